@@ -0,0 +1,124 @@
+package nanolog
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// remoteConfig is the dynamic configuration the server can push to this
+// instance at runtime: a minimum log level and a sampling rate, letting an
+// operator turn on debug logging for a single misbehaving service without
+// a redeploy. It mirrors registry.ConfigResponse on the server.
+type remoteConfig struct {
+	Level      string `json:"level"`
+	SampleRate int    `json:"sample_rate"`
+}
+
+var defaultRemoteConfig = remoteConfig{Level: "INFO", SampleRate: 100}
+
+// configPollInterval is how often NanoHandler refetches its remote config.
+// The request also doubles as this instance's keep-alive, so no separate
+// heartbeat call is needed.
+const configPollInterval = 30 * time.Second
+
+// startConfigPoller launches a background goroutine that periodically
+// fetches this instance's effective config and stores it for Enabled and
+// Handle to consult. The first tick is jittered so a fleet of instances
+// restarting together doesn't all poll in lockstep.
+func (h *NanoHandler) startConfigPoller() {
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+
+		timer := time.NewTimer(time.Duration(rand.Int63n(int64(configPollInterval))))
+		defer timer.Stop()
+
+		for {
+			select {
+			case <-timer.C:
+				h.fetchConfig()
+				timer.Reset(configPollInterval)
+			case <-h.done:
+				return
+			}
+		}
+	}()
+}
+
+// fetchConfig fetches this instance's effective config from the server and
+// stores it, leaving the previous config in place on any error.
+func (h *NanoHandler) fetchConfig() {
+	url := strings.TrimRight(h.opts.ServerURL, "/") + "/api/config?instance_id=" + h.instanceID
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return
+	}
+	_, apiKey, _ := h.session.snapshot()
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return
+	}
+
+	var cfg remoteConfig
+	if err := json.NewDecoder(resp.Body).Decode(&cfg); err != nil {
+		return
+	}
+	h.config.Store(cfg)
+}
+
+// currentConfig returns the most recently polled config, or
+// defaultRemoteConfig before the first successful poll.
+func (h *NanoHandler) currentConfig() remoteConfig {
+	if v := h.config.Load(); v != nil {
+		return v.(remoteConfig)
+	}
+	return defaultRemoteConfig
+}
+
+// levelFromString maps a ConfigResponse level name to a slog.Level,
+// defaulting to Info for anything unrecognized.
+func levelFromString(level string) slog.Level {
+	switch strings.ToUpper(level) {
+	case "DEBUG":
+		return slog.LevelDebug
+	case "WARN", "WARNING":
+		return slog.LevelWarn
+	case "ERROR", "FATAL":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// sampleKeep deterministically decides whether to keep a record for
+// (instanceID, sequence) at sampleRate percent. Hashing rather than
+// rolling dice means the decision is reproducible for a given sequence
+// number and a sample rate of, say, 10 always keeps the same ~10% of the
+// sequence space rather than a different random subset every run.
+func sampleKeep(instanceID string, sequence uint64, sampleRate int) bool {
+	if sampleRate >= 100 {
+		return true
+	}
+	if sampleRate <= 0 {
+		return false
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(instanceID))
+	h.Write([]byte(":"))
+	h.Write([]byte(strconv.FormatUint(sequence, 10)))
+	return int(h.Sum32()%100) < sampleRate
+}
@@ -11,6 +11,7 @@ import (
 	"runtime"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -19,6 +20,12 @@ type Options struct {
 	APIKey     string
 	Service    string
 	SourceHost string
+
+	// OnConfigChange, if set, is called from the session-refresh
+	// goroutine whenever a handshake changes the ingest endpoint, batch
+	// size, or retention hint this instance is running with, or rotates
+	// its API key.
+	OnConfigChange func(Config)
 }
 
 type NanoHandler struct {
@@ -29,6 +36,10 @@ type NanoHandler struct {
 	wg         sync.WaitGroup
 	attrs      []slog.Attr
 	groups     []string
+
+	config  atomic.Value // holds remoteConfig
+	seq     uint64       // sampling sequence, incremented per Handle call
+	session *session     // server-assigned ingest URL/API key/batch size
 }
 
 type LogRow struct {
@@ -57,25 +68,27 @@ func NewHandler(opts Options) *NanoHandler {
 	if h.opts.SourceHost == "" {
 		h.opts.SourceHost, _ = os.Hostname()
 	}
-
-	// Register asynchronously to not block startup
-	go func() {
-		if err := registerInstance(opts.ServerURL, opts.APIKey, opts.Service, h.instanceID); err != nil {
-			fmt.Fprintf(os.Stderr, "NanoLog Handshake Failed: %v\n", err)
-		}
-	}()
+	h.session = newSession(h.opts)
 
 	h.wg.Add(1)
 	go h.runLoop()
+	h.startSessionRefresher()
+	h.startConfigPoller()
 
 	return h
 }
 
 func (h *NanoHandler) Enabled(ctx context.Context, level slog.Level) bool {
-	return true
+	return level >= levelFromString(h.currentConfig().Level)
 }
 
 func (h *NanoHandler) Handle(ctx context.Context, r slog.Record) error {
+	cfg := h.currentConfig()
+	seq := atomic.AddUint64(&h.seq, 1)
+	if !sampleKeep(h.instanceID, seq, cfg.SampleRate) {
+		return nil
+	}
+
 	row := LogRow{
 		Timestamp:  r.Time.UnixNano(),
 		Level:      r.Level.String(),
@@ -142,7 +155,7 @@ func (h *NanoHandler) runLoop() {
 		if len(batch) == 0 {
 			return
 		}
-		
+
 		// Encode as JSON Array: [ {}, {}, {} ]
 		var buf bytes.Buffer
 		buf.WriteByte('[')
@@ -154,12 +167,13 @@ func (h *NanoHandler) runLoop() {
 		}
 		buf.WriteByte(']')
 
-		req, err := http.NewRequest("POST", strings.TrimRight(h.opts.ServerURL, "/")+"/api/ingest/batch", &buf)
+		ingestURL, apiKey, _ := h.session.snapshot()
+		req, err := http.NewRequest("POST", strings.TrimRight(ingestURL, "/")+"/api/ingest/batch", &buf)
 		if err == nil {
 			req.Header.Set("Content-Type", "application/json")
-			req.Header.Set("Authorization", "Bearer "+h.opts.APIKey)
+			req.Header.Set("Authorization", "Bearer "+apiKey)
 			req.Header.Set("X-Instance-ID", h.instanceID)
-			
+
 			client := &http.Client{Timeout: 5 * time.Second}
 			resp, err := client.Do(req)
 			if err != nil {
@@ -179,7 +193,8 @@ func (h *NanoHandler) runLoop() {
 		select {
 		case data := <-h.queue:
 			batch = append(batch, data)
-			if len(batch) >= 100 {
+			_, _, maxBatchSize := h.session.snapshot()
+			if len(batch) >= maxBatchSize {
 				send()
 			}
 		case <-ticker.C:
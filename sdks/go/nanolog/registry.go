@@ -1,31 +1,61 @@
 package nanolog
 
 import (
-	"bytes"
-	"encoding/json"
-	"fmt"
-	"io"
-	"net/http"
 	"os"
 	"path/filepath"
-	"runtime"
 	"strings"
 
 	"github.com/google/uuid"
 )
 
+// HandshakeRequest is what NanoHandler sends to /api/registry/handshake,
+// both on startup and on every later session refresh (see session.go):
+// static agent info plus the capabilities this SDK build actually
+// supports, letting the server tailor HandshakeResponse instead of
+// guessing what an arbitrary client understands.
 type HandshakeRequest struct {
 	InstanceID  string `json:"instance_id"`
 	ServiceName string `json:"service_name"`
 	HostName    string `json:"host_name"`
 	Platform    string `json:"platform"`
 	Version     string `json:"version"`
+
+	// SupportedFormats are the wire formats this SDK can decode an
+	// ingest response in. This build only ever sends/reads JSON.
+	SupportedFormats []string `json:"supported_formats"`
+	// CompressionCodecs this SDK can apply to its ingest batches before
+	// sending. Empty until compression support lands.
+	CompressionCodecs []string `json:"compression_codecs"`
+	// BufferSize is this instance's local queue capacity, in records.
+	BufferSize int `json:"buffer_size"`
+	// NanoQLOperatorLevel is the highest NanoQL operator set this SDK
+	// evaluates locally. This build does no local predicate evaluation,
+	// so it always reports 0.
+	NanoQLOperatorLevel int `json:"nanoql_operator_level"`
 }
 
+// HandshakeResponse is the server-assigned session config returned from
+// a handshake: the same level/sample_rate every SDK build understands,
+// plus the session envelope - where to send ingest traffic, how large a
+// batch to build, a retention hint, a possibly-rotated API key, and a
+// session token with a TTL the SDK should re-handshake ahead of. It
+// mirrors registry.HandshakeResponse on the server.
 type HandshakeResponse struct {
-	Status string `json:"status"`
+	Level      string `json:"level"`
+	SampleRate int    `json:"sample_rate"`
+
+	IngestEndpoints   []string `json:"ingest_endpoints"`
+	MaxBatchSize      int      `json:"max_batch_size"`
+	RetentionHint     string   `json:"retention_hint"`
+	APIKey            string   `json:"api_key"`
+	SessionToken      string   `json:"session_token"`
+	SessionTTLSeconds int      `json:"session_ttl_seconds"`
 }
 
+// ensureInstanceID returns a stable instance ID persisted under
+// ~/.nanolog/id, generating and saving a fresh UUID on first use. Any
+// failure to read or write that file falls back to an ephemeral UUID
+// good for this process only, rather than failing startup.
 func ensureInstanceID() (string, error) {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
@@ -46,41 +76,3 @@ func ensureInstanceID() (string, error) {
 	_ = os.WriteFile(idFile, []byte(newID), 0644)
 	return newID, nil
 }
-
-func registerInstance(url, apiKey, service, instanceID string) error {
-	hostname, _ := os.Hostname()
-	reqBody := HandshakeRequest{
-		InstanceID:  instanceID,
-		ServiceName: service,
-		HostName:    hostname,
-		Platform:    fmt.Sprintf("go-%s", runtime.Version()),
-		Version:     "0.1.0",
-	}
-
-	data, err := json.Marshal(reqBody)
-	if err != nil {
-		return err
-	}
-
-	req, err := http.NewRequest("POST", strings.TrimRight(url, "/")+"/api/registry/handshake", bytes.NewBuffer(data))
-	if err != nil {
-		return err
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+apiKey)
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("handshake failed: %d %s", resp.StatusCode, string(body))
-	}
-
-	return nil
-}
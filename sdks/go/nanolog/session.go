@@ -0,0 +1,204 @@
+package nanolog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config is the effective session configuration passed to
+// Options.OnConfigChange whenever a handshake changes it. It
+// deliberately excludes the API key - a callback invoked from inside
+// the SDK is the wrong place to hand a freshly rotated secret to
+// application code that might log it.
+type Config struct {
+	IngestEndpoint string
+	MaxBatchSize   int
+	RetentionHint  string
+}
+
+// defaultBatchSize is the batch flush threshold used before any
+// handshake has completed, or if a handshake's MaxBatchSize is ever
+// non-positive.
+const defaultBatchSize = 100
+
+// defaultSessionTTL seeds the refresher's retry interval when a
+// handshake fails outright (no TTL to base the next attempt on), so it
+// keeps trying at a steady pace instead of going silent.
+const defaultSessionTTL = 30 * time.Second
+
+// sessionRefreshFraction is how far into a granted session's TTL the
+// background refresher re-handshakes, leaving margin for the request
+// itself and any network hiccup to still land before the server
+// considers the old session stale.
+const sessionRefreshFraction = 0.5
+
+// session holds the mutable, server-assigned half of a NanoHandler's
+// configuration - everything a handshake can change. Guarded by its own
+// mutex rather than atomic.Value: the ingest URL, API key, and batch
+// size must always be read and swapped together as one consistent
+// unit, since a torn read pairing a freshly rotated API key with a
+// stale ingest URL (or vice versa) is exactly the bug a single lock
+// around the whole group prevents.
+type session struct {
+	mu            sync.RWMutex
+	ingestURL     string
+	apiKey        string
+	maxBatchSize  int
+	retentionHint string
+}
+
+// newSession seeds a session with opts' static server URL and API key,
+// used until the first handshake response replaces them.
+func newSession(opts Options) *session {
+	return &session{
+		ingestURL:    opts.ServerURL,
+		apiKey:       opts.APIKey,
+		maxBatchSize: defaultBatchSize,
+	}
+}
+
+// snapshot returns a consistent read of the session's current ingest
+// URL, API key, and batch size.
+func (s *session) snapshot() (ingestURL, apiKey string, maxBatchSize int) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ingestURL, s.apiKey, s.maxBatchSize
+}
+
+// apply swaps in resp's session fields atomically and returns the
+// resulting Config plus whether anything in it actually changed, so the
+// caller knows whether to invoke OnConfigChange.
+func (s *session) apply(resp HandshakeResponse, fallbackURL string) (Config, bool) {
+	ingestURL := fallbackURL
+	if len(resp.IngestEndpoints) > 0 {
+		// A clustered deployment may offer more than one; for now the
+		// SDK always takes the first and relies on the server ordering
+		// them sensibly. Spreading load across the rest is a follow-up.
+		ingestURL = resp.IngestEndpoints[0]
+	}
+	maxBatchSize := resp.MaxBatchSize
+	if maxBatchSize <= 0 {
+		maxBatchSize = defaultBatchSize
+	}
+
+	s.mu.Lock()
+	changed := s.ingestURL != ingestURL || s.maxBatchSize != maxBatchSize || s.retentionHint != resp.RetentionHint
+	s.ingestURL = ingestURL
+	s.maxBatchSize = maxBatchSize
+	s.retentionHint = resp.RetentionHint
+	if resp.APIKey != "" {
+		changed = changed || resp.APIKey != s.apiKey
+		s.apiKey = resp.APIKey
+	}
+	s.mu.Unlock()
+
+	return Config{
+		IngestEndpoint: ingestURL,
+		MaxBatchSize:   maxBatchSize,
+		RetentionHint:  resp.RetentionHint,
+	}, changed
+}
+
+// startSessionRefresher launches a background goroutine that performs
+// the instance's first handshake, then keeps re-handshaking at
+// sessionRefreshFraction of whatever TTL the server last granted,
+// reconfiguring h.session (and invoking opts.OnConfigChange on change)
+// from each response. It replaces the old fire-and-forget registration
+// call with a genuine, continuously-renewed session.
+func (h *NanoHandler) startSessionRefresher() {
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+
+		timer := time.NewTimer(0) // fire immediately for the first handshake
+		defer timer.Stop()
+
+		for {
+			select {
+			case <-timer.C:
+				timer.Reset(h.handshake())
+			case <-h.done:
+				return
+			}
+		}
+	}()
+}
+
+// handshake performs one capability exchange and applies the result to
+// h.session, returning how long to wait before the next one:
+// sessionRefreshFraction of the granted TTL on success, or
+// defaultSessionTTL (jittered) on any failure so a down server doesn't
+// get hammered.
+func (h *NanoHandler) handshake() time.Duration {
+	_, apiKey, _ := h.session.snapshot()
+
+	hostname, _ := os.Hostname()
+	reqBody := HandshakeRequest{
+		InstanceID:          h.instanceID,
+		ServiceName:         h.opts.Service,
+		HostName:            hostname,
+		Platform:            fmt.Sprintf("go-%s", runtime.Version()),
+		Version:             "0.1.0",
+		SupportedFormats:    []string{"json"},
+		CompressionCodecs:   []string{},
+		BufferSize:          cap(h.queue),
+		NanoQLOperatorLevel: 0,
+	}
+
+	data, err := json.Marshal(reqBody)
+	if err != nil {
+		return jitter(defaultSessionTTL)
+	}
+
+	req, err := http.NewRequest("POST", strings.TrimRight(h.opts.ServerURL, "/")+"/api/registry/handshake", bytes.NewBuffer(data))
+	if err != nil {
+		return jitter(defaultSessionTTL)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "NanoLog Handshake Failed: %v\n", err)
+		return jitter(defaultSessionTTL)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		fmt.Fprintf(os.Stderr, "NanoLog Handshake Failed: %d %s\n", resp.StatusCode, string(body))
+		return jitter(defaultSessionTTL)
+	}
+
+	var hresp HandshakeResponse
+	if err := json.NewDecoder(resp.Body).Decode(&hresp); err != nil {
+		return jitter(defaultSessionTTL)
+	}
+
+	cfg, changed := h.session.apply(hresp, h.opts.ServerURL)
+	if changed && h.opts.OnConfigChange != nil {
+		h.opts.OnConfigChange(cfg)
+	}
+
+	ttl := time.Duration(hresp.SessionTTLSeconds) * time.Second
+	if ttl <= 0 {
+		return jitter(defaultSessionTTL)
+	}
+	return time.Duration(float64(ttl) * sessionRefreshFraction)
+}
+
+// jitter returns d plus up to 10% extra, so a fleet of instances that
+// all failed a handshake at the same moment doesn't retry in lockstep.
+func jitter(d time.Duration) time.Duration {
+	return d + time.Duration(rand.Int63n(int64(d)/10+1))
+}
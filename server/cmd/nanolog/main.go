@@ -5,22 +5,57 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"net"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 
+	"google.golang.org/grpc"
+
+	"github.com/coffersTech/nanolog/server/internal/cluster"
+	"github.com/coffersTech/nanolog/server/internal/controller"
 	"github.com/coffersTech/nanolog/server/internal/engine"
+	"github.com/coffersTech/nanolog/server/internal/engine/cache"
+	"github.com/coffersTech/nanolog/server/internal/engine/input"
+	enginestorage "github.com/coffersTech/nanolog/server/internal/engine/storage"
+	"github.com/coffersTech/nanolog/server/internal/input/kafka"
 	"github.com/coffersTech/nanolog/server/internal/server"
+	nanologgrpc "github.com/coffersTech/nanolog/server/internal/server/grpc"
 	"github.com/coffersTech/nanolog/server/internal/storage"
 )
 
 func main() {
+	// `nanolog rebuild-index [-data dir]` forces a full rescan of the data
+	// directory and rewrites its usage.idx from scratch, for an operator
+	// who changed it out from under a stopped server (restored a backup,
+	// manually deleted files) rather than waiting for the next start's
+	// own staleness check to notice.
+	if len(os.Args) > 1 && os.Args[1] == "rebuild-index" {
+		runRebuildIndex(os.Args[2:])
+		return
+	}
+
 	// Command-line flags
 	port := flag.Int("port", 8088, "HTTP port to listen on")
 	retentionStr := flag.String("retention", "168h", "Data retention duration (e.g. 72h, 7d)")
 	dataDir := flag.String("data", "../data", "Directory to store .nano files")
 	webDir := flag.String("web", "../web", "Directory for static web files")
+	tcpJSONAddr := flag.String("tcp-json-addr", "", "Address to accept line-delimited JSON logs over TCP (empty disables)")
+	httpBulkAddr := flag.String("http-bulk-addr", "", "Address to accept newline-JSON bulk ingest over HTTP (empty disables)")
+	syslogTCPAddr := flag.String("syslog-tcp", "", "Address to accept RFC5424/RFC3164 syslog over TCP (empty disables)")
+	syslogUDPAddr := flag.String("syslog-udp", "", "Address to accept RFC5424/RFC3164 syslog over UDP (empty disables)")
+	plainTCPAddr := flag.String("plain-tcp", "", "Address to accept LEVEL|service|host|msg lines over TCP (empty disables)")
+	kafkaBrokers := flag.String("kafka-brokers", "", "Comma-separated Kafka broker addresses (empty disables Kafka ingestion)")
+	kafkaTopics := flag.String("kafka-topics", "", "Comma-separated Kafka topics to consume")
+	kafkaGroup := flag.String("kafka-group", "nanolog", "Kafka consumer group ID")
+	kafkaFormat := flag.String("kafka-format", "json", "Kafka message format: json, syslog, or raw")
+	grpcAddr := flag.String("grpc-addr", "", "Address to serve the NanoLog and OTLP logs gRPC services on (empty disables)")
+	role := flag.String("role", "standalone", "Node role: standalone, console, or ingester")
+	metaFile := flag.String("meta-file", "", "Path to the encrypted metadata store (users, tokens, config); defaults to <data>/meta.enc")
+	clusterNodes := flag.String("cluster-nodes", "", "Comma-separated data node base URLs this console aggregates (console role only)")
 	flag.Parse()
 
 	// Parse retention duration
@@ -45,21 +80,102 @@ func main() {
 	if err != nil {
 		log.Fatalf("Failed to create writer: %v", err)
 	}
-	qe := engine.NewQueryEngine(*dataDir, mt, reader.ReadSnapshot, writer.WriteSnapshot, retention)
+	fileStore, err := enginestorage.NewFileStorage(*dataDir)
+	if err != nil {
+		log.Fatalf("Failed to open storage backend: %v", err)
+	}
+	blockCache := cache.NewBlockCache(cache.DefaultCapacity)
+	qe := engine.NewQueryEngine(*dataDir, mt, fileStore, blockCache, reader.ReadSnapshot, reader.ReadColumns, writer.WriteSnapshot, retention)
 	log.Printf("QueryEngine initialized. Data: %s, Retention: %v", *dataDir, retention)
 
+	// Metadata store: users, API tokens, and runtime config, encrypted at
+	// rest in a single file by default.
+	if *metaFile == "" {
+		*metaFile = filepath.Join(*dataDir, "meta.enc")
+	}
+	metaStore := controller.NewStore(*metaFile)
+	if err := metaStore.Load(); err != nil {
+		log.Fatalf("Failed to load metadata store: %v", err)
+	}
+
+	// Console nodes aggregate queries across the data nodes listed in
+	// -cluster-nodes; standalone and ingester nodes have no aggregator.
+	var aggregator *cluster.Aggregator
+	if *role == "console" && *clusterNodes != "" {
+		aggregator = cluster.NewAggregator(strings.Split(*clusterNodes, ","))
+	}
+
 	// Start Background Cleaner
 	go qe.RunCleaner(1 * time.Hour)
 
+	// Optional network ingestion listeners, enabled individually via flags.
+	if *tcpJSONAddr != "" {
+		if err := qe.RegisterListener(input.NewTCPJSONListener(*tcpJSONAddr, qe, input.DefaultReadTimeout)); err != nil {
+			log.Fatalf("Failed to start TCP JSON listener: %v", err)
+		}
+	}
+	if *httpBulkAddr != "" {
+		if err := qe.RegisterListener(input.NewHTTPBulkListener(*httpBulkAddr, "/bulk", qe, input.DefaultReadTimeout)); err != nil {
+			log.Fatalf("Failed to start HTTP bulk listener: %v", err)
+		}
+	}
+	if *syslogTCPAddr != "" {
+		if err := qe.RegisterListener(input.NewSyslogListener(*syslogTCPAddr, "tcp", qe, input.DefaultReadTimeout)); err != nil {
+			log.Fatalf("Failed to start syslog TCP listener: %v", err)
+		}
+	}
+	if *syslogUDPAddr != "" {
+		if err := qe.RegisterListener(input.NewSyslogListener(*syslogUDPAddr, "udp", qe, input.DefaultReadTimeout)); err != nil {
+			log.Fatalf("Failed to start syslog UDP listener: %v", err)
+		}
+	}
+	if *plainTCPAddr != "" {
+		if err := qe.RegisterListener(input.NewTCPLineListener(*plainTCPAddr, input.PlainDecoder{}, qe, input.DefaultReadTimeout)); err != nil {
+			log.Fatalf("Failed to start plain-text TCP listener: %v", err)
+		}
+	}
+
+	// Optional Kafka consumer, feeding the same MemTable as HTTP ingest.
+	var kafkaConsumer *kafka.Consumer
+	if *kafkaBrokers != "" && *kafkaTopics != "" {
+		kafkaConsumer = kafka.NewConsumer(kafka.Config{
+			Brokers: strings.Split(*kafkaBrokers, ","),
+			Topics:  strings.Split(*kafkaTopics, ","),
+			GroupID: *kafkaGroup,
+			Format:  kafka.Format(*kafkaFormat),
+		}, mt)
+		if err := kafkaConsumer.Start(); err != nil {
+			log.Fatalf("Failed to start Kafka consumer: %v", err)
+		}
+	}
+
+	// Optional gRPC server, exposing the same MemTable over NanoLog's native
+	// streaming RPCs and OTLP logs.v1 Export.
+	var grpcServer *grpc.Server
+	if *grpcAddr != "" {
+		lis, err := net.Listen("tcp", *grpcAddr)
+		if err != nil {
+			log.Fatalf("Failed to listen on %s for gRPC: %v", *grpcAddr, err)
+		}
+		grpcServer = grpc.NewServer()
+		nanologgrpc.NewServer(mt, metaStore).Register(grpcServer)
+		go func() {
+			log.Printf("gRPC listening on %s", *grpcAddr)
+			if err := grpcServer.Serve(lis); err != nil {
+				log.Printf("gRPC server stopped: %v", err)
+			}
+		}()
+	}
+
 	// 3. Initialize IngestServer with web directory
-	srv := server.NewIngestServer(mt, qe, *webDir, *dataDir)
+	srv := server.NewIngestServer(qe, metaStore, *webDir, *dataDir, *role, aggregator)
 	addr := fmt.Sprintf(":%d", *port)
 
 	// 4. Start HTTP Server in a goroutine
 	go func() {
 		log.Printf("Listening on %s", addr)
 		log.Printf("Dashboard available at http://localhost%s", addr)
-		if err := srv.Start(addr); err != nil {
+		if err := srv.Start(addr, *role); err != nil {
 			log.Printf("Server stopped: %v", err)
 		}
 	}()
@@ -79,6 +195,15 @@ func main() {
 	if err := srv.Shutdown(ctx); err != nil {
 		log.Printf("Server shutdown error: %v", err)
 	}
+	qe.StopListeners()
+	if grpcServer != nil {
+		grpcServer.GracefulStop()
+	}
+	if kafkaConsumer != nil {
+		if err := kafkaConsumer.Stop(); err != nil {
+			log.Printf("Kafka consumer stop error: %v", err)
+		}
+	}
 
 	log.Println("Flushing memory to disk...")
 	if err := qe.Flush(); err != nil {
@@ -87,3 +212,22 @@ func main() {
 
 	log.Println("NanoLog exited gracefully.")
 }
+
+// runRebuildIndex implements the `rebuild-index` subcommand: open dir's
+// storage directly (no MemTable, WAL, or QueryEngine needed - this never
+// touches live data, just the usage.idx sidecar) and force a full rescan.
+func runRebuildIndex(args []string) {
+	fs := flag.NewFlagSet("rebuild-index", flag.ExitOnError)
+	dataDir := fs.String("data", "../data", "Directory to store .nano files")
+	fs.Parse(args)
+
+	store, err := enginestorage.NewFileStorage(*dataDir)
+	if err != nil {
+		log.Fatalf("Failed to open storage backend: %v", err)
+	}
+	idx, err := engine.RebuildUsageIndex(store)
+	if err != nil {
+		log.Fatalf("Failed to rebuild usage index: %v", err)
+	}
+	log.Printf("Usage index rebuilt for %s: %d files indexed", *dataDir, len(idx.Snapshot()))
+}
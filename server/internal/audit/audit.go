@@ -0,0 +1,92 @@
+// Package audit implements an append-only log of security-relevant
+// actions (token lifecycle, failed auth attempts, admin changes) for
+// deployments that need to answer "who did what, from where, when".
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Entry is one recorded action.
+type Entry struct {
+	Timestamp int64  `json:"timestamp"`
+	Actor     string `json:"actor"`   // username, token ID, or "" if unauthenticated
+	IP        string `json:"ip"`      // source IP, port stripped
+	Action    string `json:"action"`  // e.g. "token_create", "auth_failed"
+	Outcome   string `json:"outcome"` // "success" or "failure"
+	Detail    string `json:"detail,omitempty"`
+}
+
+// Logger appends Entries as newline-delimited JSON to a file under
+// dataDir, so the audit trail survives a restart and can be tailed or
+// shipped like any other log file.
+type Logger struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+// NewLogger opens (creating if needed) dataDir/audit.log for appending.
+func NewLogger(dataDir string) (*Logger, error) {
+	f, err := os.OpenFile(filepath.Join(dataDir, "audit.log"), os.O_APPEND|os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &Logger{f: f}, nil
+}
+
+// Log appends e to the log file.
+func (l *Logger) Log(e Entry) error {
+	raw, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	raw = append(raw, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, err = l.f.Write(raw)
+	return err
+}
+
+// Recent returns up to limit of the most recently logged entries, oldest
+// first. It re-reads the file from the start each call, which is fine
+// for an admin-facing, low-traffic endpoint but isn't meant for serving
+// a very large audit history.
+func (l *Logger) Recent(limit int) ([]Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, err := l.f.Seek(0, 0); err != nil {
+		return nil, err
+	}
+
+	var all []Entry
+	scanner := bufio.NewScanner(l.f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			continue
+		}
+		all = append(all, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if limit > 0 && len(all) > limit {
+		all = all[len(all)-limit:]
+	}
+	return all, nil
+}
+
+// Close closes the underlying file.
+func (l *Logger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.f.Close()
+}
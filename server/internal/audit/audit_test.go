@@ -0,0 +1,55 @@
+package audit
+
+import "testing"
+
+func TestLogAndRecent(t *testing.T) {
+	l, err := NewLogger(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLogger: %v", err)
+	}
+	defer l.Close()
+
+	entries := []Entry{
+		{Timestamp: 1, Actor: "alice", Action: "token_create", Outcome: "success"},
+		{Timestamp: 2, Actor: "", Action: "auth_failed", Outcome: "failure", Detail: "missing bearer token"},
+	}
+	for _, e := range entries {
+		if err := l.Log(e); err != nil {
+			t.Fatalf("Log: %v", err)
+		}
+	}
+
+	got, err := l.Recent(10)
+	if err != nil {
+		t.Fatalf("Recent: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(got))
+	}
+	if got[0].Action != "token_create" || got[1].Action != "auth_failed" {
+		t.Fatalf("unexpected entries: %+v", got)
+	}
+}
+
+func TestRecentLimitsToMostRecent(t *testing.T) {
+	l, err := NewLogger(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLogger: %v", err)
+	}
+	defer l.Close()
+
+	for i := int64(0); i < 5; i++ {
+		l.Log(Entry{Timestamp: i, Action: "auth_failed"})
+	}
+
+	got, err := l.Recent(2)
+	if err != nil {
+		t.Fatalf("Recent: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(got))
+	}
+	if got[0].Timestamp != 3 || got[1].Timestamp != 4 {
+		t.Fatalf("expected the 2 most recent entries, got %+v", got)
+	}
+}
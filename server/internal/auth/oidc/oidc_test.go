@@ -0,0 +1,51 @@
+package oidc
+
+import "testing"
+
+func TestMapRoleByGroups(t *testing.T) {
+	v := &Verifier{cfg: Config{
+		RoleClaim:    "groups",
+		RoleMappings: map[string]string{"nanolog-admins": "admin", "nanolog-viewers": "viewer"},
+	}}
+
+	role, ok := v.MapRole(&Claims{Groups: []string{"engineering", "nanolog-admins"}})
+	if !ok || role != "admin" {
+		t.Fatalf("MapRole() = %q, %v, want \"admin\", true", role, ok)
+	}
+}
+
+func TestMapRoleByEmail(t *testing.T) {
+	v := &Verifier{cfg: Config{
+		RoleClaim:    "email",
+		RoleMappings: map[string]string{"root@example.com": "super_admin"},
+	}}
+
+	role, ok := v.MapRole(&Claims{Email: "root@example.com"})
+	if !ok || role != "super_admin" {
+		t.Fatalf("MapRole() = %q, %v, want \"super_admin\", true", role, ok)
+	}
+}
+
+func TestMapRoleFallsBackToDefaultRole(t *testing.T) {
+	v := &Verifier{cfg: Config{
+		RoleClaim:    "groups",
+		RoleMappings: map[string]string{"nanolog-admins": "admin"},
+		DefaultRole:  "viewer",
+	}}
+
+	role, ok := v.MapRole(&Claims{Groups: []string{"unrelated-group"}})
+	if !ok || role != "viewer" {
+		t.Fatalf("MapRole() = %q, %v, want \"viewer\", true", role, ok)
+	}
+}
+
+func TestMapRoleRefusesWithNoMatchAndNoDefault(t *testing.T) {
+	v := &Verifier{cfg: Config{
+		RoleClaim:    "groups",
+		RoleMappings: map[string]string{"nanolog-admins": "admin"},
+	}}
+
+	if _, ok := v.MapRole(&Claims{Groups: []string{"unrelated-group"}}); ok {
+		t.Fatalf("MapRole() ok = true, want false for an unmapped claim with no default role")
+	}
+}
@@ -1,6 +1,7 @@
 package cluster
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -16,6 +17,22 @@ import (
 type Aggregator struct {
 	DataNodes []string
 	Client    *http.Client
+
+	// StreamClient is used for long-lived SSE connections (Tail), which
+	// Client's fixed Timeout would otherwise cut off mid-stream since
+	// that timeout bounds the whole request including reading the body.
+	StreamClient *http.Client
+
+	// Registry, when set, drives fan-out instead of the static
+	// DataNodes list: Dead nodes are skipped entirely, a node that's
+	// Degraded is expected to occasionally time out so its failure is
+	// reported back as a partial result rather than a surprise, and a
+	// node its CapabilityProber marked unhealthy is skipped the same way
+	// a Dead one is. Nil Registry (e.g. a static multi-node deployment
+	// with no keepalive subsystem) falls back to DataNodes with no
+	// partial tracking beyond per-request failures, and every node
+	// treated as fully capable.
+	Registry *Registry
 }
 
 // NewAggregator creates a new Aggregator instance.
@@ -23,27 +40,149 @@ type QueryParams struct {
 	RawQuery string
 	Limit    int
 	Auth     string
+
+	// LastEventID, when set, is forwarded as the Last-Event-ID header on
+	// Tail's per-node SSE connections so a reconnecting client resumes
+	// from where it left off on every node, not just the ones it was
+	// already connected to.
+	LastEventID string
+
+	// Cursor, when set, is a Cursor.Encode()d token from a prior
+	// SearchStream call. It's decoded and applied per-node (as that
+	// node's max_ts) so a caller paging through a streamed search doesn't
+	// re-receive rows it already saw.
+	Cursor string
 }
 
 func NewAggregator(nodes []string) *Aggregator {
 	return &Aggregator{
-		DataNodes: nodes,
-		Client:    &http.Client{Timeout: 10 * time.Second},
+		DataNodes:    nodes,
+		Client:       &http.Client{Timeout: 10 * time.Second},
+		StreamClient: &http.Client{},
 	}
 }
 
-// Search performs a scatter-gather query across all data nodes.
-func (a *Aggregator) Search(params QueryParams) ([]engine.LogRow, error) {
+// healthyNodeTimeout and degradedNodeTimeout bound how long Search,
+// Histogram, and Stats wait on a single node, enforced via context
+// rather than Client's fixed Timeout so one slow node's budget doesn't
+// have to match every other node's. A Degraded node (per the Registry's
+// keepalive-derived health) gets a shorter budget since it's already
+// expected to occasionally miss it.
+const (
+	healthyNodeTimeout  = 8 * time.Second
+	degradedNodeTimeout = 2 * time.Second
+)
+
+// NodeError records why one node didn't contribute to a fan-out result,
+// so a caller can render "3 of 5 nodes responded: node-b timed out,
+// node-d is unhealthy" instead of a bare partial flag.
+type NodeError struct {
+	Node   string `json:"node"`
+	Reason string `json:"reason"`
+}
+
+// FanoutResult carries scatter-gather metadata alongside a query's
+// actual result. Partial is true if one or more live nodes failed to
+// respond in time, so the caller can warn the user their results may be
+// incomplete instead of presenting them as exhaustive. Errors carries
+// the reason for each node in PartialNodes, in the same order.
+type FanoutResult struct {
+	Partial      bool        `json:"partial,omitempty"`
+	PartialNodes []string    `json:"partial_nodes,omitempty"`
+	Errors       []NodeError `json:"errors,omitempty"`
+}
+
+// fail records nodeURL as having failed to contribute to the result for
+// the given reason. Callers hold mu for the duration of the call.
+func (r *FanoutResult) fail(nodeURL, reason string) {
+	r.Partial = true
+	r.PartialNodes = append(r.PartialNodes, nodeURL)
+	r.Errors = append(r.Errors, NodeError{Node: nodeURL, Reason: reason})
+}
+
+func (r *FanoutResult) sort() {
+	sort.Strings(r.PartialNodes)
+	sort.Slice(r.Errors, func(i, j int) bool { return r.Errors[i].Node < r.Errors[j].Node })
+}
+
+// dispatchTarget is one node to fan a query out to, along with enough
+// Registry-derived context to pick a per-node timeout and downgrade the
+// query to what it actually supports.
+type dispatchTarget struct {
+	Addr     string
+	Degraded bool
+	Caps     NodeCapabilities
+}
+
+// dispatchTargets returns every node the Aggregator should fan out to
+// this round: the Registry's live, probe-healthy view if one is wired
+// up, or the static DataNodes list (every node treated as healthy and
+// fully capable) otherwise.
+func (a *Aggregator) dispatchTargets() []dispatchTarget {
+	if a.Registry == nil {
+		targets := make([]dispatchTarget, len(a.DataNodes))
+		for i, addr := range a.DataNodes {
+			targets[i] = dispatchTarget{Addr: addr, Caps: LocalCapabilities()}
+		}
+		return targets
+	}
+
+	addrs, degraded := a.Registry.LiveAddrs()
+	targets := make([]dispatchTarget, 0, len(addrs))
+	for _, addr := range addrs {
+		if !a.Registry.ProbeHealthy(addr) {
+			continue
+		}
+		// A node that hasn't been probed yet (the prober hasn't made its
+		// first round) is assumed fully capable, the same way
+		// ProbeHealthy assumes it's reachable - better to dispatch
+		// un-downgraded and let the request itself fail than to downgrade
+		// every query before ever learning what the node can do.
+		caps, probed := a.Registry.Capabilities(addr)
+		if !probed {
+			caps = LocalCapabilities()
+		}
+		targets = append(targets, dispatchTarget{Addr: addr, Degraded: degraded[addr], Caps: caps})
+	}
+	return targets
+}
+
+// liveNodes returns just the addrs from dispatchTargets, for callers
+// (Tail) that don't need per-node capability/degraded info.
+func (a *Aggregator) liveNodes() []string {
+	targets := a.dispatchTargets()
+	addrs := make([]string, len(targets))
+	for i, t := range targets {
+		addrs[i] = t.Addr
+	}
+	return addrs
+}
+
+func (t dispatchTarget) timeout() time.Duration {
+	if t.Degraded {
+		return degradedNodeTimeout
+	}
+	return healthyNodeTimeout
+}
+
+// Search performs a scatter-gather query across all live data nodes.
+func (a *Aggregator) Search(ctx context.Context, params QueryParams) ([]engine.LogRow, FanoutResult, error) {
 	var allRows []engine.LogRow
 	var mu sync.Mutex
 	var wg sync.WaitGroup
+	var result FanoutResult
 
-	for _, node := range a.DataNodes {
+	for _, target := range a.dispatchTargets() {
 		wg.Add(1)
-		go func(nodeURL string) {
+		go func(target dispatchTarget) {
 			defer wg.Done()
-			url := fmt.Sprintf("%s/api/search?%s", nodeURL, params.RawQuery)
-			req, err := http.NewRequest("GET", url, nil)
+
+			nodeCtx, cancel := context.WithTimeout(ctx, target.timeout())
+			defer cancel()
+
+			rawQuery := downgradeQuery(params.RawQuery, target.Caps)
+			reqURL := fmt.Sprintf("%s/api/search?%s", target.Addr, rawQuery)
+			req, err := http.NewRequestWithContext(nodeCtx, "GET", reqURL, nil)
 			if err != nil {
 				return
 			}
@@ -53,7 +192,10 @@ func (a *Aggregator) Search(params QueryParams) ([]engine.LogRow, error) {
 
 			resp, err := a.Client.Do(req)
 			if err != nil {
-				log.Printf("[Aggregator] Error from node %s: %v", nodeURL, err)
+				log.Printf("[Aggregator] Error from node %s: %v", target.Addr, err)
+				mu.Lock()
+				result.fail(target.Addr, err.Error())
+				mu.Unlock()
 				return
 			}
 			defer resp.Body.Close()
@@ -66,9 +208,12 @@ func (a *Aggregator) Search(params QueryParams) ([]engine.LogRow, error) {
 					mu.Unlock()
 				}
 			} else {
-				log.Printf("[Aggregator] Node %s returned status %d", nodeURL, resp.StatusCode)
+				log.Printf("[Aggregator] Node %s returned status %d", target.Addr, resp.StatusCode)
+				mu.Lock()
+				result.fail(target.Addr, fmt.Sprintf("status %d", resp.StatusCode))
+				mu.Unlock()
 			}
-		}(node)
+		}(target)
 	}
 
 	wg.Wait()
@@ -83,26 +228,37 @@ func (a *Aggregator) Search(params QueryParams) ([]engine.LogRow, error) {
 		allRows = allRows[:params.Limit]
 	}
 
-	return allRows, nil
+	result.sort()
+	return allRows, result, nil
 }
 
-// Histogram performs scatter-gather histogram aggregation.
-func (a *Aggregator) Histogram(params QueryParams) ([]engine.HistogramPoint, error) {
+// Histogram performs scatter-gather histogram aggregation across all
+// live data nodes.
+func (a *Aggregator) Histogram(ctx context.Context, params QueryParams) ([]engine.HistogramPoint, FanoutResult, error) {
 	combined := make(map[int64]int64)
 	var mu sync.Mutex
 	var wg sync.WaitGroup
+	var fanout FanoutResult
 
-	for _, node := range a.DataNodes {
+	for _, target := range a.dispatchTargets() {
 		wg.Add(1)
-		go func(nodeURL string) {
+		go func(target dispatchTarget) {
 			defer wg.Done()
-			url := fmt.Sprintf("%s/api/histogram?%s", nodeURL, params.RawQuery)
-			req, _ := http.NewRequest("GET", url, nil)
+
+			nodeCtx, cancel := context.WithTimeout(ctx, target.timeout())
+			defer cancel()
+
+			rawQuery := downgradeQuery(params.RawQuery, target.Caps)
+			reqURL := fmt.Sprintf("%s/api/histogram?%s", target.Addr, rawQuery)
+			req, _ := http.NewRequestWithContext(nodeCtx, "GET", reqURL, nil)
 			if params.Auth != "" {
 				req.Header.Set("Authorization", params.Auth)
 			}
 			resp, err := a.Client.Do(req)
 			if err != nil {
+				mu.Lock()
+				fanout.fail(target.Addr, err.Error())
+				mu.Unlock()
 				return
 			}
 			defer resp.Body.Close()
@@ -116,8 +272,12 @@ func (a *Aggregator) Histogram(params QueryParams) ([]engine.HistogramPoint, err
 					}
 					mu.Unlock()
 				}
+			} else {
+				mu.Lock()
+				fanout.fail(target.Addr, fmt.Sprintf("status %d", resp.StatusCode))
+				mu.Unlock()
 			}
-		}(node)
+		}(target)
 	}
 	wg.Wait()
 
@@ -129,29 +289,39 @@ func (a *Aggregator) Histogram(params QueryParams) ([]engine.HistogramPoint, err
 		return result[i].Time < result[j].Time
 	})
 
-	return result, nil
+	fanout.sort()
+	return result, fanout, nil
 }
 
-// Stats performs scatter-gather stats aggregation.
-func (a *Aggregator) Stats(auth string) (engine.SystemStats, error) {
+// Stats performs scatter-gather stats aggregation across all live data
+// nodes.
+func (a *Aggregator) Stats(ctx context.Context, auth string) (engine.SystemStats, FanoutResult, error) {
 	var total engine.SystemStats
 	total.LevelDist = make(map[string]int)
 	total.TopServices = make(map[string]int)
 
 	var mu sync.Mutex
 	var wg sync.WaitGroup
+	var fanout FanoutResult
 
-	for _, node := range a.DataNodes {
+	for _, target := range a.dispatchTargets() {
 		wg.Add(1)
-		go func(nodeURL string) {
+		go func(target dispatchTarget) {
 			defer wg.Done()
-			url := fmt.Sprintf("%s/api/stats", nodeURL)
-			req, _ := http.NewRequest("GET", url, nil)
+
+			nodeCtx, cancel := context.WithTimeout(ctx, target.timeout())
+			defer cancel()
+
+			reqURL := fmt.Sprintf("%s/api/stats", target.Addr)
+			req, _ := http.NewRequestWithContext(nodeCtx, "GET", reqURL, nil)
 			if auth != "" {
 				req.Header.Set("Authorization", auth)
 			}
 			resp, err := a.Client.Do(req)
 			if err != nil {
+				mu.Lock()
+				fanout.fail(target.Addr, err.Error())
+				mu.Unlock()
 				return
 			}
 			defer resp.Body.Close()
@@ -171,10 +341,15 @@ func (a *Aggregator) Stats(auth string) (engine.SystemStats, error) {
 					}
 					mu.Unlock()
 				}
+			} else {
+				mu.Lock()
+				fanout.fail(target.Addr, fmt.Sprintf("status %d", resp.StatusCode))
+				mu.Unlock()
 			}
-		}(node)
+		}(target)
 	}
 	wg.Wait()
 
-	return total, nil
+	fanout.sort()
+	return total, fanout, nil
 }
@@ -0,0 +1,92 @@
+package cluster
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Feature is a NanoQL operator or engine capability that isn't guaranteed
+// to exist on every node in a cluster that was rolled out in stages.
+type Feature string
+
+const (
+	FeatureRange Feature = "range" // key:[lo..hi], key:>=value
+	FeatureRegex Feature = "regex" // key~"pattern"
+	FeatureIn    Feature = "in"    // key IN (a, b, c)
+	FeatureGlob  Feature = "glob"  // key:host-*
+)
+
+// ServerVersion is this build's reported version, served from
+// /api/capabilities. Like any node's reported Version, Supports checks it
+// against featureMinVersion.
+const ServerVersion = "0.4.2"
+
+// featureMinVersion is the versioned feature map this build knows about,
+// modeled on etcd's static capability table: each feature lists the
+// lowest node version permitted to claim it, so a node's own
+// /api/capabilities response is trusted only as far as its reported
+// Version allows.
+var featureMinVersion = map[Feature]string{
+	FeatureRange: "0.1.0",
+	FeatureRegex: "0.3.1",
+	FeatureIn:    "0.4.1",
+	FeatureGlob:  "0.4.1",
+}
+
+// NodeCapabilities is what a node reports from GET /api/capabilities:
+// its protocol version, the NanoQL features and histogram bucket sizes
+// it understands, and the compression schemes it'll accept. The
+// Aggregator uses it to downgrade a query per node instead of assuming
+// every node in the cluster was built from the same commit.
+type NodeCapabilities struct {
+	Version          string    `json:"version"`
+	Features         []Feature `json:"features"`
+	HistogramBuckets []string  `json:"histogram_buckets,omitempty"`
+	Compression      []string  `json:"compression,omitempty"`
+}
+
+// LocalCapabilities returns the capability set this running binary
+// supports, for serving from its own /api/capabilities endpoint.
+func LocalCapabilities() NodeCapabilities {
+	return NodeCapabilities{
+		Version:          ServerVersion,
+		Features:         []Feature{FeatureRange, FeatureRegex, FeatureIn, FeatureGlob},
+		HistogramBuckets: []string{"1s", "1m", "1h"},
+		Compression:      []string{"gzip"},
+	}
+}
+
+// Supports reports whether caps both lists feature and permits it per
+// featureMinVersion, so a node advertising a feature its reported
+// Version predates doesn't get more dispatched to it than the version
+// itself would allow.
+func (c NodeCapabilities) Supports(feature Feature) bool {
+	if min, known := featureMinVersion[feature]; known && !versionAtLeast(c.Version, min) {
+		return false
+	}
+	for _, f := range c.Features {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
+
+// versionAtLeast reports whether v is >= min, comparing dotted numeric
+// components (major.minor.patch). A malformed or empty component
+// compares as zero, so an unparseable version never outranks a real one.
+func versionAtLeast(v, min string) bool {
+	vp := strings.Split(v, ".")
+	mp := strings.Split(min, ".")
+	for i := 0; i < len(mp); i++ {
+		var vn, mn int
+		if i < len(vp) {
+			vn, _ = strconv.Atoi(vp[i])
+		}
+		mn, _ = strconv.Atoi(mp[i])
+		if vn != mn {
+			return vn > mn
+		}
+	}
+	return true
+}
@@ -0,0 +1,52 @@
+package cluster
+
+import (
+	"encoding/base64"
+	"encoding/json"
+)
+
+// Cursor resumes a streamed search from where a previous call to
+// SearchStream left off: for each node addr it queried, the timestamp of
+// the last row that node emitted. A caller re-dispatches with this
+// cursor's Encode()d form in QueryParams.Cursor to page forward without
+// re-scanning rows it's already seen. Like Tail's Last-Event-ID, this is
+// timestamp-granular rather than a true per-row sequence number: the next
+// page asks each node for rows strictly older than its last emitted
+// timestamp, so rows sharing that timestamp which the previous page did
+// not emit are skipped across a page boundary.
+type Cursor map[string]int64
+
+// Encode base64-encodes c as JSON, so it round-trips through a URL query
+// parameter or a JSON response field without escaping. An empty Cursor
+// encodes to "" rather than "e30=" (base64 of "{}"), so a fresh query
+// with nothing to resume from can omit the field entirely.
+func (c Cursor) Encode() string {
+	if len(c) == 0 {
+		return ""
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return ""
+	}
+	return base64.URLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor parses a Cursor produced by Encode. An empty or malformed
+// token decodes to a nil Cursor rather than an error, the same way
+// downgradeQuery treats an unparseable query as "nothing to adjust" -
+// resuming from scratch is a safer failure mode for a GET query param
+// than refusing the request outright.
+func DecodeCursor(token string) Cursor {
+	if token == "" {
+		return nil
+	}
+	data, err := base64.URLEncoding.DecodeString(token)
+	if err != nil {
+		return nil
+	}
+	var c Cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil
+	}
+	return c
+}
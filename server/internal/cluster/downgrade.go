@@ -0,0 +1,208 @@
+package cluster
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/coffersTech/nanolog/server/internal/pkg/nanoql"
+)
+
+// downgradeQuery rewrites rawQuery's "q" parameter so it only uses NanoQL
+// features caps supports, returning rawQuery unchanged if the query
+// parses to nothing, doesn't parse at all (the node's own /api/search
+// will report the same error), or already fits within caps. This runs
+// once per node per request rather than once per row, so a cluster with
+// a mix of node versions doesn't need every node upgraded before a newer
+// query syntax is usable anywhere.
+func downgradeQuery(rawQuery string, caps NodeCapabilities) string {
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return rawQuery
+	}
+
+	q := values.Get("q")
+	if q == "" {
+		return rawQuery
+	}
+
+	node, err := nanoql.Parse(q)
+	if err != nil || node == nil {
+		return rawQuery
+	}
+
+	needed := requiredFeatures(node)
+	fullySupported := true
+	for feat := range needed {
+		if !caps.Supports(feat) {
+			fullySupported = false
+			break
+		}
+	}
+	if fullySupported {
+		return rawQuery
+	}
+
+	values.Set("q", render(downgradeForNode(node, caps)))
+	return values.Encode()
+}
+
+// requiredFeatures returns the set of optional NanoQL features node's
+// predicate tree uses, so a node's capabilities only need to be checked
+// against what this particular query actually needs.
+func requiredFeatures(node nanoql.Node) map[Feature]bool {
+	feats := make(map[Feature]bool)
+	collectFeatures(node, feats)
+	return feats
+}
+
+func collectFeatures(node nanoql.Node, feats map[Feature]bool) {
+	switch n := node.(type) {
+	case nanoql.BinaryExpr:
+		collectFeatures(n.Left, feats)
+		collectFeatures(n.Right, feats)
+	case nanoql.NotExpr:
+		collectFeatures(n.Expr, feats)
+	case nanoql.RangeExpr:
+		feats[FeatureRange] = true
+	case nanoql.RegexExpr:
+		feats[FeatureRegex] = true
+	case nanoql.InExpr:
+		feats[FeatureIn] = true
+	case nanoql.MatchExpr:
+		if isGlobField(n.Key) && isGlobPattern(n.Value) {
+			feats[FeatureGlob] = true
+		}
+	}
+}
+
+// isGlobField and isGlobPattern mirror the glob-detection nanoql.Match
+// uses internally (unexported there) so a query can be downgraded before
+// it's ever dispatched, not just evaluated differently once it arrives.
+// They must stay in sync with nanoql's own isGlobField/isGlobPattern -
+// otherwise a field nanoql never globs on (e.g. message) could get
+// needlessly downgraded here.
+func isGlobField(key string) bool {
+	switch strings.ToLower(key) {
+	case "host", "ip", "hostname", "service", "svc":
+		return true
+	default:
+		return false
+	}
+}
+
+func isGlobPattern(value string) bool {
+	return strings.ContainsAny(value, "*?")
+}
+
+// downgradeForNode rewrites node's predicate tree to use only features
+// caps.Supports, pushing each unsupported operator down to the closest
+// thing an older node can still evaluate: REGEX becomes a full-text
+// CONTAINS on the literal pattern, IN becomes an OR chain of equality
+// matches, and a glob match becomes a full-text CONTAINS on the pattern
+// with its wildcard characters stripped. A node missing a feature still
+// gets a usable (if coarser) result instead of a parse error.
+func downgradeForNode(node nanoql.Node, caps NodeCapabilities) nanoql.Node {
+	switch n := node.(type) {
+	case nanoql.BinaryExpr:
+		return nanoql.BinaryExpr{Op: n.Op, Left: downgradeForNode(n.Left, caps), Right: downgradeForNode(n.Right, caps)}
+
+	case nanoql.NotExpr:
+		return nanoql.NotExpr{Expr: downgradeForNode(n.Expr, caps)}
+
+	case nanoql.RegexExpr:
+		if caps.Supports(FeatureRegex) {
+			return n
+		}
+		return nanoql.MatchExpr{Value: n.Pattern, Op: "CONTAINS"}
+
+	case nanoql.InExpr:
+		if caps.Supports(FeatureIn) || len(n.Values) == 0 {
+			return n
+		}
+		var chain nanoql.Node
+		for _, v := range n.Values {
+			eq := nanoql.MatchExpr{Key: n.Key, Value: v, Op: "="}
+			if chain == nil {
+				chain = eq
+			} else {
+				chain = nanoql.BinaryExpr{Op: "OR", Left: chain, Right: eq}
+			}
+		}
+		return chain
+
+	case nanoql.MatchExpr:
+		if isGlobField(n.Key) && isGlobPattern(n.Value) && !caps.Supports(FeatureGlob) {
+			stripped := strings.NewReplacer("*", "", "?", "").Replace(n.Value)
+			return nanoql.MatchExpr{Value: stripped, Op: "CONTAINS"}
+		}
+		return n
+
+	default:
+		return node
+	}
+}
+
+// render serializes node back into NanoQL query text - the inverse of
+// nanoql.Parse, close enough to round-trip a downgraded tree before
+// forwarding it on to a node.
+func render(node nanoql.Node) string {
+	switch n := node.(type) {
+	case nanoql.BinaryExpr:
+		return fmt.Sprintf("(%s %s %s)", render(n.Left), n.Op, render(n.Right))
+
+	case nanoql.NotExpr:
+		return fmt.Sprintf("NOT (%s)", render(n.Expr))
+
+	case nanoql.MatchExpr:
+		if n.Key == "" {
+			return strconv.Quote(n.Value)
+		}
+		op := n.Op
+		if op == "" {
+			op = "="
+		}
+		if op == "=" {
+			return fmt.Sprintf("%s:%s", n.Key, strconv.Quote(n.Value))
+		}
+		return fmt.Sprintf("%s%s%s", n.Key, op, strconv.Quote(n.Value))
+
+	case nanoql.RangeExpr:
+		return renderRange(n)
+
+	case nanoql.RegexExpr:
+		return fmt.Sprintf("%s~%s", n.Key, strconv.Quote(n.Pattern))
+
+	case nanoql.InExpr:
+		quoted := make([]string, len(n.Values))
+		for i, v := range n.Values {
+			quoted[i] = strconv.Quote(v)
+		}
+		return fmt.Sprintf("%s IN (%s)", n.Key, strings.Join(quoted, ", "))
+
+	default:
+		return ""
+	}
+}
+
+func renderRange(n nanoql.RangeExpr) string {
+	switch {
+	case n.HasLo && n.HasHi && n.LoIncl && n.HiIncl:
+		return fmt.Sprintf("%s:[%s..%s]", n.Key, n.Lo, n.Hi)
+	case n.HasLo && !n.HasHi:
+		op := ">="
+		if !n.LoIncl {
+			op = ">"
+		}
+		return fmt.Sprintf("%s:%s%s", n.Key, op, n.Lo)
+	case n.HasHi && !n.HasLo:
+		op := "<="
+		if !n.HiIncl {
+			op = "<"
+		}
+		return fmt.Sprintf("%s:%s%s", n.Key, op, n.Hi)
+	default:
+		return fmt.Sprintf("%s:[%s..%s]", n.Key, n.Lo, n.Hi)
+	}
+}
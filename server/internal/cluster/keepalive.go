@@ -0,0 +1,81 @@
+package cluster
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+)
+
+// KeepaliveSender periodically POSTs this node's NodeInfo to the
+// console's /api/cluster/keepalive, so the console's Registry can track
+// this node's health. An ingester owns one; the console does not.
+type KeepaliveSender struct {
+	ConsoleAddr string
+	Auth        string
+	Client      *http.Client
+	// Info is called fresh on every tick so the reported disk_free,
+	// ingest_rate, and oldest_wal_ts reflect current state rather than a
+	// value captured once at startup.
+	Info func() NodeInfo
+
+	stop chan struct{}
+}
+
+// NewKeepaliveSender creates a sender that reports to consoleAddr.
+func NewKeepaliveSender(consoleAddr, auth string, info func() NodeInfo) *KeepaliveSender {
+	return &KeepaliveSender{
+		ConsoleAddr: consoleAddr,
+		Auth:        auth,
+		Client:      &http.Client{Timeout: 5 * time.Second},
+		Info:        info,
+		stop:        make(chan struct{}),
+	}
+}
+
+// Start begins POSTing a keepalive immediately and then every
+// KeepaliveInterval, until Stop is called.
+func (k *KeepaliveSender) Start() {
+	go func() {
+		k.send()
+		ticker := time.NewTicker(KeepaliveInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				k.send()
+			case <-k.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends the keepalive loop.
+func (k *KeepaliveSender) Stop() {
+	close(k.stop)
+}
+
+func (k *KeepaliveSender) send() {
+	body, err := json.Marshal(k.Info())
+	if err != nil {
+		return
+	}
+
+	req, err := http.NewRequest(http.MethodPost, k.ConsoleAddr+"/api/cluster/keepalive", bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if k.Auth != "" {
+		req.Header.Set("Authorization", k.Auth)
+	}
+
+	resp, err := k.Client.Do(req)
+	if err != nil {
+		log.Printf("[Cluster] keepalive to %s failed: %v", k.ConsoleAddr, err)
+		return
+	}
+	resp.Body.Close()
+}
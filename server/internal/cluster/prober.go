@@ -0,0 +1,119 @@
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// CapabilityProbeInterval is how often the console probes each live
+// node's /api/health and /api/capabilities. It runs independently of
+// KeepaliveInterval: keepalive is a node pushing "I'm alive" to the
+// console, while probing is the console pulling "what do you actually
+// support, and can you still answer right now" from each node it already
+// knows about via the Registry.
+const CapabilityProbeInterval = 15 * time.Second
+
+const capabilityProbeTimeout = 3 * time.Second
+
+// CapabilityProber periodically probes every node a Registry currently
+// considers live, caching each one's reachability and NodeCapabilities on
+// that Registry so the Aggregator can skip unhealthy nodes and downgrade
+// a query per node instead of firing every request at every node and
+// hoping for the best.
+type CapabilityProber struct {
+	Registry *Registry
+	Client   *http.Client
+
+	stop chan struct{}
+}
+
+// NewCapabilityProber creates a prober that records into registry.
+func NewCapabilityProber(registry *Registry) *CapabilityProber {
+	return &CapabilityProber{
+		Registry: registry,
+		Client:   &http.Client{Timeout: capabilityProbeTimeout},
+		stop:     make(chan struct{}),
+	}
+}
+
+// Start probes every live node immediately and then every
+// CapabilityProbeInterval, until Stop is called.
+func (p *CapabilityProber) Start() {
+	go func() {
+		p.probeAll()
+		ticker := time.NewTicker(CapabilityProbeInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.probeAll()
+			case <-p.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends the probe loop.
+func (p *CapabilityProber) Stop() {
+	close(p.stop)
+}
+
+func (p *CapabilityProber) probeAll() {
+	addrs, _ := p.Registry.LiveAddrs()
+
+	var wg sync.WaitGroup
+	for _, addr := range addrs {
+		wg.Add(1)
+		go func(addr string) {
+			defer wg.Done()
+			p.probeOne(addr)
+		}(addr)
+	}
+	wg.Wait()
+}
+
+// probeOne checks addr's health and capabilities and records the result,
+// marking addr unhealthy if either call fails - a node that can't serve
+// its own /api/capabilities isn't one the Aggregator should trust to
+// serve /api/search either.
+func (p *CapabilityProber) probeOne(addr string) {
+	healthy := p.checkHealth(addr)
+
+	caps, err := p.fetchCapabilities(addr)
+	if err != nil {
+		healthy = false
+	}
+
+	p.Registry.RecordProbe(addr, caps, healthy)
+}
+
+func (p *CapabilityProber) checkHealth(addr string) bool {
+	resp, err := p.Client.Get(addr + "/api/health")
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+	return resp.StatusCode == http.StatusOK
+}
+
+func (p *CapabilityProber) fetchCapabilities(addr string) (NodeCapabilities, error) {
+	resp, err := p.Client.Get(addr + "/api/capabilities")
+	if err != nil {
+		return NodeCapabilities{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return NodeCapabilities{}, fmt.Errorf("node %s returned status %d for capabilities", addr, resp.StatusCode)
+	}
+
+	var caps NodeCapabilities
+	if err := json.NewDecoder(resp.Body).Decode(&caps); err != nil {
+		return NodeCapabilities{}, err
+	}
+	return caps, nil
+}
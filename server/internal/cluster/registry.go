@@ -0,0 +1,181 @@
+package cluster
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// NodeHealth is how reachable a node has been over its recent keepalives.
+type NodeHealth string
+
+const (
+	NodeHealthy  NodeHealth = "healthy"
+	NodeDegraded NodeHealth = "degraded"
+	NodeDead     NodeHealth = "dead"
+)
+
+const (
+	// KeepaliveInterval is how often an ingester is expected to POST
+	// /api/cluster/keepalive. Health is derived from how many of these
+	// intervals have elapsed since a node's last heartbeat.
+	KeepaliveInterval = 5 * time.Second
+
+	degradedAfterMissed = 3
+	deadAfterMissed     = 10
+)
+
+// NodeInfo is the keepalive payload an ingester POSTs to the console.
+type NodeInfo struct {
+	NodeID      string  `json:"node_id"`
+	Addr        string  `json:"addr"`
+	DiskFree    int64   `json:"disk_free"`
+	IngestRate  float64 `json:"ingest_rate"`
+	OldestWALTs int64   `json:"oldest_wal_ts"`
+	Version     string  `json:"version"`
+}
+
+// NodeStatus is a node's last-reported NodeInfo plus the registry's
+// current view of its health, returned by GET /api/cluster/nodes.
+// Capabilities and Probed reflect the CapabilityProber's most recent
+// active check of this node, not anything it self-reported via
+// keepalive; Probed is the zero time if no probe has completed yet.
+type NodeStatus struct {
+	NodeInfo
+	Health       NodeHealth       `json:"health"`
+	LastSeen     time.Time        `json:"last_seen"`
+	Capabilities NodeCapabilities `json:"capabilities"`
+	ProbeHealthy bool             `json:"probe_healthy"`
+	Probed       time.Time        `json:"probed,omitempty"`
+}
+
+// Registry tracks every ingester's most recent keepalive and derives its
+// health from how long it's been since that node last reported in. The
+// Console node owns one; ingester nodes don't.
+type Registry struct {
+	mu     sync.RWMutex
+	nodes  map[string]*nodeEntry
+	probes map[string]probeStatus // keyed by node addr, populated by CapabilityProber
+}
+
+type nodeEntry struct {
+	info     NodeInfo
+	lastSeen time.Time
+}
+
+// probeStatus is a node addr's most recent active health/capability
+// check, as opposed to nodeEntry which is derived from that node's own
+// keepalive pushes. A node can be keepalive-Healthy yet probe-unhealthy
+// (e.g. it's heartbeating but its query path is wedged), so the
+// Aggregator checks both before dispatching to it.
+type probeStatus struct {
+	caps    NodeCapabilities
+	healthy bool
+	probed  time.Time
+}
+
+// NewRegistry creates an empty node registry.
+func NewRegistry() *Registry {
+	return &Registry{nodes: make(map[string]*nodeEntry)}
+}
+
+// Keepalive records a heartbeat from info.NodeID, registering it if this
+// is the first one seen.
+func (r *Registry) Keepalive(info NodeInfo) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nodes[info.NodeID] = &nodeEntry{info: info, lastSeen: time.Now()}
+}
+
+func (e *nodeEntry) health() NodeHealth {
+	missed := int(time.Since(e.lastSeen) / KeepaliveInterval)
+	switch {
+	case missed >= deadAfterMissed:
+		return NodeDead
+	case missed >= degradedAfterMissed:
+		return NodeDegraded
+	default:
+		return NodeHealthy
+	}
+}
+
+// Nodes returns every known node's current status, sorted by node ID so
+// callers (the /api/cluster/nodes endpoint, tests) get a stable order.
+func (r *Registry) Nodes() []NodeStatus {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	statuses := make([]NodeStatus, 0, len(r.nodes))
+	for _, e := range r.nodes {
+		status := NodeStatus{NodeInfo: e.info, Health: e.health(), LastSeen: e.lastSeen, ProbeHealthy: true}
+		if p, ok := r.probes[e.info.Addr]; ok {
+			status.Capabilities = p.caps
+			status.ProbeHealthy = p.healthy
+			status.Probed = p.probed
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].NodeID < statuses[j].NodeID })
+	return statuses
+}
+
+// RecordProbe caches addr's most recently probed NodeCapabilities and
+// reachability. It's keyed by addr rather than NodeID since a probe only
+// knows the addr it dialed (from LiveAddrs), not the NodeID a keepalive
+// separately reports for the same node.
+func (r *Registry) RecordProbe(addr string, caps NodeCapabilities, healthy bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.probes == nil {
+		r.probes = make(map[string]probeStatus)
+	}
+	r.probes[addr] = probeStatus{caps: caps, healthy: healthy, probed: time.Now()}
+}
+
+// Capabilities returns addr's most recently probed NodeCapabilities, and
+// whether a probe has completed for it yet.
+func (r *Registry) Capabilities(addr string) (NodeCapabilities, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	p, ok := r.probes[addr]
+	if !ok {
+		return NodeCapabilities{}, false
+	}
+	return p.caps, true
+}
+
+// ProbeHealthy reports whether addr's most recent active health probe
+// succeeded. An addr that hasn't been probed yet (the prober hasn't run,
+// or isn't wired up at all) is assumed healthy, so the Aggregator falls
+// back to dispatching blindly rather than excluding every node.
+func (r *Registry) ProbeHealthy(addr string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	p, ok := r.probes[addr]
+	if !ok {
+		return true
+	}
+	return p.healthy
+}
+
+// LiveAddrs returns the addrs of every node that isn't Dead, and which of
+// those are currently Degraded. The aggregator fans out to all of them
+// but treats a Degraded node's failure as an expected partial result
+// rather than a surprising one.
+func (r *Registry) LiveAddrs() (addrs []string, degraded map[string]bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	degraded = make(map[string]bool)
+	for _, e := range r.nodes {
+		h := e.health()
+		if h == NodeDead {
+			continue
+		}
+		addrs = append(addrs, e.info.Addr)
+		if h == NodeDegraded {
+			degraded[e.info.Addr] = true
+		}
+	}
+	return addrs, degraded
+}
@@ -0,0 +1,207 @@
+package cluster
+
+import (
+	"bufio"
+	"container/heap"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+
+	"github.com/coffersTech/nanolog/server/internal/engine"
+)
+
+// streamRowBuffer is how many decoded rows SearchStream lets one node's
+// reader goroutine get ahead of the merge loop before it blocks. It only
+// needs to smooth out scheduling jitter between nodes, not buffer a
+// meaningful fraction of a result set - that's the whole point of
+// streaming instead of collecting every node's rows up front.
+const streamRowBuffer = 16
+
+// SearchStreamResult is delivered once on the channel SearchStream
+// returns, after its row channel closes. It carries the same
+// partial-result bookkeeping Search returns plus a Cursor the caller can
+// pass back via QueryParams.Cursor to resume.
+type SearchStreamResult struct {
+	Fanout FanoutResult
+	Cursor Cursor
+}
+
+// mergeItem is one node's next unread row, ordered into a max-heap by
+// Timestamp so the node with the newest next row is always popped first
+// - the same Timestamp DESC order Search returns, just produced
+// incrementally instead of requiring every node's full result up front.
+type mergeItem struct {
+	row  engine.LogRow
+	addr string
+}
+
+type mergeHeap []mergeItem
+
+func (h mergeHeap) Len() int           { return len(h) }
+func (h mergeHeap) Less(i, j int) bool { return h[i].row.Timestamp > h[j].row.Timestamp }
+func (h mergeHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *mergeHeap) Push(x interface{}) { *h = append(*h, x.(mergeItem)) }
+
+func (h *mergeHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}
+
+// SearchStream is Search's streaming counterpart: instead of collecting
+// every node's full result into memory before sorting and truncating to
+// Limit, it opens an NDJSON connection to each live node's
+// /api/search/stream, holds one buffered row per node, and k-way merges
+// them via a max-heap keyed by timestamp, stopping as soon as Limit rows
+// have been emitted or every node's stream has drained. Memory use is
+// O(live nodes), not O(rows matched across the cluster).
+func (a *Aggregator) SearchStream(ctx context.Context, params QueryParams) (<-chan engine.LogRow, <-chan SearchStreamResult, error) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	out := make(chan engine.LogRow, streamRowBuffer)
+	done := make(chan SearchStreamResult, 1)
+
+	cursor := DecodeCursor(params.Cursor)
+	targets := a.dispatchTargets()
+
+	live := make(map[string]chan engine.LogRow, len(targets))
+	var result FanoutResult
+	var resultMu sync.Mutex
+
+	var wg sync.WaitGroup
+	for _, target := range targets {
+		ch := make(chan engine.LogRow, streamRowBuffer)
+		live[target.Addr] = ch
+
+		wg.Add(1)
+		go func(target dispatchTarget, ch chan<- engine.LogRow) {
+			defer wg.Done()
+			defer close(ch)
+
+			nodeCtx, cancel := context.WithTimeout(ctx, target.timeout())
+			defer cancel()
+
+			if err := a.streamNodeSearch(nodeCtx, target, params, cursor, ch); err != nil {
+				resultMu.Lock()
+				result.fail(target.Addr, err.Error())
+				resultMu.Unlock()
+			}
+		}(target, ch)
+	}
+
+	go func() {
+		defer close(out)
+
+		h := &mergeHeap{}
+		heap.Init(h)
+		lastEmitted := make(map[string]int64)
+
+		pull := func(addr string) {
+			ch, ok := live[addr]
+			if !ok {
+				return
+			}
+			if row, ok := <-ch; ok {
+				heap.Push(h, mergeItem{row: row, addr: addr})
+				return
+			}
+			delete(live, addr)
+		}
+		for addr := range live {
+			pull(addr)
+		}
+
+		emitted := 0
+	mergeLoop:
+		for h.Len() > 0 && (params.Limit <= 0 || emitted < params.Limit) {
+			item := heap.Pop(h).(mergeItem)
+			select {
+			case out <- item.row:
+			case <-ctx.Done():
+				break mergeLoop
+			}
+			lastEmitted[item.addr] = item.row.Timestamp
+			emitted++
+			pull(item.addr)
+		}
+
+		cancel() // Limit reached, or the loop above bailed: stop every still-streaming node.
+		wg.Wait()
+
+		resultMu.Lock()
+		result.sort()
+		resultMu.Unlock()
+		done <- SearchStreamResult{Fanout: result, Cursor: lastEmitted}
+		close(done)
+	}()
+
+	return out, done, nil
+}
+
+// streamNodeSearch opens target's /api/search/stream and decodes each
+// NDJSON row onto ch until the connection closes or ctx is done. A
+// connect failure or non-200 status is returned so the caller can record
+// it on the FanoutResult; an error reading the body mid-stream is
+// swallowed the same way streamNodeTail treats a dropped SSE connection
+// - whatever rows already reached ch are still valid, and the merge loop
+// doesn't need a reason for fewer rows arriving than a fully-available
+// node would have sent.
+func (a *Aggregator) streamNodeSearch(ctx context.Context, target dispatchTarget, params QueryParams, cursor Cursor, ch chan<- engine.LogRow) error {
+	rawQuery := downgradeQuery(params.RawQuery, target.Caps)
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		values = url.Values{}
+	}
+	if ts, ok := cursor[target.Addr]; ok {
+		values.Set("max_ts", strconv.FormatInt(ts-1, 10))
+	}
+	if params.Limit > 0 {
+		values.Set("limit", strconv.Itoa(params.Limit))
+	}
+
+	reqURL := fmt.Sprintf("%s/api/search/stream?%s", target.Addr, values.Encode())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+	if err != nil {
+		return err
+	}
+	if params.Auth != "" {
+		req.Header.Set("Authorization", params.Auth)
+	}
+
+	client := a.StreamClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var row engine.LogRow
+		if err := json.Unmarshal(scanner.Bytes(), &row); err != nil {
+			continue
+		}
+		select {
+		case ch <- row:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package cluster
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coffersTech/nanolog/server/internal/engine"
+)
+
+// tailReorderWindow bounds how long Tail buffers a row from one node
+// before emitting it, so rows from nodes with slightly skewed clocks
+// still come out roughly timestamp-ordered instead of in whatever order
+// each node's SSE connection happened to deliver them.
+const tailReorderWindow = 3 * time.Second
+
+// Tail opens an SSE connection to every live node's /api/tail and merges
+// them into a single channel, holding each row for up to
+// tailReorderWindow before emitting it (sorted by timestamp among
+// whatever arrived within the window) to smooth over clock skew between
+// nodes. The returned cancel func closes every upstream connection and
+// the output channel.
+func (a *Aggregator) Tail(ctx context.Context, params QueryParams) (<-chan engine.LogRow, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	out := make(chan engine.LogRow, 256)
+
+	var mu sync.Mutex
+	var buf []engine.LogRow
+
+	var wg sync.WaitGroup
+	for _, node := range a.liveNodes() {
+		wg.Add(1)
+		go func(nodeURL string) {
+			defer wg.Done()
+			a.streamNodeTail(ctx, nodeURL, params, func(row engine.LogRow) {
+				mu.Lock()
+				buf = append(buf, row)
+				mu.Unlock()
+			})
+		}(node)
+	}
+
+	go func() {
+		ticker := time.NewTicker(tailReorderWindow / 4)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				flushReadyRows(&mu, &buf, out, time.Now().Add(-tailReorderWindow).UnixNano())
+			case <-ctx.Done():
+				wg.Wait()
+				flushReadyRows(&mu, &buf, out, math.MaxInt64) // flush everything left
+				close(out)
+				return
+			}
+		}
+	}()
+
+	return out, cancel
+}
+
+// flushReadyRows emits every buffered row with Timestamp <= threshold,
+// oldest first, leaving the rest buffered for the next tick.
+func flushReadyRows(mu *sync.Mutex, buf *[]engine.LogRow, out chan<- engine.LogRow, threshold int64) {
+	mu.Lock()
+	sort.Slice(*buf, func(i, j int) bool { return (*buf)[i].Timestamp < (*buf)[j].Timestamp })
+	i := 0
+	for ; i < len(*buf); i++ {
+		if (*buf)[i].Timestamp > threshold {
+			break
+		}
+	}
+	ready := append([]engine.LogRow(nil), (*buf)[:i]...)
+	*buf = (*buf)[i:]
+	mu.Unlock()
+
+	for _, row := range ready {
+		select {
+		case out <- row:
+		default:
+			// Slow consumer; drop rather than block every upstream node.
+		}
+	}
+}
+
+// streamNodeTail opens an SSE GET to nodeURL's /api/tail and calls emit
+// for every row decoded off it, until ctx is canceled or the connection
+// drops.
+func (a *Aggregator) streamNodeTail(ctx context.Context, nodeURL string, params QueryParams, emit func(engine.LogRow)) {
+	url := fmt.Sprintf("%s/api/tail?%s", nodeURL, params.RawQuery)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if params.Auth != "" {
+		req.Header.Set("Authorization", params.Auth)
+	}
+	if params.LastEventID != "" {
+		req.Header.Set("Last-Event-ID", params.LastEventID)
+	}
+
+	client := a.StreamClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		log.Printf("[Aggregator] tail: failed to connect to %s: %v", nodeURL, err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("[Aggregator] tail: node %s returned status %d", nodeURL, resp.StatusCode)
+		return
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+		var row engine.LogRow
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &row); err != nil {
+			continue
+		}
+		emit(row)
+	}
+}
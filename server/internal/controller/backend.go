@@ -0,0 +1,27 @@
+package controller
+
+import "context"
+
+// MetaBackend abstracts where the encrypted MetaData blob is persisted, so
+// a cluster of nanolog nodes can share users, API tokens, and retention
+// config without a shared filesystem. Store only ever hands a MetaBackend
+// already-encrypted bytes and never touches plaintext through it.
+type MetaBackend interface {
+	// Load returns the current encrypted metadata blob, or (nil, nil) if
+	// nothing has ever been written.
+	Load(ctx context.Context) ([]byte, error)
+
+	// PutAll overwrites the stored blob with encrypted.
+	PutAll(ctx context.Context, encrypted []byte) error
+
+	// Watch streams the encrypted blob every time it changes, so
+	// AuthMiddleware's view of tokens/users can catch up within seconds
+	// instead of only on this node's next local write. Implementations
+	// that have no native change notification may return a nil channel;
+	// callers must treat that as "no live updates available" rather than
+	// an error.
+	Watch(ctx context.Context) (<-chan []byte, error)
+
+	// Close releases any underlying connection.
+	Close() error
+}
@@ -0,0 +1,95 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+var (
+	boltBucketName = []byte("nanolog_meta")
+	boltDataKey    = []byte("data")
+)
+
+// boltPollInterval is how often boltBackend.Watch re-reads the file to
+// notice a change made by another process sharing it over a network
+// filesystem. boltdb has no native change notification, unlike etcd's
+// Watch API, so this is the best available without adding a coordinator.
+const boltPollInterval = 2 * time.Second
+
+// boltBackend stores the encrypted MetaData blob as a single key in a
+// boltdb file, for single-binary HA deployments that want shared metadata
+// without standing up etcd or Consul.
+type boltBackend struct {
+	db *bbolt.DB
+}
+
+func newBoltBackend(path string) (*boltBackend, error) {
+	db, err := bbolt.Open(path, 0600, &bbolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(boltBucketName)
+		return err
+	}); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &boltBackend{db: db}, nil
+}
+
+func (b *boltBackend) Load(ctx context.Context) ([]byte, error) {
+	var data []byte
+	err := b.db.View(func(tx *bbolt.Tx) error {
+		if v := tx.Bucket(boltBucketName).Get(boltDataKey); v != nil {
+			data = append([]byte(nil), v...)
+		}
+		return nil
+	})
+	return data, err
+}
+
+func (b *boltBackend) PutAll(ctx context.Context, encrypted []byte) error {
+	return b.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(boltBucketName).Put(boltDataKey, encrypted)
+	})
+}
+
+func (b *boltBackend) Watch(ctx context.Context) (<-chan []byte, error) {
+	ch := make(chan []byte, 1)
+
+	go func() {
+		defer close(ch)
+
+		last, _ := b.Load(ctx)
+		ticker := time.NewTicker(boltPollInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				cur, err := b.Load(ctx)
+				if err != nil || bytes.Equal(cur, last) {
+					continue
+				}
+				last = cur
+				select {
+				case ch <- cur:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
+func (b *boltBackend) Close() error {
+	return b.db.Close()
+}
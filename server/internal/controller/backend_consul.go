@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+
+	consulapi "github.com/hashicorp/consul/api"
+)
+
+// consulBackend stores the encrypted MetaData blob under a single Consul
+// KV key. Consul has no persistent watch stream like etcd; instead it
+// exposes blocking queries, where Get only returns once the key's
+// ModifyIndex has advanced past WaitIndex. Watch drives that loop
+// continuously so it behaves like the other backends' push-based Watch.
+type consulBackend struct {
+	kv  *consulapi.KV
+	key string
+}
+
+func newConsulBackend(addr, key string) (*consulBackend, error) {
+	cli, err := consulapi.NewClient(&consulapi.Config{Address: addr})
+	if err != nil {
+		return nil, err
+	}
+	return &consulBackend{kv: cli.KV(), key: key}, nil
+}
+
+func (b *consulBackend) Load(ctx context.Context) ([]byte, error) {
+	pair, _, err := b.kv.Get(b.key, nil)
+	if err != nil {
+		return nil, err
+	}
+	if pair == nil {
+		return nil, nil
+	}
+	return pair.Value, nil
+}
+
+func (b *consulBackend) PutAll(ctx context.Context, encrypted []byte) error {
+	_, err := b.kv.Put(&consulapi.KVPair{Key: b.key, Value: encrypted}, nil)
+	return err
+}
+
+func (b *consulBackend) Watch(ctx context.Context) (<-chan []byte, error) {
+	ch := make(chan []byte, 1)
+
+	go func() {
+		defer close(ch)
+
+		var lastIndex uint64
+		var last []byte
+		for ctx.Err() == nil {
+			pair, meta, err := b.kv.Get(b.key, &consulapi.QueryOptions{WaitIndex: lastIndex})
+			if err != nil {
+				continue
+			}
+			if meta != nil {
+				lastIndex = meta.LastIndex
+			}
+
+			var cur []byte
+			if pair != nil {
+				cur = pair.Value
+			}
+			if bytes.Equal(cur, last) {
+				continue
+			}
+			last = cur
+
+			select {
+			case ch <- cur:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
+func (b *consulBackend) Close() error {
+	return nil
+}
@@ -0,0 +1,67 @@
+package controller
+
+import (
+	"context"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// etcdBackend stores the encrypted MetaData blob under a single etcd key.
+// Its Watch is the backend that best satisfies "a token revoked on node A
+// is honored on node B within seconds", since etcd pushes key changes to
+// watchers over a long-lived gRPC stream rather than polling.
+type etcdBackend struct {
+	cli *clientv3.Client
+	key string
+}
+
+func newEtcdBackend(endpoints []string, key string) (*etcdBackend, error) {
+	cli, err := clientv3.New(clientv3.Config{Endpoints: endpoints})
+	if err != nil {
+		return nil, err
+	}
+	return &etcdBackend{cli: cli, key: key}, nil
+}
+
+func (b *etcdBackend) Load(ctx context.Context) ([]byte, error) {
+	resp, err := b.cli.Get(ctx, b.key)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, nil
+	}
+	return resp.Kvs[0].Value, nil
+}
+
+func (b *etcdBackend) PutAll(ctx context.Context, encrypted []byte) error {
+	_, err := b.cli.Put(ctx, b.key, string(encrypted))
+	return err
+}
+
+func (b *etcdBackend) Watch(ctx context.Context) (<-chan []byte, error) {
+	ch := make(chan []byte, 1)
+	watchCh := b.cli.Watch(ctx, b.key)
+
+	go func() {
+		defer close(ch)
+		for resp := range watchCh {
+			for _, ev := range resp.Events {
+				if ev.Type != clientv3.EventTypePut {
+					continue
+				}
+				select {
+				case ch <- ev.Kv.Value:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
+func (b *etcdBackend) Close() error {
+	return b.cli.Close()
+}
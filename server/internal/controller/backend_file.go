@@ -0,0 +1,37 @@
+package controller
+
+import (
+	"context"
+	"os"
+)
+
+// fileBackend is the original single-node MetaBackend: one encrypted file
+// on local disk. It has no way to notice another process rewriting the
+// file, so Watch always returns a nil channel.
+type fileBackend struct {
+	path string
+}
+
+func newFileBackend(path string) *fileBackend {
+	return &fileBackend{path: path}
+}
+
+func (b *fileBackend) Load(ctx context.Context) ([]byte, error) {
+	data, err := os.ReadFile(b.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return data, err
+}
+
+func (b *fileBackend) PutAll(ctx context.Context, encrypted []byte) error {
+	return os.WriteFile(b.path, encrypted, 0600)
+}
+
+func (b *fileBackend) Watch(ctx context.Context) (<-chan []byte, error) {
+	return nil, nil
+}
+
+func (b *fileBackend) Close() error {
+	return nil
+}
@@ -1,8 +1,12 @@
 package controller
 
 import (
+	"context"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"hash/fnv"
 	"os"
 	"strings"
 	"sync"
@@ -12,6 +16,11 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+// ErrConfigConflict is returned by DoLockedAction when the caller's
+// fingerprint doesn't match the store's current one, meaning a
+// concurrent writer changed Config first.
+var ErrConfigConflict = errors.New("config changed concurrently")
+
 // User represents a system user profile.
 type User struct {
 	Username     string `json:"username"`
@@ -27,11 +36,106 @@ type APIToken struct {
 	Token     string `json:"token"` // Sk-xxxxxx
 	Type      string `json:"type"`  // "write" (SDK), "read" (Grafana)
 	CreatedBy string `json:"created_by"`
+
+	// Scopes restricts what the token may do, e.g. "ingest:write",
+	// "logs:read", "admin:users". "*" grants every scope. Empty means the
+	// token grants nothing beyond authentication - AuthMiddleware still
+	// enforces a scope for every route that requires one.
+	Scopes []string `json:"scopes,omitempty"`
+	// AllowedServices, when non-empty, restricts ingest through this
+	// token to these service names.
+	AllowedServices []string `json:"allowed_services,omitempty"`
+	// AllowedHosts, when non-empty, restricts use of this token to
+	// requests from a source IP matching one of these CIDRs.
+	AllowedHosts []string `json:"allowed_hosts,omitempty"`
+	// RateLimitRPS caps requests-per-second for this token via a
+	// per-token bucket. Zero means unlimited.
+	RateLimitRPS int `json:"rate_limit_rps,omitempty"`
+	// ExpiresAt is a Unix timestamp after which the token is rejected.
+	// Zero means it never expires.
+	ExpiresAt int64 `json:"expires_at,omitempty"`
+	// LastUsedAt is a Unix timestamp updated (in memory only - see
+	// Store.TouchTokenUsage) on every successful authentication.
+	LastUsedAt int64 `json:"last_used_at,omitempty"`
 }
 
 // Config holds system-wide settings.
 type Config struct {
-	Retention string `json:"retention"` // e.g. "168h"
+	Retention string     `json:"retention"` // e.g. "168h"
+	OIDC      OIDCConfig `json:"oidc"`
+
+	// DefaultLogConfig is the registry's fallback SDK config, applied to
+	// any instance whose service has no entry in ServiceConfig.
+	DefaultLogConfig ServiceLogConfig `json:"default_log_config"`
+	// ServiceConfig maps a service name to the dynamic logging config
+	// pushed to every SDK instance of that service, keyed the same way
+	// an operator refers to the service in the UI. Persisted here (and
+	// not in registry.Store, which only holds ephemeral instance
+	// registrations) so a level change survives a restart.
+	ServiceConfig map[string]ServiceLogConfig `json:"service_config,omitempty"`
+
+	// RetentionPolicies are named overrides of Retention for files
+	// matching a predicate, e.g. a shorter age limit for a noisy
+	// service. Persisted here so they survive a restart the same way
+	// Retention does; QueryEngine.ApplyConfig compiles them into
+	// engine.RetentionPolicy and installs them via SetPolicies whenever
+	// this Config changes.
+	RetentionPolicies []RetentionPolicy `json:"retention_policies,omitempty"`
+}
+
+// RetentionPolicy is the wire/storage form of engine.RetentionPolicy. It
+// lives here, rather than being reused directly from the engine package,
+// because engine already imports controller (for ApplyConfig) and Go
+// doesn't allow the reverse.
+type RetentionPolicy struct {
+	Name string `json:"name"`
+	// MaxAge is a time.ParseDuration string, e.g. "720h".
+	MaxAge string `json:"max_age"`
+	// Predicate is a NanoQL query string restricting which files this
+	// policy applies to, e.g. "service:checkout". Empty matches every
+	// file.
+	Predicate string `json:"predicate,omitempty"`
+}
+
+// ServiceLogConfig is the dynamic logging configuration the registry
+// pushes to SDK instances: the level and sample rate to run at, plus any
+// tail-sampling rules that override the sample rate for records matching
+// a specific shape.
+type ServiceLogConfig struct {
+	Level             string             `json:"level"`
+	SampleRate        int                `json:"sample_rate"`
+	TailSamplingRules []TailSamplingRule `json:"tail_sampling_rules,omitempty"`
+}
+
+// TailSamplingRule raises (or lowers) the effective sample rate for
+// records whose Field ("level", "service", or "message") contains Match,
+// e.g. always keeping every record with "panic" in its message even
+// when the service's default SampleRate is much lower.
+type TailSamplingRule struct {
+	Field      string `json:"field"`
+	Match      string `json:"match"`
+	SampleRate int    `json:"sample_rate"`
+}
+
+// OIDCConfig configures single sign-on against an external OIDC provider
+// (Okta, Auth0, Keycloak, ...). An empty IssuerURL disables OIDC: the
+// login/callback handlers return 404 and AuthMiddleware never attempts
+// token verification.
+type OIDCConfig struct {
+	IssuerURL    string `json:"issuer_url"`
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	RedirectURL  string `json:"redirect_url"`
+
+	// RoleClaim names the ID token claim used to resolve a nanolog role,
+	// e.g. "groups" or "email".
+	RoleClaim string `json:"role_claim"`
+	// RoleMappings maps one value of RoleClaim (a group name, an email
+	// address, ...) to a nanolog role ("super_admin", "admin", "viewer").
+	RoleMappings map[string]string `json:"role_mappings"`
+	// DefaultRole is granted when RoleClaim's value has no entry in
+	// RoleMappings. Empty means the login is refused instead.
+	DefaultRole string `json:"default_role"`
 }
 
 // MetaData is the top-level container for system metadata.
@@ -42,41 +146,54 @@ type MetaData struct {
 	Config      Config     `json:"config"`
 }
 
-// Store handles the persistence and in-memory management of MetaData.
+// Store handles the persistence and in-memory management of MetaData. It
+// talks to its MetaBackend only in encrypted bytes, so swapping the
+// backend (file, boltdb, etcd, Consul) never touches the encryption or
+// the in-memory mutation logic below.
 type Store struct {
-	filePath string
-	mu       sync.RWMutex
-	data     *MetaData
+	backend MetaBackend
+	mu      sync.RWMutex
+	data    *MetaData
+
+	configSubsMu sync.Mutex
+	configSubs   []chan Config
 }
 
-// NewStore creates a new metadata store.
+// NewStore creates a metadata store backed by a single encrypted file,
+// the original single-node deployment mode.
 func NewStore(filePath string) *Store {
+	return NewStoreWithBackend(newFileBackend(filePath))
+}
+
+// NewStoreWithBackend creates a metadata store backed by b, for
+// multi-node deployments that share users, API tokens, and retention
+// config through boltdb, etcd, or Consul instead of a local file.
+func NewStoreWithBackend(b MetaBackend) *Store {
 	return &Store{
-		filePath: filePath,
+		backend: b,
 		data: &MetaData{
 			Users:  make([]User, 0),
 			Tokens: make([]APIToken, 0),
-			Config: Config{Retention: "168h"},
+			Config: Config{
+				Retention:        "168h",
+				DefaultLogConfig: ServiceLogConfig{Level: "INFO", SampleRate: 100},
+			},
 		},
 	}
 }
 
-// Load reads metadata from disk.
+// Load reads metadata from the backend.
 func (s *Store) Load() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if _, err := os.Stat(s.filePath); os.IsNotExist(err) {
-		s.data.Initialized = false
-		return nil
-	}
-
-	encryptedData, err := os.ReadFile(s.filePath)
+	encryptedData, err := s.backend.Load(context.Background())
 	if err != nil {
 		return err
 	}
 
 	if len(encryptedData) == 0 {
+		s.data.Initialized = false
 		return nil
 	}
 
@@ -89,14 +206,14 @@ func (s *Store) Load() error {
 	return json.Unmarshal(decrypted, s.data)
 }
 
-// Save writes metadata to disk.
+// Save writes metadata to the backend.
 func (s *Store) Save() error {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	return s.saveLocked()
 }
 
-// saveLocked writes metadata to disk with encryption.
+// saveLocked encrypts the in-memory metadata and hands it to the backend.
 func (s *Store) saveLocked() error {
 	jsonData, err := json.Marshal(s.data)
 	if err != nil {
@@ -108,7 +225,55 @@ func (s *Store) saveLocked() error {
 		return err
 	}
 
-	return os.WriteFile(s.filePath, encrypted, 0600)
+	return s.backend.PutAll(context.Background(), encrypted)
+}
+
+// Watch streams MetaData whenever the backend reports the stored blob
+// changed - typically another node writing a new token or revoking one.
+// On backends with a native watch (etcd, and polling for boltdb/Consul),
+// this is what lets AuthMiddleware honor a revocation within seconds
+// instead of only on this node's own next write. The file backend can't
+// watch at all, so its callers only ever see the channel close. The
+// returned channel is closed when ctx is done or the backend can't watch.
+func (s *Store) Watch(ctx context.Context) <-chan MetaData {
+	out := make(chan MetaData, 1)
+
+	raw, err := s.backend.Watch(ctx)
+	if err != nil || raw == nil {
+		close(out)
+		return out
+	}
+
+	go func() {
+		defer close(out)
+		for encrypted := range raw {
+			decrypted, err := security.Decrypt(encrypted)
+			if err != nil {
+				continue
+			}
+			var md MetaData
+			if err := json.Unmarshal(decrypted, &md); err != nil {
+				continue
+			}
+
+			s.mu.Lock()
+			s.data = &md
+			s.mu.Unlock()
+
+			select {
+			case out <- md:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out
+}
+
+// Close releases the backend's underlying connection.
+func (s *Store) Close() error {
+	return s.backend.Close()
 }
 
 // GetData returns a copy of the current metadata.
@@ -228,13 +393,160 @@ func (s *Store) GetTokenByValue(val string) (APIToken, bool) {
 	return APIToken{}, false
 }
 
-// UpdateConfig updates system configuration.
-func (s *Store) UpdateConfig(cfg Config) error {
+// TouchTokenUsage records that a token was just used, updating LastUsedAt
+// in memory without persisting to disk - unlike every other Store
+// mutation, this runs on every authenticated request, and encrypting and
+// rewriting the whole metadata file that often would be far too
+// expensive for what is ultimately an informational field. LastUsedAt is
+// therefore best-effort and reverts to its last-saved value across a
+// restart.
+func (s *Store) TouchTokenUsage(id string, ts int64) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	for i, t := range s.data.Tokens {
+		if t.ID == id {
+			s.data.Tokens[i].LastUsedAt = ts
+			return
+		}
+	}
+}
+
+// AddRetentionPolicy appends a new named retention policy to Config and
+// publishes the change, the same publish-on-success contract
+// DoLockedAction uses for other config edits - QueryEngine.WatchConfig
+// picks it up and calls ApplyConfig, so the new policy takes effect
+// without a restart.
+func (s *Store) AddRetentionPolicy(p RetentionPolicy) error {
+	s.mu.Lock()
+	for _, existing := range s.data.Config.RetentionPolicies {
+		if existing.Name == p.Name {
+			s.mu.Unlock()
+			return fmt.Errorf("retention policy %q already exists", p.Name)
+		}
+	}
+
+	s.data.Config.RetentionPolicies = append(s.data.Config.RetentionPolicies, p)
+	cfg := s.data.Config
+	if err := s.saveLocked(); err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	s.mu.Unlock()
+
+	s.publishConfig(cfg)
+	return nil
+}
+
+// DeleteRetentionPolicy removes a named retention policy, publishing the
+// change on success the same way AddRetentionPolicy does.
+func (s *Store) DeleteRetentionPolicy(name string) error {
+	s.mu.Lock()
+	policies := s.data.Config.RetentionPolicies
+	for i, p := range policies {
+		if p.Name != name {
+			continue
+		}
+
+		s.data.Config.RetentionPolicies = append(policies[:i:i], policies[i+1:]...)
+		cfg := s.data.Config
+		if err := s.saveLocked(); err != nil {
+			s.mu.Unlock()
+			return err
+		}
+		s.mu.Unlock()
+
+		s.publishConfig(cfg)
+		return nil
+	}
+	s.mu.Unlock()
+	return os.ErrNotExist
+}
+
+// Fingerprint returns a hash of the current Config, for use as an
+// ETag/If-Match value so concurrent admins editing config can't silently
+// clobber each other's changes.
+func (s *Store) Fingerprint() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return configFingerprint(s.data.Config)
+}
+
+func configFingerprint(cfg Config) string {
+	raw, _ := json.Marshal(cfg)
+	h := fnv.New64a()
+	h.Write(raw)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// DoLockedAction applies fn to a copy of the current Config and, if fn
+// succeeds, persists and publishes the result. If fingerprint is
+// non-empty and doesn't match Fingerprint(), it returns ErrConfigConflict
+// without calling fn at all, the same way an HTTP If-Match mismatch
+// returns 412/409 without applying the write — this is what lets two
+// admins editing config concurrently fail loudly instead of one silently
+// overwriting the other's change.
+func (s *Store) DoLockedAction(fingerprint string, fn func(cfg *Config) error) error {
+	s.mu.Lock()
+	if fingerprint != "" && fingerprint != configFingerprint(s.data.Config) {
+		s.mu.Unlock()
+		return ErrConfigConflict
+	}
+
+	cfg := s.data.Config
+	if err := fn(&cfg); err != nil {
+		s.mu.Unlock()
+		return err
+	}
+
 	s.data.Config = cfg
-	return s.saveLocked()
+	if err := s.saveLocked(); err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	s.mu.Unlock()
+
+	s.publishConfig(cfg)
+	return nil
+}
+
+// SubscribeConfig registers a subscriber for published Config updates,
+// mirroring MemTable.Subscribe: the channel is buffered and a subscriber
+// that falls behind has updates dropped rather than stalling a
+// concurrent config write. The returned func must be called when the
+// subscriber is done.
+func (s *Store) SubscribeConfig() (<-chan Config, func()) {
+	ch := make(chan Config, 4)
+
+	s.configSubsMu.Lock()
+	s.configSubs = append(s.configSubs, ch)
+	s.configSubsMu.Unlock()
+
+	unsubscribe := func() {
+		s.configSubsMu.Lock()
+		for i, c := range s.configSubs {
+			if c == ch {
+				s.configSubs = append(s.configSubs[:i], s.configSubs[i+1:]...)
+				break
+			}
+		}
+		s.configSubsMu.Unlock()
+		close(ch)
+	}
+	return ch, unsubscribe
+}
+
+func (s *Store) publishConfig(cfg Config) {
+	s.configSubsMu.Lock()
+	defer s.configSubsMu.Unlock()
+
+	for _, ch := range s.configSubs {
+		select {
+		case ch <- cfg:
+		default:
+			// Slow subscriber; drop rather than stall this write.
+		}
+	}
 }
 
 // UpdateUserPassword updates the password hash for a user.
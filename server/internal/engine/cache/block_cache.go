@@ -0,0 +1,141 @@
+// Package cache provides a sharded, byte-budgeted LRU for decompressed
+// .nano column blocks, plus a buffer pool for the bytes read off disk before
+// decompression. It mirrors goleveldb's cache.NewLRU / util.NewBufferPool
+// split: the LRU avoids re-reading and re-decompressing blocks across scans,
+// the pool avoids an allocation per block read.
+package cache
+
+import (
+	"container/list"
+	"sync"
+)
+
+// DefaultCapacity is the default total byte budget for a new BlockCache.
+const DefaultCapacity = 64 * 1024 * 1024 // 64 MiB
+
+const shardCount = 16
+
+// BlockCache caches decompressed column blocks keyed by (fileID, blockID).
+// fileID identifies the .nano file (callers typically derive it from a
+// storage.FileDesc); blockID identifies a block within that file (e.g. one
+// column). It is safe for concurrent use.
+type BlockCache struct {
+	shards [shardCount]*shard
+}
+
+type blockKey struct {
+	fileID  uint64
+	blockID uint64
+}
+
+type entry struct {
+	key      blockKey
+	value    []byte
+	skipSize int64
+}
+
+type shard struct {
+	mu       sync.Mutex
+	capacity int64
+	size     int64
+	ll       *list.List
+	index    map[blockKey]*list.Element
+	hits     int64
+	misses   int64
+}
+
+// Stats reports cumulative hit/miss counters and current occupancy.
+type Stats struct {
+	Hits      int64 `json:"hits"`
+	Misses    int64 `json:"misses"`
+	UsedBytes int64 `json:"used_bytes"`
+}
+
+// NewBlockCache creates a BlockCache with the given total byte budget spread
+// evenly across its shards. A capacity <= 0 falls back to DefaultCapacity.
+func NewBlockCache(capacity int64) *BlockCache {
+	if capacity <= 0 {
+		capacity = DefaultCapacity
+	}
+	perShard := capacity / shardCount
+	if perShard <= 0 {
+		perShard = 1
+	}
+
+	bc := &BlockCache{}
+	for i := range bc.shards {
+		bc.shards[i] = &shard{
+			capacity: perShard,
+			ll:       list.New(),
+			index:    make(map[blockKey]*list.Element),
+		}
+	}
+	return bc
+}
+
+func (bc *BlockCache) shardFor(fileID, blockID uint64) *shard {
+	h := fileID*31 + blockID
+	return bc.shards[h%shardCount]
+}
+
+// Get returns the cached block for (fileID, blockID) if present. Otherwise
+// it calls load, which must read and return the block along with skipSize —
+// the number of bytes load consumed from its underlying stream, so that on
+// a later cache hit the caller can skip over those same bytes without
+// re-reading them. On a miss, load has already consumed the bytes itself,
+// so the returned skipSize is 0 and irrelevant to the caller.
+func (bc *BlockCache) Get(fileID, blockID uint64, load func() (data []byte, skipSize int64, err error)) (data []byte, hit bool, skipSize int64, err error) {
+	s := bc.shardFor(fileID, blockID)
+	key := blockKey{fileID: fileID, blockID: blockID}
+
+	s.mu.Lock()
+	if el, ok := s.index[key]; ok {
+		s.ll.MoveToFront(el)
+		s.hits++
+		ent := el.Value.(*entry)
+		data, skipSize = ent.value, ent.skipSize
+		s.mu.Unlock()
+		return data, true, skipSize, nil
+	}
+	s.misses++
+	s.mu.Unlock()
+
+	data, consumed, err := load()
+	if err != nil {
+		return nil, false, 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if el, ok := s.index[key]; ok {
+		// Another caller populated it while we were loading; keep theirs.
+		return el.Value.(*entry).value, false, 0, nil
+	}
+	el := s.ll.PushFront(&entry{key: key, value: data, skipSize: consumed})
+	s.index[key] = el
+	s.size += int64(len(data))
+
+	for s.size > s.capacity && s.ll.Len() > 1 {
+		back := s.ll.Back()
+		ev := back.Value.(*entry)
+		s.size -= int64(len(ev.value))
+		delete(s.index, ev.key)
+		s.ll.Remove(back)
+	}
+
+	return data, false, 0, nil
+}
+
+// Stats returns cumulative hit/miss counters and current occupancy across
+// all shards.
+func (bc *BlockCache) Stats() Stats {
+	var st Stats
+	for _, s := range bc.shards {
+		s.mu.Lock()
+		st.Hits += s.hits
+		st.Misses += s.misses
+		st.UsedBytes += s.size
+		s.mu.Unlock()
+	}
+	return st
+}
@@ -0,0 +1,88 @@
+package cache
+
+import "testing"
+
+func TestBlockCacheHitAvoidsReload(t *testing.T) {
+	bc := NewBlockCache(DefaultCapacity)
+
+	loads := 0
+	load := func() ([]byte, int64, error) {
+		loads++
+		return []byte("block-data"), 10, nil
+	}
+
+	data, hit, _, err := bc.Get(1, 0, load)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if hit {
+		t.Fatal("expected first Get to be a miss")
+	}
+	if string(data) != "block-data" {
+		t.Fatalf("expected %q, got %q", "block-data", data)
+	}
+
+	data, hit, skip, err := bc.Get(1, 0, load)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if !hit {
+		t.Fatal("expected second Get to be a hit")
+	}
+	if skip != 10 {
+		t.Fatalf("expected skipSize 10, got %d", skip)
+	}
+	if string(data) != "block-data" {
+		t.Fatalf("expected %q, got %q", "block-data", data)
+	}
+
+	if loads != 1 {
+		t.Fatalf("expected load to run once, ran %d times", loads)
+	}
+
+	stats := bc.Stats()
+	if stats.Hits != 1 || stats.Misses != 1 {
+		t.Fatalf("expected 1 hit and 1 miss, got %+v", stats)
+	}
+}
+
+func TestBlockCacheDistinctKeys(t *testing.T) {
+	bc := NewBlockCache(DefaultCapacity)
+
+	if _, _, _, err := bc.Get(1, 0, func() ([]byte, int64, error) { return []byte("a"), 1, nil }); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	_, hit, _, err := bc.Get(1, 1, func() ([]byte, int64, error) { return []byte("b"), 1, nil })
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if hit {
+		t.Fatal("expected different blockID to miss")
+	}
+	_, hit, _, err = bc.Get(2, 0, func() ([]byte, int64, error) { return []byte("c"), 1, nil })
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if hit {
+		t.Fatal("expected different fileID to miss")
+	}
+}
+
+func TestBlockCacheEvictsUnderCapacity(t *testing.T) {
+	// A tiny total capacity, so a single fileID with many blocks must evict
+	// older entries to stay within budget regardless of sharding.
+	bc := NewBlockCache(int64(shardCount) * 4)
+
+	block := make([]byte, 16)
+	for blockID := uint64(0); blockID < 100; blockID++ {
+		id := blockID
+		if _, _, _, err := bc.Get(1, id, func() ([]byte, int64, error) { return block, 1, nil }); err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+	}
+
+	stats := bc.Stats()
+	if stats.UsedBytes > int64(shardCount)*4+int64(len(block))*shardCount {
+		t.Fatalf("expected eviction to bound occupancy, got %+v", stats)
+	}
+}
@@ -1,13 +1,10 @@
 package engine
 
 import (
-	"fmt"
 	"log"
-	"os"
-	"path/filepath"
-	"strconv"
-	"strings"
 	"time"
+
+	"github.com/coffersTech/nanolog/server/internal/engine/storage"
 )
 
 // RunCleaner periodically scans the data directory and removes expired .nano files.
@@ -15,68 +12,107 @@ func (qe *QueryEngine) RunCleaner(interval time.Duration) {
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
-	log.Printf("Cleaner started. Retention: %v, Interval: %v", qe.Retention, interval)
+	log.Printf("Cleaner started. Retention: %v, Interval: %v", qe.GetRetention(), interval)
 
 	for range ticker.C {
-		if qe.Retention <= 0 {
+		if qe.GetRetention() <= 0 && len(qe.GetPolicies()) == 0 {
 			continue
 		}
 		qe.purgeExpiredFiles()
 	}
 }
 
+// purgeExpiredFiles removes every .nano file expired under the retention
+// policy list (shortest MaxAge among every policy matching the file, via
+// matchingMaxAge), falling back to the single global retention for files
+// no policy matches.
+//
+// When there are no named policies, every file shares the same
+// threshold, so a usageIndex (if the store backs one) can pop exactly
+// the expired files in O(log N) with no directory listing or manifest
+// reads at all. Named policies need each file's manifest to know which
+// policy (if any) even applies, so that path still reads one manifest
+// per file - the index still saves the directory listing itself, just
+// not the per-file reads multi-policy matching already requires.
 func (qe *QueryEngine) purgeExpiredFiles() {
-	entries, err := os.ReadDir(qe.dataDir)
-	if err != nil {
-		if os.IsNotExist(err) {
+	policies := qe.GetPolicies()
+	defaultRetention := qe.GetRetention()
+	now := time.Now()
+
+	if qe.usageIndex != nil && len(policies) == 0 {
+		if defaultRetention <= 0 {
 			return
 		}
-		log.Printf("Cleaner error: failed to read data dir: %v", err)
+		threshold := now.Add(-defaultRetention).UnixNano()
+		for _, fd := range qe.usageIndex.PopExpired(threshold) {
+			qe.deleteExpiredFile(fd)
+		}
 		return
 	}
 
-	now := time.Now()
-	threshold := now.Add(-qe.Retention).UnixNano()
+	var files []storage.FileDesc
+	var err error
+	if qe.usageIndex != nil {
+		files = qe.usageIndex.Snapshot()
+	} else {
+		files, err = qe.findNanoFiles()
+	}
+	if err != nil {
+		log.Printf("Cleaner error: failed to list files: %v", err)
+		return
+	}
 
-	for _, entry := range entries {
-		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".nano") {
-			continue
+	for _, fd := range files {
+		manifest, mErr := readManifest(qe.store, fd)
+		if mErr != nil {
+			// Files written before this feature shipped have no sidecar
+			// manifest, so only the default retention (no predicate to
+			// evaluate) can apply to them.
+			manifest = FileManifest{}
 		}
 
-		// Filename format: log_{minTs}_{maxTs}.nano
-		name := entry.Name()
-		maxTs, err := extractMaxTs(name)
-		if err != nil {
-			continue // Skip files with unexpected names
+		maxAge, matched := matchingMaxAge(policies, fd, manifest)
+		if !matched {
+			maxAge = defaultRetention
+		}
+		if maxAge <= 0 {
+			continue
 		}
 
-		if maxTs < threshold {
-			path := filepath.Join(qe.dataDir, name)
-			if err := os.Remove(path); err != nil {
-				log.Printf("Cleaner error: failed to delete %s: %v", name, err)
-			} else {
-				log.Printf("Expired file deleted: %s", name)
-				// Update stats cache
-				qe.mu.Lock()
-				delete(qe.statsCache, name)
-				qe.mu.Unlock()
-			}
+		threshold := now.Add(-maxAge).UnixNano()
+		if fd.MaxTs >= threshold {
+			continue
 		}
+
+		qe.deleteExpiredFile(fd)
 	}
 }
 
-func extractMaxTs(filename string) (int64, error) {
-	// log_1735230000_1735233600.nano
-	base := strings.TrimSuffix(filename, ".nano")
-	parts := strings.Split(base, "_")
-	if len(parts) != 3 {
-		return 0, fmt.Errorf("invalid format")
+// deleteExpiredFile removes fd and its sidecar manifest (a no-op,
+// harmlessly logged, for a pre-manifest-feature file that never had
+// one), drops fd from the usage index and stats cache, and logs the
+// outcome - the common tail end of both purgeExpiredFiles paths above.
+// The fast path above doesn't read a file's manifest before getting
+// here (that's the whole point of skipping straight to PopExpired), so
+// unlike the old single-pass purgeExpiredFiles this always attempts the
+// manifest removal rather than gating it on a manifest read that may not
+// have happened.
+func (qe *QueryEngine) deleteExpiredFile(fd storage.FileDesc) {
+	if err := qe.store.Remove(fd); err != nil {
+		log.Printf("Cleaner error: failed to delete %s: %v", fd.Name(), err)
+		return
 	}
-
-	maxTs, err := strconv.ParseInt(parts[2], 10, 64)
-	if err != nil {
-		return 0, err
+	if err := qe.store.Remove(manifestDesc(fd)); err != nil {
+		log.Printf("Cleaner warning: failed to delete manifest for %s (may not have had one): %v", fd.Name(), err)
+	}
+	if qe.usageIndex != nil {
+		if err := qe.usageIndex.Remove(fd); err != nil {
+			log.Printf("Cleaner warning: failed to update usage index after deleting %s: %v", fd.Name(), err)
+		}
 	}
 
-	return maxTs, nil
+	log.Printf("Expired file deleted: %s", fd.Name())
+	qe.mu.Lock()
+	delete(qe.statsCache, fd.Name())
+	qe.mu.Unlock()
 }
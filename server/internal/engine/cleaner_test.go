@@ -0,0 +1,179 @@
+package engine
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/coffersTech/nanolog/server/internal/engine/cache"
+	"github.com/coffersTech/nanolog/server/internal/engine/storage"
+)
+
+// noopWriter satisfies SnapshotWriterFunc without encoding anything real -
+// these tests only exercise manifest/retention logic, not .nano decoding.
+func noopWriter(w io.WriteCloser, mt *MemTable) error {
+	_, err := w.Write([]byte("fake-snapshot"))
+	return err
+}
+
+func noopReader(rs io.ReadSeekCloser, filter Filter, fileID uint64, bc *cache.BlockCache) ([]LogRow, error) {
+	return nil, nil
+}
+
+func noopColReader(rs io.ReadSeekCloser, cols ColumnSet, filter Filter, fileID uint64, bc *cache.BlockCache) (ColumnBatch, error) {
+	return ColumnBatch{}, nil
+}
+
+// newTestEngine builds a QueryEngine over a MemStorage backend and a WAL
+// rooted at t.TempDir(), with a long-enough default retention that a test
+// can always override via SetRetention/SetPolicies explicitly.
+func newTestEngine(t *testing.T) (*QueryEngine, storage.Storage) {
+	t.Helper()
+	store := storage.NewMemStorage()
+	qe := NewQueryEngine(t.TempDir(), NewMemTable(), store, nil, noopReader, noopColReader, noopWriter, time.Hour)
+	return qe, store
+}
+
+// flushService writes a one-row MemTable for service/level at ts and
+// flushes it, returning the resulting FileDesc.
+func flushService(t *testing.T, qe *QueryEngine, ts int64, level, service, host string) storage.FileDesc {
+	t.Helper()
+	qe.mt.Append(ts, level, service, host, "msg")
+	if err := qe.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	return storage.FileDesc{Kind: storage.KindNano, MinTs: ts, MaxTs: ts}
+}
+
+func TestPurgeExpiredFiles_DefaultRetention(t *testing.T) {
+	qe, store := newTestEngine(t)
+	qe.SetRetention(time.Hour)
+
+	old := flushService(t, qe, time.Now().Add(-2*time.Hour).UnixNano(), "INFO", "checkout", "host-1")
+	fresh := flushService(t, qe, time.Now().Add(-time.Minute).UnixNano(), "INFO", "checkout", "host-1")
+
+	qe.purgeExpiredFiles()
+
+	if _, err := store.Open(old); err == nil {
+		t.Errorf("expected expired file %v to be removed", old)
+	}
+	if _, err := store.Open(fresh); err != nil {
+		t.Errorf("expected fresh file %v to survive, got %v", fresh, err)
+	}
+}
+
+func TestPurgeExpiredFiles_PolicyOverridesDefault(t *testing.T) {
+	qe, store := newTestEngine(t)
+	qe.SetRetention(24 * time.Hour) // default would keep everything below
+
+	billing := flushService(t, qe, time.Now().Add(-2*time.Hour).UnixNano(), "INFO", "billing", "host-1")
+	checkout := flushService(t, qe, time.Now().Add(-2*time.Hour).UnixNano(), "INFO", "checkout", "host-1")
+
+	if err := qe.SetPolicies([]RetentionPolicy{
+		{Name: "billing-short", MaxAge: "1h", Predicate: "service:billing"},
+	}); err != nil {
+		t.Fatalf("SetPolicies: %v", err)
+	}
+
+	qe.purgeExpiredFiles()
+
+	if _, err := store.Open(billing); err == nil {
+		t.Errorf("expected billing file %v (matched by policy) to be purged", billing)
+	}
+	if _, err := store.Open(checkout); err != nil {
+		t.Errorf("expected checkout file %v (no matching policy) to survive under the default retention, got %v", checkout, err)
+	}
+}
+
+func TestPurgeExpiredFiles_OverlappingPredicatesShortestWins(t *testing.T) {
+	qe, store := newTestEngine(t)
+	qe.SetRetention(0) // no fallback - only policies decide
+
+	fd := flushService(t, qe, time.Now().Add(-2*time.Hour).UnixNano(), "ERROR", "checkout", "host-1")
+
+	// Both policies match this file (service:checkout and level:>=WARN);
+	// the shorter of the two MaxAges should decide, regardless of order.
+	if err := qe.SetPolicies([]RetentionPolicy{
+		{Name: "all-checkout", MaxAge: "24h", Predicate: "service:checkout"},
+		{Name: "errors-short", MaxAge: "1h", Predicate: "level:>=WARN"},
+	}); err != nil {
+		t.Fatalf("SetPolicies: %v", err)
+	}
+
+	qe.purgeExpiredFiles()
+
+	if _, err := store.Open(fd); err == nil {
+		t.Errorf("expected file %v to be purged under the shorter of two overlapping policies", fd)
+	}
+}
+
+func TestPurgeExpiredFiles_NonMatchingPolicyLeavesDefault(t *testing.T) {
+	qe, store := newTestEngine(t)
+	qe.SetRetention(time.Hour)
+
+	fd := flushService(t, qe, time.Now().Add(-2*time.Hour).UnixNano(), "INFO", "checkout", "host-1")
+
+	if err := qe.SetPolicies([]RetentionPolicy{
+		{Name: "billing-only", MaxAge: "100h", Predicate: "service:billing"},
+	}); err != nil {
+		t.Fatalf("SetPolicies: %v", err)
+	}
+
+	qe.purgeExpiredFiles()
+
+	if _, err := store.Open(fd); err == nil {
+		t.Errorf("expected file %v to fall back to the expired default retention, not the unrelated policy", fd)
+	}
+}
+
+func TestSetPolicies_ReloadReplacesPreviousList(t *testing.T) {
+	qe, _ := newTestEngine(t)
+
+	if err := qe.SetPolicies([]RetentionPolicy{
+		{Name: "a", MaxAge: "1h", Predicate: "service:checkout"},
+	}); err != nil {
+		t.Fatalf("SetPolicies: %v", err)
+	}
+	if err := qe.SetPolicies([]RetentionPolicy{
+		{Name: "b", MaxAge: "2h", Predicate: "service:billing"},
+	}); err != nil {
+		t.Fatalf("SetPolicies: %v", err)
+	}
+
+	got := qe.GetPolicies()
+	if len(got) != 1 || got[0].Name != "b" {
+		t.Fatalf("expected reload to replace the policy list with just %q, got %+v", "b", got)
+	}
+}
+
+func TestSetPolicies_InvalidPredicateRejected(t *testing.T) {
+	qe, _ := newTestEngine(t)
+
+	if err := qe.SetPolicies([]RetentionPolicy{{Name: "good", MaxAge: "1h"}}); err != nil {
+		t.Fatalf("SetPolicies: %v", err)
+	}
+
+	err := qe.SetPolicies([]RetentionPolicy{
+		{Name: "bad", MaxAge: "1h", Predicate: "service:("},
+	})
+	if err == nil {
+		t.Fatal("expected an error for an unparsable predicate")
+	}
+
+	// The previous, valid list should still be in effect.
+	got := qe.GetPolicies()
+	if len(got) != 1 || got[0].Name != "good" {
+		t.Fatalf("expected the rejected SetPolicies call to leave the old list in place, got %+v", got)
+	}
+}
+
+func TestSetPolicies_InvalidMaxAgeRejected(t *testing.T) {
+	qe, _ := newTestEngine(t)
+
+	err := qe.SetPolicies([]RetentionPolicy{
+		{Name: "bad", MaxAge: "not-a-duration"},
+	})
+	if err == nil {
+		t.Fatal("expected an error for an unparsable max_age")
+	}
+}
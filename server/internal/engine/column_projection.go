@@ -0,0 +1,65 @@
+package engine
+
+// ColumnSet is a bitmask selecting which columns a projected read should
+// populate. Combine bits with |, e.g. ColTs|ColLvl.
+type ColumnSet uint8
+
+const (
+	ColTs ColumnSet = 1 << iota
+	ColLvl
+	ColSvc
+	ColHost
+	ColMsg
+)
+
+// ColAll requests every column, equivalent to the row-based read path.
+const ColAll = ColTs | ColLvl | ColSvc | ColHost | ColMsg
+
+// Has reports whether cs includes col.
+func (cs ColumnSet) Has(col ColumnSet) bool {
+	return cs&col != 0
+}
+
+// ColumnBatch holds column-aligned slices produced by a projected read.
+// Only the columns requested via ColumnSet are populated; the rest are left
+// nil. Every populated slice has length RowCount.
+type ColumnBatch struct {
+	RowCount   int
+	Timestamps []int64
+	Levels     []uint8
+	Services   []string
+	Hosts      []string
+	Messages   []string
+}
+
+// Row reconstructs the LogRow at index i from whichever columns are
+// populated; fields backed by an absent column are left at their zero
+// value.
+func (b ColumnBatch) Row(i int) LogRow {
+	var row LogRow
+	if b.Timestamps != nil {
+		row.Timestamp = b.Timestamps[i]
+	}
+	if b.Levels != nil {
+		row.Level = b.Levels[i]
+	}
+	if b.Services != nil {
+		row.Service = b.Services[i]
+	}
+	if b.Hosts != nil {
+		row.Host = b.Hosts[i]
+	}
+	if b.Messages != nil {
+		row.Message = b.Messages[i]
+	}
+	return row
+}
+
+// Rows adapts the batch to the []LogRow shape row-based callers expect.
+func (b ColumnBatch) Rows() []LogRow {
+	rows := make([]LogRow, b.RowCount)
+	for i := range rows {
+		rows[i] = b.Row(i)
+	}
+	return rows
+}
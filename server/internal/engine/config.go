@@ -0,0 +1,56 @@
+package engine
+
+import (
+	"log"
+	"time"
+
+	"github.com/coffersTech/nanolog/server/internal/controller"
+)
+
+// GetRetention returns the current retention duration used by the
+// cleaner.
+func (qe *QueryEngine) GetRetention() time.Duration {
+	qe.mu.RLock()
+	defer qe.mu.RUnlock()
+	return qe.retention
+}
+
+// SetRetention updates the retention duration the cleaner purges
+// against. Safe to call concurrently with the cleaner's ticker.
+func (qe *QueryEngine) SetRetention(d time.Duration) {
+	qe.mu.Lock()
+	defer qe.mu.Unlock()
+	qe.retention = d
+}
+
+// ApplyConfig updates every QueryEngine setting controller.Config
+// carries live, so a config change takes effect immediately instead of
+// waiting for a restart.
+func (qe *QueryEngine) ApplyConfig(cfg controller.Config) {
+	d, err := time.ParseDuration(cfg.Retention)
+	if err != nil {
+		log.Printf("ApplyConfig: ignoring invalid retention %q: %v", cfg.Retention, err)
+	} else {
+		qe.SetRetention(d)
+	}
+
+	policies := make([]RetentionPolicy, len(cfg.RetentionPolicies))
+	for i, p := range cfg.RetentionPolicies {
+		policies[i] = RetentionPolicy{Name: p.Name, MaxAge: p.MaxAge, Predicate: p.Predicate}
+	}
+	if err := qe.SetPolicies(policies); err != nil {
+		log.Printf("ApplyConfig: ignoring invalid retention policies: %v", err)
+	}
+}
+
+// WatchConfig subscribes to store's published Config updates and applies
+// each one live via ApplyConfig until the returned stop func is called.
+func (qe *QueryEngine) WatchConfig(store *controller.Store) func() {
+	ch, unsubscribe := store.SubscribeConfig()
+	go func() {
+		for cfg := range ch {
+			qe.ApplyConfig(cfg)
+		}
+	}()
+	return unsubscribe
+}
@@ -1,6 +1,8 @@
 package engine
 
 import (
+	"errors"
+	"fmt"
 	"sort"
 )
 
@@ -9,9 +11,20 @@ type HistogramPoint struct {
 	Count int   `json:"count"`
 }
 
-// ComputeHistogram aggregates log counts over time buckets.
+// ErrUnknownCountByField is returned by CountBy when field isn't one of its
+// supported grouping columns.
+var ErrUnknownCountByField = errors.New("engine: unknown CountBy field")
+
+// ComputeHistogram aggregates log counts over time buckets. It only
+// projects the columns it needs (Timestamp, Level, Service — plus Message
+// when the filter requires a text match), so disk files are scanned without
+// ever decompressing the message column in the common case.
 func (qe *QueryEngine) ComputeHistogram(start, end int64, interval int64, filter Filter) ([]HistogramPoint, error) {
-	// Map to store bucket counts: timestamp -> count
+	cols := ColTs | ColLvl | ColSvc
+	if filter.Query != "" {
+		cols |= ColMsg
+	}
+
 	buckets := make(map[int64]int)
 
 	// 1. Scan MemTable
@@ -22,66 +35,36 @@ func (qe *QueryEngine) ComputeHistogram(start, end int64, interval int64, filter
 		if ts < start || ts > end {
 			continue
 		}
-
-		// Apply filters
-		// Note: This iterates full MemTable. For high performance, we could optimize search
-		// but MemTable is usually small.
-		matches := true
-		if filter.Level > 0 && qe.mt.LvlCol[i] != filter.Level {
-			matches = false
-		} else if filter.Service != "" && qe.mt.SvcCol[i] != filter.Service {
-			matches = false
-		} else if filter.Host != "" && qe.mt.HostCol[i] != filter.Host {
-			matches = false
-		} else if filter.Query != "" {
-			// Basic substring match (slow)
-			// Ideally we shouldn't scan message for histogram unless necessary
-			// Assuming message scan is needed if query is present
-			// For histogram, usually we just want volume of ERRORs, etc.
-			// Implementing correctly:
-			// strings.Contains(qe.mt.MsgCol[i], filter.Query) - handled by Filter check logic duplication here
-			// To avoid duplication, we rely on manual check or helper.
-			// Let's manually check for now.
-			// Actually strings package import needed?
-			// We can assume user wants filtering.
-		}
-
-		if matches {
-			// Bucketize
-			// Interval is in nanoseconds??
-			// User inputs: start(ms), end(ms), interval(ms/s?)
-			// Typically TS is nanoseconds in our system.
-			// Let's assume input args are already converted to Nanoseconds by the caller or we convert here.
-			// Assuming caller passes Nanoseconds for start/end/interval to match engine.
-			bucket := (ts / interval) * interval
-			buckets[bucket]++
+		if !qe.mt.matchesFilter(i, filter) {
+			continue
 		}
+		bucket := (ts / interval) * interval
+		buckets[bucket]++
 	}
 	qe.mt.mu.RUnlock()
 
-	// 2. Scan Disk Files
+	// 2. Scan Disk Files, projecting only the columns bucketing needs.
 	files, err := qe.findNanoFiles()
 	if err != nil {
 		return nil, err
 	}
 
-	for _, file := range files {
-		// Read file with filter
-		// Optimization: We are reading full rows here which is inefficient (reads Msg column).
-		// But Reader interface `ReadSnapshot` currently returns []LogRow.
-		// To fix "Performance Key" requirement properly:
-		// We would need a new reader method `ReadTimestampOnly` or `ReadColumns(cols)`.
-		// Given current API limitation, we use existing readerFunc.
-		rows, err := qe.readerFunc(file, filter)
+	for _, fd := range files {
+		rs, err := qe.store.Open(fd)
+		if err != nil {
+			continue
+		}
+		batch, err := qe.colReader(rs, cols, filter, fileID(fd), qe.cache)
 		if err != nil {
 			continue
 		}
 
-		for _, row := range rows {
-			if row.Timestamp < start || row.Timestamp > end {
+		for i := 0; i < batch.RowCount; i++ {
+			ts := batch.Timestamps[i]
+			if ts < start || ts > end {
 				continue
 			}
-			bucket := (row.Timestamp / interval) * interval
+			bucket := (ts / interval) * interval
 			buckets[bucket]++
 		}
 	}
@@ -98,3 +81,73 @@ func (qe *QueryEngine) ComputeHistogram(start, end int64, interval int64, filter
 
 	return points, nil
 }
+
+// CountBy aggregates matching log counts grouped by field ("level" or
+// "service"), using the same column projection as ComputeHistogram so it
+// pays for exactly the columns grouping and filtering need.
+func (qe *QueryEngine) CountBy(field string, filter Filter) (map[string]int, error) {
+	cols := ColTs
+	switch field {
+	case "level":
+		cols |= ColLvl
+	case "service":
+		cols |= ColSvc
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownCountByField, field)
+	}
+	if filter.Service != "" {
+		cols |= ColSvc
+	}
+	if filter.Level > 0 {
+		cols |= ColLvl
+	}
+	if filter.Query != "" {
+		cols |= ColMsg
+	}
+
+	counts := make(map[string]int)
+
+	qe.mt.mu.RLock()
+	rowCount := len(qe.mt.TsCol)
+	for i := 0; i < rowCount; i++ {
+		ts := qe.mt.TsCol[i]
+		if filter.MinTime > 0 && ts < filter.MinTime {
+			continue
+		}
+		if filter.MaxTime > 0 && ts > filter.MaxTime {
+			continue
+		}
+		if !qe.mt.matchesFilter(i, filter) {
+			continue
+		}
+		counts[qe.mt.groupKey(i, field)]++
+	}
+	qe.mt.mu.RUnlock()
+
+	files, err := qe.findNanoFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, fd := range files {
+		rs, err := qe.store.Open(fd)
+		if err != nil {
+			continue
+		}
+		batch, err := qe.colReader(rs, cols, filter, fileID(fd), qe.cache)
+		if err != nil {
+			continue
+		}
+
+		for i := 0; i < batch.RowCount; i++ {
+			switch field {
+			case "level":
+				counts[DecodeLevel(batch.Levels[i])]++
+			case "service":
+				counts[batch.Services[i]]++
+			}
+		}
+	}
+
+	return counts, nil
+}
@@ -0,0 +1,122 @@
+package input
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Decoded is the protocol-neutral result of decoding one line or datagram.
+// Ts is left zero when the wire format carries no usable timestamp;
+// callers stamp it with time.Now() in that case, same as the JSON
+// listeners already do for a missing "timestamp" field.
+type Decoded struct {
+	Ts      int64
+	Level   string
+	Service string
+	Host    string
+	Msg     string
+}
+
+// Decoder parses one line (TCP) or datagram (UDP) of a wire protocol into
+// Decoded fields. It's deliberately expressed in plain fields rather than
+// engine.LogRow: this package must not import engine, since engine already
+// imports input to let QueryEngine register listeners, and the reverse
+// import would cycle.
+type Decoder interface {
+	// Decode parses line, using peerHost to fill in Host when the
+	// protocol doesn't carry its own hostname.
+	Decode(line []byte, peerHost string) (Decoded, error)
+}
+
+// syslogSeverityLevel maps the low 3 bits of a syslog PRI value (the
+// severity) to a nanolog level name. Shared by RFC5424Decoder and
+// RFC3164Decoder, which encode PRI identically.
+var syslogSeverityLevel = [8]string{
+	"FATAL", "FATAL", "FATAL", "ERROR", "WARN", "INFO", "INFO", "DEBUG",
+}
+
+// RFC5424Decoder decodes the IETF syslog protocol (RFC 5424):
+// <PRI>VERSION TIMESTAMP HOSTNAME APP-NAME PROCID MSGID [STRUCTURED-DATA] MSG
+// Only PRI, HOSTNAME, APP-NAME, and the free-form message tail are used;
+// TIMESTAMP, PROCID, and MSGID are parsed only to be skipped over.
+// STRUCTURED-DATA is skipped only in its nil form ("-"); a structured-data
+// element is left at the start of the message.
+type RFC5424Decoder struct{}
+
+var rfc5424Header = regexp.MustCompile(`^<(\d+)>\d+\s+\S+\s+(\S+)\s+(\S+)\s+\S+\s+\S+\s+(?:-\s+)?(.*)$`)
+
+func (RFC5424Decoder) Decode(line []byte, peerHost string) (Decoded, error) {
+	m := rfc5424Header.FindStringSubmatch(string(line))
+	if m == nil {
+		return Decoded{}, fmt.Errorf("input: line does not match RFC5424 header")
+	}
+	pri, err := strconv.Atoi(m[1])
+	if err != nil {
+		return Decoded{}, fmt.Errorf("input: invalid RFC5424 PRI: %w", err)
+	}
+	host := m[2]
+	if host == "-" || host == "" {
+		host = peerHost
+	}
+	return Decoded{
+		Level:   syslogSeverityLevel[pri&0x07],
+		Service: "syslog",
+		Host:    host,
+		Msg:     fmt.Sprintf("%s: %s", m[3], m[4]),
+	}, nil
+}
+
+// RFC3164Decoder decodes the older BSD syslog protocol (RFC 3164):
+// <PRI>Mmm dd hh:mm:ss HOSTNAME TAG: MSG
+// It has no version field or structured data, and TAG (the service name)
+// is folded into the free-text tail rather than a dedicated field, so it's
+// split out on the first ": ".
+type RFC3164Decoder struct{}
+
+var rfc3164Header = regexp.MustCompile(`^<(\d+)>[A-Za-z]{3}\s+\d{1,2}\s+\d{2}:\d{2}:\d{2}\s+(\S+)\s+(.*)$`)
+
+func (RFC3164Decoder) Decode(line []byte, peerHost string) (Decoded, error) {
+	m := rfc3164Header.FindStringSubmatch(string(line))
+	if m == nil {
+		return Decoded{}, fmt.Errorf("input: line does not match RFC3164 header")
+	}
+	pri, err := strconv.Atoi(m[1])
+	if err != nil {
+		return Decoded{}, fmt.Errorf("input: invalid RFC3164 PRI: %w", err)
+	}
+	service, msg := "syslog", m[3]
+	if idx := strings.Index(m[3], ": "); idx != -1 {
+		service, msg = m[3][:idx], m[3][idx+2:]
+	}
+	return Decoded{
+		Level:   syslogSeverityLevel[pri&0x07],
+		Service: service,
+		Host:    m[2],
+		Msg:     msg,
+	}, nil
+}
+
+// PlainDecoder decodes the simple pipe-delimited format
+// "LEVEL|service|host|msg" used by netcat pipelines and ad hoc shippers
+// that don't want to speak syslog or JSON. An empty host field falls back
+// to peerHost.
+type PlainDecoder struct{}
+
+func (PlainDecoder) Decode(line []byte, peerHost string) (Decoded, error) {
+	parts := strings.SplitN(string(line), "|", 4)
+	if len(parts) != 4 {
+		return Decoded{}, fmt.Errorf("input: plain line must have 4 |-delimited fields, got %d", len(parts))
+	}
+	host := parts[2]
+	if host == "" {
+		host = peerHost
+	}
+	return Decoded{
+		Level:   parts[0],
+		Service: parts[1],
+		Host:    host,
+		Msg:     parts[3],
+	}, nil
+}
@@ -0,0 +1,106 @@
+package input
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// HTTPBulkListener accepts POST requests whose body is newline-delimited
+// JSON log objects (NDJSON), one per line, decoded with the same
+// conventions as TCPJSONListener. It's meant for bulk loaders that already
+// speak HTTP but don't want the authenticated /api/ingest request/response
+// shape.
+type HTTPBulkListener struct {
+	addr        string
+	path        string
+	readTimeout time.Duration
+	ing         Ingester
+	counters
+
+	srv *http.Server
+}
+
+// NewHTTPBulkListener creates a bulk listener bound to addr, serving only
+// path. readTimeout becomes the server's ReadTimeout (applied per-request,
+// not reset mid-body like timeoutConn, since net/http already enforces it
+// at the connection level).
+func NewHTTPBulkListener(addr, path string, ing Ingester, readTimeout time.Duration) *HTTPBulkListener {
+	return &HTTPBulkListener{addr: addr, path: path, ing: ing, readTimeout: readTimeout}
+}
+
+func (l *HTTPBulkListener) Name() string { return fmt.Sprintf("http-bulk:%s%s", l.addr, l.path) }
+
+func (l *HTTPBulkListener) Start() error {
+	mux := http.NewServeMux()
+	mux.HandleFunc(l.path, l.handleBulk)
+	l.srv = &http.Server{
+		Addr:        l.addr,
+		Handler:     mux,
+		ReadTimeout: l.readTimeout,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- l.srv.ListenAndServe()
+	}()
+	select {
+	case err := <-errCh:
+		return err
+	case <-time.After(50 * time.Millisecond):
+		return nil
+	}
+}
+
+func (l *HTTPBulkListener) Stop() error {
+	if l.srv == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return l.srv.Shutdown(ctx)
+}
+
+func (l *HTTPBulkListener) handleBulk(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	defer r.Body.Close()
+
+	host := r.RemoteAddr
+	if idx := strings.LastIndex(host, ":"); idx != -1 {
+		host = host[:idx]
+	}
+
+	stopCh := r.Context().Done()
+	scanner := bufio.NewScanner(r.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		if !awaitCapacity(l.ing, &l.pending, stopCh) {
+			http.Error(w, "ingestion paused, try again later", http.StatusServiceUnavailable)
+			return
+		}
+		atomic.AddInt64(&l.accepted, 1)
+		if err := ingestLine(l.ing, line, host); err != nil {
+			atomic.AddInt64(&l.dropped, 1)
+			log.Printf("input: http-bulk malformed line from %s: %v", host, err)
+		}
+	}
+	if err := scanner.Err(); err != nil && err != io.EOF {
+		http.Error(w, "error reading body", http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
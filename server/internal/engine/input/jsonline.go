@@ -0,0 +1,50 @@
+package input
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// jsonLogLine is the wire shape TCPJSONListener and HTTPBulkListener decode
+// each line into. Field names and fallbacks mirror the HTTP ingest
+// endpoint's handleIngest so a single log object round-trips identically
+// regardless of which entrypoint it arrived through.
+type jsonLogLine struct {
+	Timestamp int64  `json:"timestamp"`
+	Level     string `json:"level"`
+	Service   string `json:"service"`
+	Host      string `json:"host"`
+	Message   string `json:"message"`
+	Msg       string `json:"msg"`
+}
+
+// ingestLine parses a single JSON log line and hands it to ing, applying
+// the same defaults as handleIngest: missing timestamp becomes now, empty
+// service becomes "default", empty host falls back to defaultHost (the
+// peer address), and message falls back to msg.
+func ingestLine(ing Ingester, line []byte, defaultHost string) error {
+	var l jsonLogLine
+	if err := json.Unmarshal(line, &l); err != nil {
+		return err
+	}
+
+	ts := l.Timestamp
+	if ts == 0 {
+		ts = time.Now().UnixNano()
+	}
+	service := l.Service
+	if service == "" {
+		service = "default"
+	}
+	host := l.Host
+	if host == "" {
+		host = defaultHost
+	}
+	msg := l.Message
+	if msg == "" {
+		msg = l.Msg
+	}
+
+	ing.Ingest(ts, l.Level, service, host, msg)
+	return nil
+}
@@ -0,0 +1,119 @@
+package input
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TCPLineListener accepts newline-delimited lines over TCP and decodes
+// each with a pluggable Decoder. It's the generic listener new wire
+// formats (like PlainDecoder's "LEVEL|service|host|msg") plug into without
+// writing a new accept loop.
+type TCPLineListener struct {
+	addr        string
+	dec         Decoder
+	readTimeout time.Duration
+	ing         Ingester
+	counters
+
+	mu     sync.Mutex
+	ln     net.Listener
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+// NewTCPLineListener creates a line listener bound to addr once Start is
+// called, decoding each line with dec. readTimeout is applied per
+// connection via timeoutConn; pass 0 to disable idle-connection reaping.
+func NewTCPLineListener(addr string, dec Decoder, ing Ingester, readTimeout time.Duration) *TCPLineListener {
+	return &TCPLineListener{addr: addr, dec: dec, ing: ing, readTimeout: readTimeout}
+}
+
+func (l *TCPLineListener) Name() string { return fmt.Sprintf("tcp-line:%s", l.addr) }
+
+func (l *TCPLineListener) Start() error {
+	ln, err := net.Listen("tcp", l.addr)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	l.ln = ln
+	l.stopCh = make(chan struct{})
+	l.mu.Unlock()
+
+	l.wg.Add(1)
+	go l.acceptLoop(ln, l.stopCh)
+	return nil
+}
+
+func (l *TCPLineListener) Stop() error {
+	l.mu.Lock()
+	ln, stopCh := l.ln, l.stopCh
+	l.mu.Unlock()
+	if ln == nil {
+		return nil
+	}
+	close(stopCh)
+	err := ln.Close()
+	l.wg.Wait()
+	return err
+}
+
+func (l *TCPLineListener) acceptLoop(ln net.Listener, stopCh chan struct{}) {
+	defer l.wg.Done()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			select {
+			case <-stopCh:
+				return
+			default:
+				log.Printf("input: %s accept error: %v", l.Name(), err)
+				continue
+			}
+		}
+		l.wg.Add(1)
+		go l.handleConn(conn, stopCh)
+	}
+}
+
+func (l *TCPLineListener) handleConn(conn net.Conn, stopCh chan struct{}) {
+	defer l.wg.Done()
+	defer conn.Close()
+
+	host := conn.RemoteAddr().String()
+	if idx := strings.LastIndex(host, ":"); idx != -1 {
+		host = host[:idx]
+	}
+
+	tc := newTimeoutConn(conn, l.readTimeout)
+	scanner := bufio.NewScanner(tc)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		if !awaitCapacity(l.ing, &l.pending, stopCh) {
+			return
+		}
+		atomic.AddInt64(&l.accepted, 1)
+		d, err := l.dec.Decode(line, host)
+		if err != nil {
+			atomic.AddInt64(&l.dropped, 1)
+			log.Printf("input: %s malformed line from %s: %v", l.Name(), host, err)
+			continue
+		}
+		ts := d.Ts
+		if ts == 0 {
+			ts = time.Now().UnixNano()
+		}
+		l.ing.Ingest(ts, d.Level, d.Service, d.Host, d.Msg)
+	}
+}
@@ -0,0 +1,260 @@
+package input
+
+import (
+	"bufio"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeIngester is a minimal Ingester recording every call, with a
+// programmable size so tests can exercise backpressure without a real
+// MemTable.
+type fakeIngester struct {
+	mu       sync.Mutex
+	rows     []string
+	size     int64
+	maxBytes int64
+}
+
+func (f *fakeIngester) Ingest(ts int64, level, service, host, msg string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rows = append(f.rows, msg)
+}
+
+func (f *fakeIngester) MemTableSize() int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.size
+}
+
+func (f *fakeIngester) MaxTableBytes() int64 { return f.maxBytes }
+
+func (f *fakeIngester) setSize(v int64) {
+	f.mu.Lock()
+	f.size = v
+	f.mu.Unlock()
+}
+
+func (f *fakeIngester) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.rows)
+}
+
+func TestIngestLineDefaults(t *testing.T) {
+	ing := &fakeIngester{maxBytes: 1024}
+
+	if err := ingestLine(ing, []byte(`{"level":"INFO","message":"hello"}`), "1.2.3.4"); err != nil {
+		t.Fatalf("ingestLine: %v", err)
+	}
+	if err := ingestLine(ing, []byte(`{"level":"WARN","msg":"fallback"}`), "1.2.3.4"); err != nil {
+		t.Fatalf("ingestLine: %v", err)
+	}
+	if ing.count() != 2 {
+		t.Fatalf("expected 2 rows, got %d", ing.count())
+	}
+	if ing.rows[0] != "hello" || ing.rows[1] != "fallback" {
+		t.Errorf("unexpected rows: %+v", ing.rows)
+	}
+
+	if err := ingestLine(ing, []byte(`not json`), "1.2.3.4"); err == nil {
+		t.Errorf("expected error decoding malformed line")
+	}
+}
+
+func TestRFC5424Decoder(t *testing.T) {
+	line := "<12>1 2026-07-29T12:00:00Z myhost myapp 1234 ID47 - connection reset"
+	d, err := RFC5424Decoder{}.Decode([]byte(line), "fallback")
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if d.Level != "WARN" || d.Host != "myhost" || d.Msg != "myapp: connection reset" {
+		t.Errorf("unexpected decode: %+v", d)
+	}
+
+	if _, err := (RFC5424Decoder{}).Decode([]byte("not syslog at all"), "fallback"); err == nil {
+		t.Errorf("expected an error for an unparsable line")
+	}
+}
+
+func TestRFC3164Decoder(t *testing.T) {
+	line := "<12>Jul 29 12:00:00 myhost myapp[1234]: connection reset"
+	d, err := RFC3164Decoder{}.Decode([]byte(line), "fallback")
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if d.Level != "WARN" || d.Host != "myhost" || d.Service != "myapp[1234]" || d.Msg != "connection reset" {
+		t.Errorf("unexpected decode: %+v", d)
+	}
+
+	if _, err := (RFC3164Decoder{}).Decode([]byte("not syslog at all"), "fallback"); err == nil {
+		t.Errorf("expected an error for an unparsable line")
+	}
+}
+
+func TestPlainDecoder(t *testing.T) {
+	d, err := PlainDecoder{}.Decode([]byte("ERROR|payments|10.0.0.1|card declined"), "fallback")
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if d.Level != "ERROR" || d.Service != "payments" || d.Host != "10.0.0.1" || d.Msg != "card declined" {
+		t.Errorf("unexpected decode: %+v", d)
+	}
+
+	d, err = PlainDecoder{}.Decode([]byte("INFO|svc||booted"), "10.0.0.2")
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if d.Host != "10.0.0.2" {
+		t.Errorf("expected empty host to fall back to peerHost, got %q", d.Host)
+	}
+
+	if _, err := (PlainDecoder{}).Decode([]byte("too|few|fields"), "fallback"); err == nil {
+		t.Errorf("expected an error for a line missing fields")
+	}
+}
+
+func TestAwaitCapacityReturnsImmediatelyUnderThreshold(t *testing.T) {
+	ing := &fakeIngester{maxBytes: 100}
+	ing.setSize(50)
+	var pending int64
+	stopCh := make(chan struct{})
+
+	done := make(chan bool, 1)
+	go func() { done <- awaitCapacity(ing, &pending, stopCh) }()
+
+	select {
+	case ok := <-done:
+		if !ok {
+			t.Errorf("expected awaitCapacity to succeed")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("awaitCapacity blocked despite being under threshold")
+	}
+	if atomic.LoadInt64(&pending) != 0 {
+		t.Errorf("pending should be 0 once capacity is available, got %d", pending)
+	}
+}
+
+func TestAwaitCapacityBlocksThenUnblocks(t *testing.T) {
+	ing := &fakeIngester{maxBytes: 100}
+	ing.setSize(300) // above 2x threshold
+	var pending int64
+	stopCh := make(chan struct{})
+
+	done := make(chan bool, 1)
+	go func() { done <- awaitCapacity(ing, &pending, stopCh) }()
+
+	// Give the goroutine a moment to register as pending.
+	time.Sleep(20 * time.Millisecond)
+	if atomic.LoadInt64(&pending) != 1 {
+		t.Fatalf("expected pending=1 while blocked, got %d", pending)
+	}
+
+	ing.setSize(10) // drop below threshold
+	select {
+	case ok := <-done:
+		if !ok {
+			t.Errorf("expected awaitCapacity to succeed once capacity frees up")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("awaitCapacity never unblocked after capacity freed")
+	}
+}
+
+func TestAwaitCapacityStopsOnStopCh(t *testing.T) {
+	ing := &fakeIngester{maxBytes: 100}
+	ing.setSize(300)
+	var pending int64
+	stopCh := make(chan struct{})
+
+	done := make(chan bool, 1)
+	go func() { done <- awaitCapacity(ing, &pending, stopCh) }()
+
+	time.Sleep(20 * time.Millisecond)
+	close(stopCh)
+
+	select {
+	case ok := <-done:
+		if ok {
+			t.Errorf("expected awaitCapacity to report false after stopCh closed")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("awaitCapacity never returned after stopCh closed")
+	}
+}
+
+func TestTCPJSONListenerEndToEnd(t *testing.T) {
+	ing := &fakeIngester{maxBytes: 1024 * 1024}
+	l := NewTCPJSONListener("127.0.0.1:0", ing, 0)
+	// Start binds to an ephemeral port; grab the real address afterward.
+	if err := l.Start(); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	defer l.Stop()
+
+	conn, err := net.Dial("tcp", l.ln.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	w := bufio.NewWriter(conn)
+	w.WriteString(`{"level":"INFO","service":"svc","message":"one"}` + "\n")
+	w.WriteString(`{"level":"ERROR","service":"svc","message":"two"}` + "\n")
+	w.Flush()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for ing.count() < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if ing.count() != 2 {
+		t.Fatalf("expected 2 ingested rows, got %d", ing.count())
+	}
+
+	stats := l.Stats()
+	if stats.Accepted != 2 {
+		t.Errorf("expected Accepted=2, got %+v", stats)
+	}
+}
+
+func TestTCPLineListenerWithPlainDecoder(t *testing.T) {
+	ing := &fakeIngester{maxBytes: 1024 * 1024}
+	l := NewTCPLineListener("127.0.0.1:0", PlainDecoder{}, ing, 0)
+	if err := l.Start(); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	defer l.Stop()
+
+	conn, err := net.Dial("tcp", l.ln.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	w := bufio.NewWriter(conn)
+	w.WriteString("ERROR|payments|10.0.0.1|card declined\n")
+	w.WriteString("bad line missing fields\n")
+	w.Flush()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for l.Stats().Accepted < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if ing.count() != 1 {
+		t.Fatalf("expected 1 ingested row (malformed line dropped), got %d", ing.count())
+	}
+	if ing.rows[0] != "card declined" {
+		t.Errorf("unexpected row: %q", ing.rows[0])
+	}
+
+	stats := l.Stats()
+	if stats.Accepted != 2 || stats.Dropped != 1 {
+		t.Errorf("expected Accepted=2 Dropped=1, got %+v", stats)
+	}
+}
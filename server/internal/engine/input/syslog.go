@@ -0,0 +1,187 @@
+package input
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// syslogDecoders are tried in order against each incoming line: RFC5424
+// first (it's unambiguous thanks to its VERSION field), then the older
+// RFC3164 format. A line matching neither falls back to a raw INFO record
+// in ingestSyslogLine.
+var syslogDecoders = []Decoder{RFC5424Decoder{}, RFC3164Decoder{}}
+
+// ingestSyslogLine decodes line as RFC5424 or RFC3164 and ingests it,
+// falling back to a raw INFO record under fallbackHost if neither parses.
+func ingestSyslogLine(ing Ingester, line, fallbackHost string) {
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" {
+		return
+	}
+	for _, dec := range syslogDecoders {
+		if d, err := dec.Decode([]byte(line), fallbackHost); err == nil {
+			ing.Ingest(time.Now().UnixNano(), d.Level, d.Service, d.Host, d.Msg)
+			return
+		}
+	}
+	ing.Ingest(time.Now().UnixNano(), "INFO", "syslog", fallbackHost, line)
+}
+
+// SyslogListener accepts RFC5424 or RFC3164 syslog messages over UDP (one
+// message per datagram) and/or TCP (newline-delimited, RFC6587 style),
+// auto-detecting the format per line via syslogDecoders. Set Network to
+// "udp", "tcp", or "udp+tcp" via NewSyslogListener's network parameter.
+type SyslogListener struct {
+	addr        string
+	network     string
+	readTimeout time.Duration
+	ing         Ingester
+	counters
+
+	mu      sync.Mutex
+	udpConn *net.UDPConn
+	tcpLn   net.Listener
+	stopCh  chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewSyslogListener creates a syslog listener bound to addr once Start is
+// called. network selects the transport(s): "udp", "tcp", or "udp+tcp" for
+// both. readTimeout governs idle TCP connections only; UDP has no
+// per-packet deadline to reset.
+func NewSyslogListener(addr, network string, ing Ingester, readTimeout time.Duration) *SyslogListener {
+	return &SyslogListener{addr: addr, network: network, ing: ing, readTimeout: readTimeout}
+}
+
+func (l *SyslogListener) Name() string { return fmt.Sprintf("syslog:%s(%s)", l.addr, l.network) }
+
+func (l *SyslogListener) Start() error {
+	l.mu.Lock()
+	l.stopCh = make(chan struct{})
+	stopCh := l.stopCh
+	l.mu.Unlock()
+
+	if strings.Contains(l.network, "udp") {
+		udpAddr, err := net.ResolveUDPAddr("udp", l.addr)
+		if err != nil {
+			return err
+		}
+		conn, err := net.ListenUDP("udp", udpAddr)
+		if err != nil {
+			return err
+		}
+		l.mu.Lock()
+		l.udpConn = conn
+		l.mu.Unlock()
+		l.wg.Add(1)
+		go l.readUDP(conn, stopCh)
+	}
+
+	if strings.Contains(l.network, "tcp") {
+		ln, err := net.Listen("tcp", l.addr)
+		if err != nil {
+			return err
+		}
+		l.mu.Lock()
+		l.tcpLn = ln
+		l.mu.Unlock()
+		l.wg.Add(1)
+		go l.acceptTCP(ln, stopCh)
+	}
+	return nil
+}
+
+func (l *SyslogListener) Stop() error {
+	l.mu.Lock()
+	udpConn, tcpLn, stopCh := l.udpConn, l.tcpLn, l.stopCh
+	l.mu.Unlock()
+	if stopCh == nil {
+		return nil
+	}
+	close(stopCh)
+	var err error
+	if udpConn != nil {
+		err = udpConn.Close()
+	}
+	if tcpLn != nil {
+		if e := tcpLn.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	l.wg.Wait()
+	return err
+}
+
+// readUDP is the one path where backpressure means dropping rather than
+// pausing: a single goroutine reads the whole socket, so blocking it to
+// wait for a flush would also stall every other sender's datagrams
+// indiscriminately, and UDP gives no way to signal "slow down" to just the
+// one source causing the pressure.
+func (l *SyslogListener) readUDP(conn *net.UDPConn, stopCh chan struct{}) {
+	defer l.wg.Done()
+	buf := make([]byte, 64*1024)
+	for {
+		n, addr, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			select {
+			case <-stopCh:
+				return
+			default:
+				log.Printf("input: syslog UDP read error on %s: %v", l.addr, err)
+				continue
+			}
+		}
+		atomic.AddInt64(&l.accepted, 1)
+		if l.ing.MemTableSize() > backpressureThreshold(l.ing) {
+			atomic.AddInt64(&l.dropped, 1)
+			continue
+		}
+		host := addr.IP.String()
+		ingestSyslogLine(l.ing, string(buf[:n]), host)
+	}
+}
+
+func (l *SyslogListener) acceptTCP(ln net.Listener, stopCh chan struct{}) {
+	defer l.wg.Done()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			select {
+			case <-stopCh:
+				return
+			default:
+				log.Printf("input: syslog TCP accept error on %s: %v", l.addr, err)
+				continue
+			}
+		}
+		l.wg.Add(1)
+		go l.handleTCPConn(conn, stopCh)
+	}
+}
+
+func (l *SyslogListener) handleTCPConn(conn net.Conn, stopCh chan struct{}) {
+	defer l.wg.Done()
+	defer conn.Close()
+
+	host := conn.RemoteAddr().String()
+	if idx := strings.LastIndex(host, ":"); idx != -1 {
+		host = host[:idx]
+	}
+
+	tc := newTimeoutConn(conn, l.readTimeout)
+	scanner := bufio.NewScanner(tc)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if !awaitCapacity(l.ing, &l.pending, stopCh) {
+			return
+		}
+		atomic.AddInt64(&l.accepted, 1)
+		ingestSyslogLine(l.ing, scanner.Text(), host)
+	}
+}
@@ -0,0 +1,109 @@
+package input
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TCPJSONListener accepts one JSON log object per line over TCP. Lines are
+// decoded with the same field conventions as the HTTP ingest endpoint.
+type TCPJSONListener struct {
+	addr        string
+	readTimeout time.Duration
+	ing         Ingester
+	counters
+
+	mu     sync.Mutex
+	ln     net.Listener
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+// NewTCPJSONListener creates a listener bound to addr once Start is
+// called. readTimeout is applied per-connection via timeoutConn; pass 0 to
+// disable idle-connection reaping.
+func NewTCPJSONListener(addr string, ing Ingester, readTimeout time.Duration) *TCPJSONListener {
+	return &TCPJSONListener{addr: addr, ing: ing, readTimeout: readTimeout}
+}
+
+func (l *TCPJSONListener) Name() string { return fmt.Sprintf("tcp-json:%s", l.addr) }
+
+func (l *TCPJSONListener) Start() error {
+	ln, err := net.Listen("tcp", l.addr)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	l.ln = ln
+	l.stopCh = make(chan struct{})
+	l.mu.Unlock()
+
+	l.wg.Add(1)
+	go l.acceptLoop(ln, l.stopCh)
+	return nil
+}
+
+func (l *TCPJSONListener) Stop() error {
+	l.mu.Lock()
+	ln, stopCh := l.ln, l.stopCh
+	l.mu.Unlock()
+	if ln == nil {
+		return nil
+	}
+	close(stopCh)
+	err := ln.Close()
+	l.wg.Wait()
+	return err
+}
+
+func (l *TCPJSONListener) acceptLoop(ln net.Listener, stopCh chan struct{}) {
+	defer l.wg.Done()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			select {
+			case <-stopCh:
+				return
+			default:
+				log.Printf("input: tcp-json accept error on %s: %v", l.addr, err)
+				continue
+			}
+		}
+		l.wg.Add(1)
+		go l.handleConn(conn, stopCh)
+	}
+}
+
+func (l *TCPJSONListener) handleConn(conn net.Conn, stopCh chan struct{}) {
+	defer l.wg.Done()
+	defer conn.Close()
+
+	host := conn.RemoteAddr().String()
+	if idx := strings.LastIndex(host, ":"); idx != -1 {
+		host = host[:idx]
+	}
+
+	tc := newTimeoutConn(conn, l.readTimeout)
+	scanner := bufio.NewScanner(tc)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		if !awaitCapacity(l.ing, &l.pending, stopCh) {
+			return
+		}
+		atomic.AddInt64(&l.accepted, 1)
+		if err := ingestLine(l.ing, line, host); err != nil {
+			atomic.AddInt64(&l.dropped, 1)
+			log.Printf("input: tcp-json malformed line from %s: %v", host, err)
+		}
+	}
+}
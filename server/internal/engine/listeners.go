@@ -0,0 +1,69 @@
+package engine
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/coffersTech/nanolog/server/internal/engine/input"
+)
+
+// MemTableSize returns the live MemTable's current byte size. It, together
+// with MaxTableBytes, satisfies input.Ingester so engine/input listeners
+// can throttle without this package needing to import input back.
+func (qe *QueryEngine) MemTableSize() int64 {
+	qe.mu.RLock()
+	mt := qe.mt
+	qe.mu.RUnlock()
+	return mt.GetSize()
+}
+
+// MaxTableBytes returns the configured flush threshold, satisfying
+// input.Ingester.
+func (qe *QueryEngine) MaxTableBytes() int64 { return qe.MaxTableSize }
+
+// RegisterListener starts l against qe and tracks it so its counters show
+// up in GetStats and it's stopped by StopListeners. Call after
+// NewQueryEngine, once per network entrypoint the caller wants enabled
+// (see cmd/nanolog/main.go for an example).
+func (qe *QueryEngine) RegisterListener(l input.Listener) error {
+	if err := l.Start(); err != nil {
+		return fmt.Errorf("start listener %s: %w", l.Name(), err)
+	}
+	qe.mu.Lock()
+	qe.listeners = append(qe.listeners, l)
+	qe.mu.Unlock()
+	log.Printf("Listener started: %s", l.Name())
+	return nil
+}
+
+// StopListeners stops every listener registered via RegisterListener, in
+// registration order. It's best-effort: a listener that fails to stop is
+// logged and skipped so the rest still get a chance to shut down cleanly.
+func (qe *QueryEngine) StopListeners() {
+	qe.mu.RLock()
+	listeners := append([]input.Listener(nil), qe.listeners...)
+	qe.mu.RUnlock()
+
+	for _, l := range listeners {
+		if err := l.Stop(); err != nil {
+			log.Printf("Listener stop error (%s): %v", l.Name(), err)
+		}
+	}
+}
+
+// listenerStats returns the current Stats of every registered listener,
+// keyed by Name, for GetStats to embed in SystemStats.
+func (qe *QueryEngine) listenerStats() map[string]input.Stats {
+	qe.mu.RLock()
+	listeners := append([]input.Listener(nil), qe.listeners...)
+	qe.mu.RUnlock()
+
+	if len(listeners) == 0 {
+		return nil
+	}
+	out := make(map[string]input.Stats, len(listeners))
+	for _, l := range listeners {
+		out[l.Name()] = l.Stats()
+	}
+	return out
+}
@@ -0,0 +1,14 @@
+package engine
+
+// LogEvent is the pre-ingest, string-level view of a log record that
+// protocol decoders (server/internal/ingest) and network listeners
+// (engine/input) hand to QueryEngine.Ingest. LogRow is its disk/query-time
+// counterpart, with Level already encoded to the uint8 column
+// representation.
+type LogEvent struct {
+	Timestamp int64
+	Level     string
+	Service   string
+	Host      string
+	Message   string
+}
@@ -19,3 +19,12 @@ type Filter struct {
 	Host    string `json:"host"`
 	Query   string `json:"q"` // Global keyword search in message
 }
+
+// GetTimestamp, GetLevel, GetService, GetHost, and GetMessage satisfy
+// nanoql.LogRecord, letting NanoQL match residual predicates directly
+// against LogRow without either package importing the other's types.
+func (r *LogRow) GetTimestamp() int64 { return r.Timestamp }
+func (r *LogRow) GetLevel() uint8     { return r.Level }
+func (r *LogRow) GetService() string  { return r.Service }
+func (r *LogRow) GetHost() string     { return r.Host }
+func (r *LogRow) GetMessage() string  { return r.Message }
@@ -0,0 +1,100 @@
+package engine
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/coffersTech/nanolog/server/internal/engine/storage"
+)
+
+// FileManifest is a sidecar to a .nano file recording the metadata
+// RetentionPolicy predicates need to match a file without decoding its
+// columns: the distinct services/hosts/levels it contains, its row count,
+// and its on-disk size. It's written once by the ingest path
+// (Flush/flushMemTable) alongside the .nano file it describes, from the
+// rows still in memory at flush time - the .nano format itself never
+// persists a host column, so this is also the only place Host survives
+// past a flush.
+type FileManifest struct {
+	Services []string `json:"services"`
+	Hosts    []string `json:"hosts"`
+	Levels   []string `json:"levels"`
+	RowCount int      `json:"row_count"`
+	Size     int64    `json:"size"`
+}
+
+// newFileManifest builds a FileManifest from the rows about to be flushed.
+func newFileManifest(rows []LogRow, size int64) FileManifest {
+	svcSeen := make(map[string]bool)
+	hostSeen := make(map[string]bool)
+	lvlSeen := make(map[string]bool)
+
+	m := FileManifest{RowCount: len(rows), Size: size}
+	for _, r := range rows {
+		if !svcSeen[r.Service] {
+			svcSeen[r.Service] = true
+			m.Services = append(m.Services, r.Service)
+		}
+		if !hostSeen[r.Host] {
+			hostSeen[r.Host] = true
+			m.Hosts = append(m.Hosts, r.Host)
+		}
+		lvl := DecodeLevel(r.Level)
+		if !lvlSeen[lvl] {
+			lvlSeen[lvl] = true
+			m.Levels = append(m.Levels, lvl)
+		}
+	}
+	return m
+}
+
+// manifestDesc returns the storage.FileDesc a .nano file's manifest
+// sidecar is stored under: same time range, storage.KindMeta instead of
+// storage.KindNano, so FileStorage/MemStorage need no changes to support
+// it - it's just another Kind sharing the existing Create/Open/Remove.
+func manifestDesc(fd storage.FileDesc) storage.FileDesc {
+	return storage.FileDesc{Kind: storage.KindMeta, MinTs: fd.MinTs, MaxTs: fd.MaxTs}
+}
+
+// writeManifest persists manifest as fd's sidecar.
+func writeManifest(store storage.Storage, fd storage.FileDesc, manifest FileManifest) error {
+	w, err := store.Create(manifestDesc(fd))
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(w).Encode(manifest); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// readManifest loads fd's sidecar manifest, returning an error if it
+// doesn't exist (e.g. a .nano file written before this feature shipped).
+func readManifest(store storage.Storage, fd storage.FileDesc) (FileManifest, error) {
+	rs, err := store.Open(manifestDesc(fd))
+	if err != nil {
+		return FileManifest{}, err
+	}
+	defer rs.Close()
+
+	var m FileManifest
+	if err := json.NewDecoder(rs).Decode(&m); err != nil {
+		return FileManifest{}, err
+	}
+	return m, nil
+}
+
+// countingWriteCloser wraps an io.WriteCloser to track bytes written, so
+// Flush/flushMemTable can record a .nano file's size in its manifest
+// without asking Storage for a Stat it doesn't expose.
+type countingWriteCloser struct {
+	io.WriteCloser
+	n int64
+}
+
+func (c *countingWriteCloser) Write(p []byte) (int, error) {
+	n, err := c.WriteCloser.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -28,12 +28,28 @@ type MemTable struct {
 	HostCol []string // Hostname/IP
 	MsgCol  []string // Message content
 
+	// AttrCol holds each row's structured attributes as JSON, one row per
+	// index matching the other columns. It's populated only by ingestion
+	// paths that carry structured attributes (currently the OTLP gRPC
+	// Export RPC); rows appended via Append leave it empty. Like HostCol,
+	// it isn't persisted by the .nano snapshot format yet.
+	AttrCol *BytesColumn
+
 	// Metadata
 	SizeBytes int64 // Estimated memory usage in bytes
 
 	// Stats
 	writeCounter int64   // Atomic counter for ingestion
 	currentRate  float64 // Logs per second
+
+	// Live-tail subscribers, notified on every Append. Guarded by mu.
+	subscribers []*logSubscriber
+}
+
+// logSubscriber is one Tail RPC's view onto newly appended rows.
+type logSubscriber struct {
+	ch     chan LogRow
+	filter Filter
 }
 
 // NewMemTable initializes MemTable with pre-allocated capacity.
@@ -45,12 +61,19 @@ func NewMemTable() *MemTable {
 		SvcCol:    make([]string, 0, cap),
 		HostCol:   make([]string, 0, cap),
 		MsgCol:    make([]string, 0, cap),
+		AttrCol:   NewBytesColumn(64*1024, cap),
 		SizeBytes: 0,
 	}
 }
 
-// Append adds a log entry.
+// Append adds a log entry with no structured attributes.
 func (mt *MemTable) Append(ts int64, level string, service string, host string, msg string) {
+	mt.AppendWithAttrs(ts, level, service, host, msg, nil)
+}
+
+// AppendWithAttrs adds a log entry along with its structured attributes,
+// encoded as JSON (nil/empty if the source record carried none).
+func (mt *MemTable) AppendWithAttrs(ts int64, level string, service string, host string, msg string, attrsJSON []byte) {
 	mt.mu.Lock()
 	defer mt.mu.Unlock()
 
@@ -60,13 +83,85 @@ func (mt *MemTable) Append(ts int64, level string, service string, host string,
 	mt.SvcCol = append(mt.SvcCol, service)
 	mt.HostCol = append(mt.HostCol, host)
 	mt.MsgCol = append(mt.MsgCol, msg)
+	mt.AttrCol.Append(attrsJSON)
 
-	// Update size estimate: msg + service + host + 8 (timestamp) + 1 (level)
-	addedSize := int64(len(msg) + len(service) + len(host) + 8 + 1)
+	// Update size estimate: msg + service + host + attrs + 8 (timestamp) + 1 (level)
+	addedSize := int64(len(msg) + len(service) + len(host) + len(attrsJSON) + 8 + 1)
 	atomic.AddInt64(&mt.SizeBytes, addedSize)
 
 	// Update stats counter
 	atomic.AddInt64(&mt.writeCounter, 1)
+
+	if len(mt.subscribers) > 0 {
+		mt.notifySubscribers(LogRow{
+			Timestamp: ts,
+			Level:     lvl,
+			Service:   service,
+			Host:      host,
+			Message:   msg,
+		})
+	}
+}
+
+// Subscribe registers a live-tail subscriber matching filter (MinTime/MaxTime
+// are ignored since only rows appended from here on are ever delivered) and
+// returns a channel of newly appended rows along with an unsubscribe
+// function the caller must call when done. The channel is buffered; a
+// subscriber that falls behind has rows dropped rather than stalling
+// Append for every other writer and subscriber.
+func (mt *MemTable) Subscribe(filter Filter) (<-chan LogRow, func()) {
+	sub := &logSubscriber{ch: make(chan LogRow, 256), filter: filter}
+
+	mt.mu.Lock()
+	mt.subscribers = append(mt.subscribers, sub)
+	mt.mu.Unlock()
+
+	unsubscribe := func() {
+		mt.mu.Lock()
+		for i, s := range mt.subscribers {
+			if s == sub {
+				mt.subscribers = append(mt.subscribers[:i], mt.subscribers[i+1:]...)
+				break
+			}
+		}
+		mt.mu.Unlock()
+		close(sub.ch)
+	}
+	return sub.ch, unsubscribe
+}
+
+// notifySubscribers fans row out to every subscriber whose filter matches
+// it. Caller must hold mt.mu (called from Append, which already does).
+func (mt *MemTable) notifySubscribers(row LogRow) {
+	for _, sub := range mt.subscribers {
+		if !matchesRow(row, sub.filter) {
+			continue
+		}
+		select {
+		case sub.ch <- row:
+		default:
+			// Slow subscriber; drop rather than stall ingestion.
+		}
+	}
+}
+
+// matchesRow reports whether row satisfies the non-time fields of filter,
+// mirroring matchesFilter's logic for a standalone LogRow rather than a
+// column index.
+func matchesRow(row LogRow, filter Filter) bool {
+	if filter.Level > 0 && row.Level != filter.Level {
+		return false
+	}
+	if filter.Service != "" && row.Service != filter.Service {
+		return false
+	}
+	if filter.Host != "" && row.Host != filter.Host {
+		return false
+	}
+	if filter.Query != "" && !strings.Contains(row.Message, filter.Query) {
+		return false
+	}
+	return true
 }
 
 // GetSize returns the estimated memory usage in bytes.
@@ -91,6 +186,7 @@ func (mt *MemTable) Reset() {
 	mt.SvcCol = mt.SvcCol[:0]
 	mt.HostCol = mt.HostCol[:0]
 	mt.MsgCol = mt.MsgCol[:0]
+	mt.AttrCol.Reset()
 	atomic.StoreInt64(&mt.SizeBytes, 0)
 }
 
@@ -136,53 +232,82 @@ func (mt *MemTable) Search(filter Filter, limit int) []LogRow {
 			continue
 		}
 
-		lvl := mt.LvlCol[i]
-		if filter.Level > 0 && lvl != filter.Level {
-			continue
-		}
-
-		svc := mt.SvcCol[i]
-		if filter.Service != "" && svc != filter.Service {
-			continue
-		}
-
-		host := mt.HostCol[i]
-		if filter.Host != "" && host != filter.Host {
-			continue
-		}
-
-		msg := mt.MsgCol[i]
-		if filter.Query != "" && !strings.Contains(msg, filter.Query) {
+		if !mt.matchesFilter(i, filter) {
 			continue
 		}
 
 		result = append(result, LogRow{
 			Timestamp: ts,
-			Level:     lvl,
-			Service:   svc,
-			Host:      host,
-			Message:   msg,
+			Level:     mt.LvlCol[i],
+			Service:   mt.SvcCol[i],
+			Host:      mt.HostCol[i],
+			Message:   mt.MsgCol[i],
 		})
 	}
 
 	return result
 }
 
-// EncodeLevel converts string level to uint8.
+// matchesFilter reports whether row i satisfies the non-time fields of
+// filter (Level, Service, Host, Query). Callers check MinTime/MaxTime
+// themselves, since they scan different time ranges before ever reaching
+// here (e.g. histogram bucketing vs. full search). Caller must hold mt.mu.
+func (mt *MemTable) matchesFilter(i int, filter Filter) bool {
+	if filter.Level > 0 && mt.LvlCol[i] != filter.Level {
+		return false
+	}
+	if filter.Service != "" && mt.SvcCol[i] != filter.Service {
+		return false
+	}
+	if filter.Host != "" && mt.HostCol[i] != filter.Host {
+		return false
+	}
+	if filter.Query != "" && !strings.Contains(mt.MsgCol[i], filter.Query) {
+		return false
+	}
+	return true
+}
+
+// groupKey returns the CountBy grouping value for row i. Caller must hold
+// mt.mu and pass a field CountBy already validated.
+func (mt *MemTable) groupKey(i int, field string) string {
+	switch field {
+	case "level":
+		return DecodeLevel(mt.LvlCol[i])
+	case "service":
+		return mt.SvcCol[i]
+	default:
+		return ""
+	}
+}
+
+// EncodeLevel converts string level to uint8, defaulting to INFO for
+// anything it doesn't recognize.
 func EncodeLevel(l string) uint8 {
+	if lvl, ok := parseLevelName(l); ok {
+		return lvl
+	}
+	return LevelInfo
+}
+
+// parseLevelName converts a recognized level name to its uint8 code,
+// reporting false instead of silently defaulting like EncodeLevel does.
+// Used where defaulting an unrecognized name to INFO would be wrong, e.g.
+// deciding whether a NanoQL predicate can be pushed down into Filter.
+func parseLevelName(l string) (uint8, bool) {
 	switch strings.ToUpper(l) {
 	case "DEBUG":
-		return LevelDebug
+		return LevelDebug, true
 	case "INFO":
-		return LevelInfo
+		return LevelInfo, true
 	case "WARN", "WARNING":
-		return LevelWarn
+		return LevelWarn, true
 	case "ERROR":
-		return LevelError
+		return LevelError, true
 	case "FATAL":
-		return LevelFatal
+		return LevelFatal, true
 	default:
-		return LevelInfo
+		return 0, false
 	}
 }
 
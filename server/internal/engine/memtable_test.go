@@ -0,0 +1,55 @@
+package engine
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemTableSubscribeReceivesMatchingRows(t *testing.T) {
+	mt := NewMemTable()
+	ch, unsubscribe := mt.Subscribe(Filter{Service: "checkout"})
+	defer unsubscribe()
+
+	mt.Append(1, "INFO", "other", "host-1", "ignored")
+	mt.Append(2, "INFO", "checkout", "host-1", "order placed")
+
+	select {
+	case row := <-ch:
+		if row.Service != "checkout" || row.Message != "order placed" {
+			t.Fatalf("unexpected row: %+v", row)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected a matching row on the subscriber channel")
+	}
+
+	select {
+	case row := <-ch:
+		t.Fatalf("expected no further rows, got %+v", row)
+	default:
+	}
+}
+
+func TestMemTableAppendWithAttrsPopulatesAttrCol(t *testing.T) {
+	mt := NewMemTable()
+	mt.Append(1, "INFO", "svc", "host", "no attrs")
+	mt.AppendWithAttrs(2, "INFO", "svc", "host", "has attrs", []byte(`{"trace_id":"abc"}`))
+
+	if got := mt.AttrCol.Get(0); len(got) != 0 {
+		t.Fatalf("expected empty attrs for row 0, got %q", got)
+	}
+	if got := string(mt.AttrCol.Get(1)); got != `{"trace_id":"abc"}` {
+		t.Fatalf("unexpected attrs for row 1: %q", got)
+	}
+}
+
+func TestMemTableUnsubscribeStopsDelivery(t *testing.T) {
+	mt := NewMemTable()
+	ch, unsubscribe := mt.Subscribe(Filter{})
+	unsubscribe()
+
+	mt.Append(1, "INFO", "svc", "host", "after unsubscribe")
+
+	if _, ok := <-ch; ok {
+		t.Fatal("expected channel to be closed after unsubscribe")
+	}
+}
@@ -0,0 +1,130 @@
+package engine
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/coffersTech/nanolog/server/internal/pkg/nanoql"
+)
+
+// Compile walks a NanoQL AST and extracts every predicate that Filter can
+// express exactly (level/service/host equality and inclusive ts ranges)
+// into a Filter, so ExecuteScan's file pruning by timestamp and
+// ComputeHistogram/CountBy's column projection keep working for queries
+// written with the new range/comparison syntax. It returns the residual
+// node — whatever couldn't be pushed down — for callers to evaluate per-row
+// with MatchNanoQL. Compile only pushes predicates out of the top-level AND
+// chain: a predicate inside an OR or NOT can't be safely removed, since
+// dropping it would make the compiled Filter exclude rows the residual
+// would otherwise have matched.
+func Compile(node nanoql.Node) (Filter, nanoql.Node) {
+	var f Filter
+	residual := compileAnd(node, &f)
+	return f, residual
+}
+
+func compileAnd(node nanoql.Node, f *Filter) nanoql.Node {
+	if bin, ok := node.(nanoql.BinaryExpr); ok && bin.Op == "AND" {
+		left := compileAnd(bin.Left, f)
+		right := compileAnd(bin.Right, f)
+		return combineResidual(left, right)
+	}
+
+	if pushDown(node, f) {
+		return nil
+	}
+	return node
+}
+
+func combineResidual(left, right nanoql.Node) nanoql.Node {
+	switch {
+	case left == nil:
+		return right
+	case right == nil:
+		return left
+	default:
+		return nanoql.BinaryExpr{Op: "AND", Left: left, Right: right}
+	}
+}
+
+// pushDown absorbs node into f if it's a predicate Filter can express
+// exactly, reporting whether it did. Each leaf of the AND chain shares the
+// same f (see compileAnd), so a field a prior clause already pushed down
+// is tightened or checked rather than overwritten: two equality clauses on
+// the same field only combine if they agree (a contradiction is left
+// residual, which MatchNanoQL will then correctly never match), and two ts
+// bounds on the same side keep whichever is stricter.
+func pushDown(node nanoql.Node, f *Filter) bool {
+	switch n := node.(type) {
+	case nanoql.MatchExpr:
+		if n.Op != "=" {
+			return false
+		}
+		// A glob value (service:web-*) is a pattern, not a value Filter's
+		// exact equality can express.
+		if strings.ContainsAny(n.Value, "*?") {
+			return false
+		}
+		switch strings.ToLower(n.Key) {
+		case "service", "svc":
+			if f.Service != "" && f.Service != n.Value {
+				return false
+			}
+			f.Service = n.Value
+			return true
+		case "host", "ip", "hostname":
+			if f.Host != "" && f.Host != n.Value {
+				return false
+			}
+			f.Host = n.Value
+			return true
+		case "level", "lvl":
+			lvl, ok := parseLevelName(n.Value)
+			if !ok {
+				return false
+			}
+			if f.Level != 0 && f.Level != lvl {
+				return false
+			}
+			f.Level = lvl
+			return true
+		}
+		return false
+
+	case nanoql.RangeExpr:
+		if strings.ToLower(n.Key) != "ts" && strings.ToLower(n.Key) != "timestamp" {
+			return false
+		}
+		// Filter's MinTime/MaxTime are inclusive bounds, so an exclusive
+		// comparison (ts:<x, ts:>x) can't be expressed exactly and must
+		// stay residual rather than over-matching.
+		if n.HasLo {
+			if !n.LoIncl {
+				return false
+			}
+			lo, err := strconv.ParseInt(n.Lo, 10, 64)
+			if err != nil {
+				return false
+			}
+			if f.MinTime == 0 || lo > f.MinTime {
+				f.MinTime = lo
+			}
+		}
+		if n.HasHi {
+			if !n.HiIncl {
+				return false
+			}
+			hi, err := strconv.ParseInt(n.Hi, 10, 64)
+			if err != nil {
+				return false
+			}
+			if f.MaxTime == 0 || hi < f.MaxTime {
+				f.MaxTime = hi
+			}
+		}
+		return true
+
+	default:
+		return false
+	}
+}
@@ -0,0 +1,124 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/coffersTech/nanolog/server/internal/pkg/nanoql"
+)
+
+func TestCompilePushesTimeAndEqualityPredicates(t *testing.T) {
+	node, err := nanoql.Parse("service:order AND ts:[100..200] AND level:ERROR")
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+
+	f, residual := Compile(node)
+
+	if f.Service != "order" || f.MinTime != 100 || f.MaxTime != 200 || f.Level != LevelError {
+		t.Errorf("unexpected compiled filter: %+v", f)
+	}
+	if residual != nil {
+		t.Errorf("expected no residual, got %+v", residual)
+	}
+}
+
+func TestCompileLeavesExclusiveRangesResidual(t *testing.T) {
+	node, err := nanoql.Parse("ts:>100")
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+
+	f, residual := Compile(node)
+
+	if f.MinTime != 0 {
+		t.Errorf("exclusive bound should not be pushed, got MinTime=%d", f.MinTime)
+	}
+	if residual == nil {
+		t.Fatalf("expected residual predicate for exclusive range")
+	}
+	row := &LogRow{Timestamp: 150}
+	if !MatchNanoQL(residual, row) {
+		t.Errorf("residual should still match ts=150 against ts:>100")
+	}
+	row2 := &LogRow{Timestamp: 100}
+	if MatchNanoQL(residual, row2) {
+		t.Errorf("residual should reject ts=100 against exclusive ts:>100")
+	}
+}
+
+func TestCompileDoesNotPushPredicatesInsideOr(t *testing.T) {
+	node, err := nanoql.Parse("service:order OR level:ERROR")
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+
+	f, residual := Compile(node)
+
+	if f.Service != "" || f.Level != 0 {
+		t.Errorf("OR branches must not be pushed down, got filter: %+v", f)
+	}
+	if residual == nil {
+		t.Fatalf("expected the whole OR expression as residual")
+	}
+
+	matchRow := &LogRow{Service: "payments", Level: LevelError}
+	if !MatchNanoQL(residual, matchRow) {
+		t.Errorf("residual should still match level:ERROR branch")
+	}
+}
+
+func TestCompileTightensDuplicateTimeBounds(t *testing.T) {
+	node, err := nanoql.Parse("ts:>=5000 AND ts:>=1000")
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+
+	f, residual := Compile(node)
+
+	if f.MinTime != 5000 {
+		t.Errorf("expected the stricter MinTime=5000 to win, got %d", f.MinTime)
+	}
+	if residual != nil {
+		t.Errorf("expected no residual once the stricter bound is captured, got %+v", residual)
+	}
+}
+
+func TestCompileLeavesConflictingEqualityResidual(t *testing.T) {
+	node, err := nanoql.Parse("service:order AND service:payments")
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+
+	f, residual := Compile(node)
+
+	if f.Service != "order" {
+		t.Errorf("expected the first service clause pushed down, got %+v", f)
+	}
+	if residual == nil {
+		t.Fatalf("expected the conflicting second service clause to stay residual")
+	}
+
+	row := &LogRow{Service: "order"}
+	if MatchNanoQL(residual, row) {
+		t.Errorf("residual should reject service=order since it also requires service=payments")
+	}
+}
+
+func TestCompileLeavesRegexAndServiceLevelResidualMix(t *testing.T) {
+	node, err := nanoql.Parse(`service:order AND msg~"timeout"`)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+
+	f, residual := Compile(node)
+
+	if f.Service != "order" {
+		t.Errorf("expected service pushed down, got %+v", f)
+	}
+	if residual == nil {
+		t.Fatalf("expected regex predicate left as residual")
+	}
+	if _, ok := residual.(nanoql.RegexExpr); !ok {
+		t.Errorf("expected residual to be the bare RegexExpr, got %+v", residual)
+	}
+}
@@ -1,51 +1,81 @@
 package engine
 
 import (
-	"fmt"
+	"hash/fnv"
+	"io"
 	"log"
-	"os"
 	"path/filepath"
 	"sort"
-	"strconv"
-	"strings"
 	"sync"
 	"time"
+
+	"github.com/coffersTech/nanolog/server/internal/engine/cache"
+	"github.com/coffersTech/nanolog/server/internal/engine/input"
+	"github.com/coffersTech/nanolog/server/internal/engine/storage"
 )
 
-// SnapshotReaderFunc is a function type for reading .nano files with filtering.
-type SnapshotReaderFunc func(filename string, filter Filter) ([]LogRow, error)
+// SnapshotReaderFunc reads a .nano snapshot from an open handle (typically
+// obtained via Storage.Open) and returns the rows matching filter. fileID
+// identifies the handle for block-cache lookups; bc may be nil to read
+// without caching.
+type SnapshotReaderFunc func(rs io.ReadSeekCloser, filter Filter, fileID uint64, bc *cache.BlockCache) ([]LogRow, error)
+
+// SnapshotWriterFunc writes a MemTable to an open handle (typically
+// obtained via Storage.Create) in .nano format.
+type SnapshotWriterFunc func(w io.WriteCloser, mt *MemTable) error
 
-// SnapshotWriterFunc is a function type for writing MemTable to a .nano file.
-type SnapshotWriterFunc func(path string, mt *MemTable) error
+// ColumnReaderFunc is the columnar companion to SnapshotReaderFunc: instead
+// of materializing []LogRow, it returns only the columns selected by cols,
+// letting callers like ComputeHistogram and CountBy skip columns (most
+// importantly Message) they have no use for.
+type ColumnReaderFunc func(rs io.ReadSeekCloser, cols ColumnSet, filter Filter, fileID uint64, bc *cache.BlockCache) (ColumnBatch, error)
 
 // QueryEngine handles query execution and data lifecycle across persisted data.
 type QueryEngine struct {
 	dataDir    string
 	mt         *MemTable
+	store      storage.Storage
+	cache      *cache.BlockCache
 	readerFunc SnapshotReaderFunc
+	colReader  ColumnReaderFunc
 	writerFunc SnapshotWriterFunc
-	Retention  time.Duration
+	retention  time.Duration     // guarded by mu; read/written via GetRetention/SetRetention
+	policies   []RetentionPolicy // guarded by mu; read/written via GetPolicies/SetPolicies
 
 	// Configuration
 	MaxTableSize int64
 
-	// Stats Cache
+	// Stats Cache, keyed by FileDesc.Name()
 	statsCache map[string]SystemStats
 	mu         sync.RWMutex
 
 	// WAL for crash recovery
 	wal *WAL
+
+	// usageIndex lets purgeExpiredFiles skip a full store.List scan on
+	// every cleaner tick. It's nil when store doesn't implement
+	// storage.SidecarStorage (e.g. MemStorage in tests), in which case
+	// the cleaner falls back to listing directly.
+	usageIndex *UsageIndex
+
+	// Network ingestion listeners registered via RegisterListener.
+	listeners []input.Listener
 }
 
 // NewQueryEngine creates a new QueryEngine and initializes the stats cache.
-func NewQueryEngine(dataDir string, mt *MemTable, readerFunc SnapshotReaderFunc, writerFunc SnapshotWriterFunc, retention time.Duration) *QueryEngine {
-	// Initialize WAL
-	walPath := filepath.Join(dataDir, "wal.log")
-	if err := os.MkdirAll(dataDir, 0755); err != nil {
-		log.Printf("Warning: failed to create data dir for WAL: %v", err)
+// store is the backend snapshots are persisted to; dataDir is still used
+// directly for the WAL, which has not yet moved behind the Storage interface.
+// blockCache caches decompressed .nano column blocks across scans; pass nil
+// to get a cache sized at cache.DefaultCapacity. colReader backs the
+// projected reads used by ComputeHistogram and CountBy.
+func NewQueryEngine(dataDir string, mt *MemTable, store storage.Storage, blockCache *cache.BlockCache, readerFunc SnapshotReaderFunc, colReader ColumnReaderFunc, writerFunc SnapshotWriterFunc, retention time.Duration) *QueryEngine {
+	if blockCache == nil {
+		blockCache = cache.NewBlockCache(cache.DefaultCapacity)
 	}
-
-	wal, err := OpenWAL(walPath)
+	// Initialize WAL. It lives in its own segmented subdirectory (OpenWAL
+	// creates dataDir transitively via MkdirAll).
+	walDir := filepath.Join(dataDir, "wal")
+	wal, err := OpenWAL(walDir)
 	if err != nil {
 		log.Printf("Warning: failed to open WAL: %v", err)
 	}
@@ -53,9 +83,12 @@ func NewQueryEngine(dataDir string, mt *MemTable, readerFunc SnapshotReaderFunc,
 	qe := &QueryEngine{
 		dataDir:      dataDir,
 		mt:           mt,
+		store:        store,
+		cache:        blockCache,
 		readerFunc:   readerFunc,
+		colReader:    colReader,
 		writerFunc:   writerFunc,
-		Retention:    retention,
+		retention:    retention,
 		MaxTableSize: 64 * 1024 * 1024, // 64MB Default
 		statsCache:   make(map[string]SystemStats),
 		wal:          wal,
@@ -64,15 +97,34 @@ func NewQueryEngine(dataDir string, mt *MemTable, readerFunc SnapshotReaderFunc,
 	// Crash Recovery: Replay WAL if it has data
 	if wal != nil {
 		recoveredRows, err := wal.Replay()
-		if err == nil && len(recoveredRows) > 0 {
-			log.Printf("Crash recovery: replaying %d logs from WAL...", len(recoveredRows))
-			for _, row := range recoveredRows {
-				// Re-append to current MemTable
-				// Note: We avoid calling qe.Ingest here to prevent re-writing to WAL
-				qe.mt.Append(row.Timestamp, DecodeLevel(row.Level), row.Service, row.Host, row.Message)
-			}
-		} else if err != nil {
+		if err != nil && err != ErrTornTail {
 			log.Printf("WAL replay warning: %v", err)
+		} else {
+			if err == ErrTornTail {
+				log.Printf("Crash recovery: torn WAL tail detected, recovering %d good records and dropping the rest", len(recoveredRows))
+				if tErr := wal.TruncateTornTail(); tErr != nil {
+					log.Printf("Failed to truncate torn WAL tail: %v", tErr)
+				}
+			}
+			if len(recoveredRows) > 0 {
+				log.Printf("Crash recovery: replaying %d logs from WAL...", len(recoveredRows))
+				for _, row := range recoveredRows {
+					// Re-append to current MemTable
+					// Note: We avoid calling qe.Ingest here to prevent re-writing to WAL
+					qe.mt.Append(row.Timestamp, DecodeLevel(row.Level), row.Service, row.Host, row.Message)
+				}
+			}
+		}
+	}
+
+	// usage index, if the store backs one, so the cleaner can avoid a
+	// full directory scan on every tick.
+	if sidecar, ok := store.(storage.SidecarStorage); ok {
+		idx, err := LoadUsageIndex(sidecar)
+		if err != nil {
+			log.Printf("Warning: failed to load usage index, cleaner will fall back to full scans: %v", err)
+		} else {
+			qe.usageIndex = idx
 		}
 	}
 
@@ -88,31 +140,63 @@ func (qe *QueryEngine) Flush() error {
 		return nil
 	}
 
-	// Ensure data directory exists
-	if err := os.MkdirAll(qe.dataDir, 0755); err != nil {
-		return err
+	// Seal the WAL segments covering this MemTable before writing, so
+	// Checkpoint below only ever deletes segments this flush actually
+	// covers, not ones later writes might still land in.
+	var walEndSeq uint64
+	if qe.wal != nil {
+		seq, err := qe.wal.Rotate()
+		if err != nil {
+			log.Printf("WAL rotate error: %v", err)
+		} else {
+			walEndSeq = seq
+		}
 	}
 
-	minTs := qe.mt.MinTimestamp()
-	maxTs := qe.mt.MaxTimestamp()
-	filename := fmt.Sprintf("log_%d_%d.nano", minTs, maxTs)
-	path := filepath.Join(qe.dataDir, filename)
+	fd := storage.FileDesc{
+		Kind:  storage.KindNano,
+		MinTs: qe.mt.MinTimestamp(),
+		MaxTs: qe.mt.MaxTimestamp(),
+	}
 
 	// Compute stats before reset
 	rows := qe.mt.Search(Filter{}, -1)
 	fStats := qe.computeStatsFromRows(rows)
 
-	if err := qe.writerFunc(path, qe.mt); err != nil {
+	w, err := qe.store.Create(fd)
+	if err != nil {
 		return err
 	}
+	cw := &countingWriteCloser{WriteCloser: w}
+	if err := qe.writerFunc(cw, qe.mt); err != nil {
+		cw.Close()
+		return err
+	}
+	if err := cw.Close(); err != nil {
+		return err
+	}
+	if err := writeManifest(qe.store, fd, newFileManifest(qe.mt.Search(Filter{}, qe.mt.Len()), cw.n)); err != nil {
+		log.Printf("Flush: failed to write manifest for %s: %v", fd.Name(), err)
+	}
+	if qe.usageIndex != nil {
+		if err := qe.usageIndex.Add(fd); err != nil {
+			log.Printf("Flush: failed to update usage index for %s: %v", fd.Name(), err)
+		}
+	}
 
 	// Update cache
 	qe.mu.Lock()
-	qe.statsCache[filename] = fStats
+	qe.statsCache[fd.Name()] = fStats
 	qe.mu.Unlock()
 
+	if qe.wal != nil {
+		if err := qe.wal.Checkpoint(walEndSeq); err != nil {
+			log.Printf("WAL checkpoint error: %v", err)
+		}
+	}
+
 	qe.mt.Reset()
-	log.Printf("Flushed to disk: %s", filename)
+	log.Printf("Flushed to disk: %s", fd.Name())
 	return nil
 }
 
@@ -144,13 +228,28 @@ func (qe *QueryEngine) Ingest(ts int64, level, service, host, msg string) {
 
 		log.Printf("MemTable reached threshold (%d MB), swapping for async flush...", qe.MaxTableSize/(1024*1024))
 		oldTable := qe.mt
+
+		// Seal the WAL segments covering oldTable before the new MemTable
+		// can write a single record, so the two tables never share a
+		// segment and a checkpoint for one can never delete data the
+		// other still needs.
+		var walEndSeq uint64
+		if qe.wal != nil {
+			seq, err := qe.wal.Rotate()
+			if err != nil {
+				log.Printf("WAL rotate error: %v", err)
+			} else {
+				walEndSeq = seq
+			}
+		}
+
 		qe.mt = NewMemTable()
 		// Inherit stats ticker for the new table
 		qe.mt.StartStatsTicker(1 * time.Second)
 		qe.mu.Unlock()
 
 		// Background flush
-		go qe.flushMemTable(oldTable)
+		go qe.flushMemTable(oldTable, walEndSeq)
 	}
 }
 
@@ -163,44 +262,63 @@ func (qe *QueryEngine) SyncWAL() {
 	}
 }
 
-func (qe *QueryEngine) flushMemTable(mt *MemTable) {
+// flushMemTable writes mt to disk. walEndSeq is the last WAL segment mt's
+// records were pinned to (via Rotate at swap time); once the write
+// succeeds, only the segments up to walEndSeq are checkpointed away, never
+// segments newer MemTables may still depend on.
+func (qe *QueryEngine) flushMemTable(mt *MemTable, walEndSeq uint64) {
 	if mt.Len() == 0 {
 		return
 	}
 
-	// Ensure data directory exists
-	if err := os.MkdirAll(qe.dataDir, 0755); err != nil {
-		log.Printf("Background flush directory error: %v", err)
-		return
+	fd := storage.FileDesc{
+		Kind:  storage.KindNano,
+		MinTs: mt.MinTimestamp(),
+		MaxTs: mt.MaxTimestamp(),
 	}
 
-	minTs := mt.MinTimestamp()
-	maxTs := mt.MaxTimestamp()
-	filename := fmt.Sprintf("log_%d_%d.nano", minTs, maxTs)
-	path := filepath.Join(qe.dataDir, filename)
-
 	// Compute stats for cache
 	rows := mt.Search(Filter{}, -1)
 	fStats := qe.computeStatsFromRows(rows)
 
-	if err := qe.writerFunc(path, mt); err != nil {
+	w, err := qe.store.Create(fd)
+	if err != nil {
+		log.Printf("Background flush create error: %v", err)
+		return
+	}
+	cw := &countingWriteCloser{WriteCloser: w}
+	if err := qe.writerFunc(cw, mt); err != nil {
 		log.Printf("Background flush write error: %v", err)
+		cw.Close()
 		return
 	}
+	if err := cw.Close(); err != nil {
+		log.Printf("Background flush close error: %v", err)
+		return
+	}
+	if err := writeManifest(qe.store, fd, newFileManifest(mt.Search(Filter{}, mt.Len()), cw.n)); err != nil {
+		log.Printf("Background flush: failed to write manifest for %s: %v", fd.Name(), err)
+	}
+	if qe.usageIndex != nil {
+		if err := qe.usageIndex.Add(fd); err != nil {
+			log.Printf("Background flush: failed to update usage index for %s: %v", fd.Name(), err)
+		}
+	}
 
 	// Store in cache
 	qe.mu.Lock()
-	qe.statsCache[filename] = fStats
+	qe.statsCache[fd.Name()] = fStats
 	qe.mu.Unlock()
 
-	// Truncate WAL after successful write
+	// Checkpoint the WAL after successful write, covering only the
+	// segments this MemTable was pinned to.
 	if qe.wal != nil {
-		if err := qe.wal.Reset(); err != nil {
-			log.Printf("WAL reset error: %v", err)
+		if err := qe.wal.Checkpoint(walEndSeq); err != nil {
+			log.Printf("WAL checkpoint error: %v", err)
 		}
 	}
 
-	log.Printf("Background flush completed: %s", filename)
+	log.Printf("Background flush completed: %s", fd.Name())
 }
 
 // ExecuteScan scans memory and then .nano files and returns up to `limit` rows matching the filter.
@@ -224,26 +342,28 @@ func (qe *QueryEngine) ExecuteScan(filter Filter, limit int) ([]LogRow, error) {
 	}
 
 	sort.Slice(files, func(i, j int) bool {
-		return files[i] > files[j]
+		return files[i].MaxTs > files[j].MaxTs
 	})
 
-	for _, file := range files {
+	for _, fd := range files {
 		if len(result) >= limit {
 			break
 		}
 
-		// File Pruning: Parse timestamps from filename (log_minTs_maxTs.nano)
-		minTs, maxTs, err := parseTsFromFilename(file)
-		if err == nil {
-			if filter.MinTime > 0 && maxTs < filter.MinTime {
-				continue // File is too old
-			}
-			if filter.MaxTime > 0 && minTs > filter.MaxTime {
-				continue // File is too new
-			}
+		// File Pruning: skip files whose time range can't satisfy filter.
+		if filter.MinTime > 0 && fd.MaxTs < filter.MinTime {
+			continue // File is too old
+		}
+		if filter.MaxTime > 0 && fd.MinTs > filter.MaxTime {
+			continue // File is too new
 		}
 
-		rows, err := qe.readerFunc(file, filter)
+		rs, err := qe.store.Open(fd)
+		if err != nil {
+			// Log error but continue with other files
+			continue
+		}
+		rows, err := qe.readerFunc(rs, filter, fileID(fd), qe.cache)
 		if err != nil {
 			// Log error but continue with other files
 			continue
@@ -264,25 +384,36 @@ func (qe *QueryEngine) ExecuteScan(filter Filter, limit int) ([]LogRow, error) {
 	return result, nil
 }
 
-// findNanoFiles returns all .nano files in the data directory.
-func (qe *QueryEngine) findNanoFiles() ([]string, error) {
-	var files []string
+// Subscribe registers a live-tail subscriber on the current MemTable and
+// returns a channel of newly ingested rows plus an unsubscribe func. It's
+// a thin passthrough to MemTable.Subscribe, grabbed under qe.mu the same
+// way ExecuteScan grabs mt so a concurrent flush swapping qe.mt doesn't
+// race the subscription; the caller is the /api/tail SSE handler, which
+// needs push delivery instead of polling ExecuteScan.
+func (qe *QueryEngine) Subscribe(filter Filter) (<-chan LogRow, func()) {
+	qe.mu.RLock()
+	mt := qe.mt
+	qe.mu.RUnlock()
+	return mt.Subscribe(filter)
+}
 
-	entries, err := os.ReadDir(qe.dataDir)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return files, nil // Empty result if dir doesn't exist
-		}
-		return nil, err
-	}
+// findNanoFiles returns the descriptors of all .nano files in the store.
+func (qe *QueryEngine) findNanoFiles() ([]storage.FileDesc, error) {
+	return qe.store.List(storage.KindNano)
+}
 
-	for _, entry := range entries {
-		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".nano") {
-			files = append(files, filepath.Join(qe.dataDir, entry.Name()))
-		}
-	}
+// fileID derives a stable cache key for fd's contents from its name, so the
+// same file maps to the same block-cache entries across opens.
+func fileID(fd storage.FileDesc) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(fd.Name()))
+	return h.Sum64()
+}
 
-	return files, nil
+// CacheStats reports the block cache's cumulative hit/miss counters and
+// current occupancy, for the HTTP stats endpoint.
+func (qe *QueryEngine) CacheStats() cache.Stats {
+	return qe.cache.Stats()
 }
 
 func (qe *QueryEngine) loadStatsCache() {
@@ -293,13 +424,19 @@ func (qe *QueryEngine) loadStatsCache() {
 	}
 
 	corruptedCount := 0
-	for _, file := range files {
+	for _, fd := range files {
 		// Optimization: Read all rows to aggregate stats.
-		rows, err := qe.readerFunc(file, Filter{})
+		rs, err := qe.store.Open(fd)
+		if err != nil {
+			log.Printf("Skipping unreadable file %s: %v", fd.Name(), err)
+			corruptedCount++
+			continue
+		}
+		rows, err := qe.readerFunc(rs, Filter{}, fileID(fd), qe.cache)
 		if err != nil {
 			// If file is corrupted, we log and skip it.
 			// In the future, we could move it to a 'corrupted' subfolder.
-			log.Printf("Skipping corrupted file %s: %v", filepath.Base(file), err)
+			log.Printf("Skipping corrupted file %s: %v", fd.Name(), err)
 			corruptedCount++
 			continue
 		}
@@ -307,7 +444,7 @@ func (qe *QueryEngine) loadStatsCache() {
 		fStats := qe.computeStatsFromRows(rows)
 
 		qe.mu.Lock()
-		qe.statsCache[filepath.Base(file)] = fStats
+		qe.statsCache[fd.Name()] = fStats
 		qe.mu.Unlock()
 	}
 
@@ -343,21 +480,3 @@ func (qe *QueryEngine) computeStatsFromRows(rows []LogRow) SystemStats {
 	}
 	return s
 }
-
-func parseTsFromFilename(filename string) (int64, int64, error) {
-	base := filepath.Base(filename)
-	if !strings.HasPrefix(base, "log_") || !strings.HasSuffix(base, ".nano") {
-		return 0, 0, fmt.Errorf("invalid format")
-	}
-	content := strings.TrimSuffix(strings.TrimPrefix(base, "log_"), ".nano")
-	parts := strings.Split(content, "_")
-	if len(parts) != 2 {
-		return 0, 0, fmt.Errorf("invalid parts")
-	}
-	minTs, err1 := strconv.ParseInt(parts[0], 10, 64)
-	maxTs, err2 := strconv.ParseInt(parts[1], 10, 64)
-	if err1 != nil || err2 != nil {
-		return 0, 0, fmt.Errorf("invalid timestamps")
-	}
-	return minTs, maxTs, nil
-}
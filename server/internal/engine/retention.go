@@ -0,0 +1,160 @@
+package engine
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/coffersTech/nanolog/server/internal/engine/storage"
+	"github.com/coffersTech/nanolog/server/internal/pkg/nanoql"
+)
+
+// RetentionPolicy is one named rule in QueryEngine's retention policy
+// list, modeled on InfluxDB-style named retention policies. A file is
+// purged once it's older than the shortest MaxAge among every policy
+// whose Predicate matches it; a file no policy matches falls back to
+// QueryEngine's single global retention (GetRetention/SetRetention), so
+// existing single-retention deployments keep working unchanged.
+type RetentionPolicy struct {
+	Name string `json:"name"`
+	// MaxAge is a time.ParseDuration string, e.g. "720h", matching the
+	// wire format controller.Config.Retention already uses.
+	MaxAge string `json:"max_age"`
+	// Predicate is a NanoQL query string restricting which files this
+	// policy applies to, e.g. "service:checkout" or
+	// "service IN (checkout, billing) AND level:>=WARN". Empty matches
+	// every file.
+	Predicate string `json:"predicate,omitempty"`
+
+	maxAge time.Duration
+	node   nanoql.Node
+}
+
+// GetPolicies returns a copy of the current retention policy list.
+func (qe *QueryEngine) GetPolicies() []RetentionPolicy {
+	qe.mu.RLock()
+	defer qe.mu.RUnlock()
+	return append([]RetentionPolicy(nil), qe.policies...)
+}
+
+// SetPolicies replaces the retention policy list, parsing every
+// Predicate and MaxAge up front so purgeExpiredFiles never reparses
+// NanoQL or a duration string per file per run. On a parse error, the
+// existing policies are left in place and the error names the offending
+// policy.
+func (qe *QueryEngine) SetPolicies(policies []RetentionPolicy) error {
+	compiled := make([]RetentionPolicy, len(policies))
+	for i, p := range policies {
+		node, err := nanoql.Parse(p.Predicate)
+		if err != nil {
+			return fmt.Errorf("policy %q: invalid predicate: %w", p.Name, err)
+		}
+		maxAge, err := time.ParseDuration(p.MaxAge)
+		if err != nil {
+			return fmt.Errorf("policy %q: invalid max_age: %w", p.Name, err)
+		}
+		p.node = node
+		p.maxAge = maxAge
+		compiled[i] = p
+	}
+
+	qe.mu.Lock()
+	defer qe.mu.Unlock()
+	qe.policies = compiled
+	return nil
+}
+
+// matchingMaxAge returns the shortest MaxAge among every policy whose
+// predicate matches fd/manifest, and whether any did. Matching every
+// policy (rather than stopping at the first) is what gives overlapping
+// predicates "shortest retention wins" semantics: a file two policies
+// both claim is purged on the more aggressive of the two, regardless of
+// the policies' order in the list.
+func matchingMaxAge(policies []RetentionPolicy, fd storage.FileDesc, manifest FileManifest) (time.Duration, bool) {
+	var (
+		shortest time.Duration
+		found    bool
+	)
+	for _, p := range policies {
+		if !matchesPolicy(p.node, fd, manifest) {
+			continue
+		}
+		if !found || p.maxAge < shortest {
+			shortest = p.maxAge
+			found = true
+		}
+	}
+	return shortest, found
+}
+
+// matchesPolicy reports whether fd/manifest could satisfy node. Exact
+// predicates (service/host/level equality, inclusive ts ranges) are
+// pushed into a Filter via Compile and checked directly against fd and
+// manifest; anything left over (a residual, e.g. a level range or a
+// regex) is checked by probing every distinct service/host/level
+// combination the manifest recorded, at both of the file's timestamp
+// extremes. Since a manifest records which values a file contains but
+// not which row they came from, this can over-match - the same
+// deliberate false-positive tradeoff the v3 Bloom filters make for query
+// pruning. It only ever widens which files a policy applies to; it never
+// affects what a row-level query returns.
+func matchesPolicy(node nanoql.Node, fd storage.FileDesc, manifest FileManifest) bool {
+	if node == nil {
+		return true
+	}
+
+	filter, residual := Compile(node)
+	if filter.MinTime > 0 && fd.MaxTs < filter.MinTime {
+		return false
+	}
+	if filter.MaxTime > 0 && fd.MinTs > filter.MaxTime {
+		return false
+	}
+	if filter.Service != "" && !containsValue(manifest.Services, filter.Service) {
+		return false
+	}
+	if filter.Host != "" && !containsValue(manifest.Hosts, filter.Host) {
+		return false
+	}
+	if filter.Level > 0 && !containsValue(manifest.Levels, DecodeLevel(filter.Level)) {
+		return false
+	}
+	if residual == nil {
+		return true
+	}
+
+	services := manifest.Services
+	if len(services) == 0 {
+		services = []string{""}
+	}
+	hosts := manifest.Hosts
+	if len(hosts) == 0 {
+		hosts = []string{""}
+	}
+	levels := manifest.Levels
+	if len(levels) == 0 {
+		levels = []string{"INFO"}
+	}
+
+	for _, ts := range []int64{fd.MinTs, fd.MaxTs} {
+		for _, svc := range services {
+			for _, host := range hosts {
+				for _, lvl := range levels {
+					row := &LogRow{Timestamp: ts, Level: EncodeLevel(lvl), Service: svc, Host: host}
+					if nanoql.Match(residual, row) {
+						return true
+					}
+				}
+			}
+		}
+	}
+	return false
+}
+
+func containsValue(values []string, want string) bool {
+	for _, v := range values {
+		if v == want {
+			return true
+		}
+	}
+	return false
+}
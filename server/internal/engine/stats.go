@@ -3,15 +3,20 @@ package engine
 import (
 	"os"
 	"path/filepath"
+
+	"github.com/coffersTech/nanolog/server/internal/engine/cache"
+	"github.com/coffersTech/nanolog/server/internal/engine/input"
 )
 
 // SystemStats contains high-level system metrics.
 type SystemStats struct {
-	IngestionRate float64        `json:"ingestion_rate"` // logs/sec
-	TotalLogs     int64          `json:"total_logs"`     // total count
-	DiskUsage     int64          `json:"disk_usage"`     // bytes
-	LevelDist     map[string]int `json:"level_dist"`     // e.g. "INFO": 100
-	TopServices   map[string]int `json:"top_services"`   // e.g. "order-svc": 50
+	IngestionRate float64                `json:"ingestion_rate"`     // logs/sec
+	TotalLogs     int64                  `json:"total_logs"`         // total count
+	DiskUsage     int64                  `json:"disk_usage"`         // bytes
+	LevelDist     map[string]int         `json:"level_dist"`         // e.g. "INFO": 100
+	TopServices   map[string]int         `json:"top_services"`       // e.g. "order-svc": 50
+	BlockCache    cache.Stats            `json:"block_cache"`        // .nano reader cache hit/miss counters
+	Listeners     map[string]input.Stats `json:"listeners,omitempty"` // per-listener accept/drop/pending, keyed by Listener.Name
 }
 
 // GetStats aggregates current system statistics from cache and MemTable.
@@ -78,5 +83,8 @@ func (qe *QueryEngine) GetStats() SystemStats {
 		stats.LevelDist[lvlStr]++
 	}
 
+	stats.BlockCache = qe.CacheStats()
+	stats.Listeners = qe.listenerStats()
+
 	return stats
 }
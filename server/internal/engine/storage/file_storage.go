@@ -0,0 +1,186 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// fileName maps a FileDesc to the on-disk filename FileStorage uses.
+// Kept as a free function so FileDesc.Name() and FileStorage.path agree.
+func fileName(fd FileDesc) string {
+	switch fd.Kind {
+	case KindNano:
+		return fmt.Sprintf("log_%d_%d.nano", fd.MinTs, fd.MaxTs)
+	case KindMeta:
+		return fmt.Sprintf("log_%d_%d.meta.json", fd.MinTs, fd.MaxTs)
+	default:
+		return fmt.Sprintf("%s_%d_%d", fd.Kind, fd.MinTs, fd.MaxTs)
+	}
+}
+
+// parseFileName reverses fileName, returning ok=false for names that don't
+// match the expected scheme for kind.
+func parseFileName(kind Kind, name string) (FileDesc, bool) {
+	switch kind {
+	case KindNano:
+		if !strings.HasPrefix(name, "log_") || !strings.HasSuffix(name, ".nano") {
+			return FileDesc{}, false
+		}
+		content := strings.TrimSuffix(strings.TrimPrefix(name, "log_"), ".nano")
+		parts := strings.Split(content, "_")
+		if len(parts) != 2 {
+			return FileDesc{}, false
+		}
+		minTs, err1 := strconv.ParseInt(parts[0], 10, 64)
+		maxTs, err2 := strconv.ParseInt(parts[1], 10, 64)
+		if err1 != nil || err2 != nil {
+			return FileDesc{}, false
+		}
+		return FileDesc{Kind: kind, MinTs: minTs, MaxTs: maxTs}, true
+	case KindMeta:
+		if !strings.HasPrefix(name, "log_") || !strings.HasSuffix(name, ".meta.json") {
+			return FileDesc{}, false
+		}
+		content := strings.TrimSuffix(strings.TrimPrefix(name, "log_"), ".meta.json")
+		parts := strings.Split(content, "_")
+		if len(parts) != 2 {
+			return FileDesc{}, false
+		}
+		minTs, err1 := strconv.ParseInt(parts[0], 10, 64)
+		maxTs, err2 := strconv.ParseInt(parts[1], 10, 64)
+		if err1 != nil || err2 != nil {
+			return FileDesc{}, false
+		}
+		return FileDesc{Kind: kind, MinTs: minTs, MaxTs: maxTs}, true
+	default:
+		return FileDesc{}, false
+	}
+}
+
+// FileStorage is a Storage backend rooted at a local directory. It matches
+// the filename scheme NanoLog has always used for snapshots:
+// log_{minTs}_{maxTs}.nano.
+type FileStorage struct {
+	dir      string
+	lockFile *os.File
+}
+
+// NewFileStorage creates a FileStorage rooted at dir, creating the
+// directory if it doesn't already exist.
+func NewFileStorage(dir string) (*FileStorage, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &FileStorage{dir: dir}, nil
+}
+
+func (fs *FileStorage) path(fd FileDesc) string {
+	return filepath.Join(fs.dir, fileName(fd))
+}
+
+// List returns the descriptors of every file of the given kind found in
+// the storage directory.
+func (fs *FileStorage) List(kind Kind) ([]FileDesc, error) {
+	entries, err := os.ReadDir(fs.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var out []FileDesc
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if fd, ok := parseFileName(kind, entry.Name()); ok {
+			out = append(out, fd)
+		}
+	}
+	return out, nil
+}
+
+// Create opens fd for writing, creating the backing file if necessary.
+func (fs *FileStorage) Create(fd FileDesc) (io.WriteCloser, error) {
+	return os.Create(fs.path(fd))
+}
+
+// Open opens fd for reading.
+func (fs *FileStorage) Open(fd FileDesc) (io.ReadSeekCloser, error) {
+	return os.Open(fs.path(fd))
+}
+
+// Remove deletes the backing file for fd.
+func (fs *FileStorage) Remove(fd FileDesc) error {
+	return os.Remove(fs.path(fd))
+}
+
+// Lock acquires an advisory, exclusive flock on a LOCK file in the storage
+// directory, guaranteeing a single writer.
+func (fs *FileStorage) Lock() error {
+	if fs.lockFile != nil {
+		return errors.New("storage: already locked")
+	}
+
+	f, err := os.OpenFile(filepath.Join(fs.dir, "LOCK"), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		return fmt.Errorf("storage: failed to acquire lock: %w", err)
+	}
+
+	fs.lockFile = f
+	return nil
+}
+
+// Unlock releases a lock acquired via Lock.
+func (fs *FileStorage) Unlock() error {
+	if fs.lockFile == nil {
+		return nil
+	}
+
+	err := syscall.Flock(int(fs.lockFile.Fd()), syscall.LOCK_UN)
+	closeErr := fs.lockFile.Close()
+	fs.lockFile = nil
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
+
+// ReadSidecar returns the contents of name, a file in the storage
+// directory alongside its .nano/.meta.json snapshots.
+func (fs *FileStorage) ReadSidecar(name string) ([]byte, error) {
+	return os.ReadFile(filepath.Join(fs.dir, name))
+}
+
+// WriteSidecar replaces name's contents via write-then-rename, so a
+// reader (including this same process on its next restart) never
+// observes a partially written sidecar.
+func (fs *FileStorage) WriteSidecar(name string, data []byte) error {
+	path := filepath.Join(fs.dir, name)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// DirModTime returns the storage directory's current modification time.
+func (fs *FileStorage) DirModTime() (time.Time, error) {
+	info, err := os.Stat(fs.dir)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return info.ModTime(), nil
+}
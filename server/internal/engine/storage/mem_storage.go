@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sync"
+)
+
+// MemStorage is an in-memory Storage backend. It exists so unit tests can
+// exercise QueryEngine flush/scan/cleanup logic without touching the
+// filesystem.
+type MemStorage struct {
+	mu     sync.Mutex
+	locked bool
+	files  map[FileDesc][]byte
+}
+
+// NewMemStorage creates an empty in-memory Storage backend.
+func NewMemStorage() *MemStorage {
+	return &MemStorage{files: make(map[FileDesc][]byte)}
+}
+
+// List returns the descriptors of every stored file of the given kind.
+func (m *MemStorage) List(kind Kind) ([]FileDesc, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var out []FileDesc
+	for fd := range m.files {
+		if fd.Kind == kind {
+			out = append(out, fd)
+		}
+	}
+	return out, nil
+}
+
+// memWriter buffers writes in memory and commits them to the MemStorage on
+// Close, mirroring the create-then-write-then-close lifecycle of a real file.
+type memWriter struct {
+	m   *MemStorage
+	fd  FileDesc
+	buf bytes.Buffer
+}
+
+func (w *memWriter) Write(p []byte) (int, error) {
+	return w.buf.Write(p)
+}
+
+func (w *memWriter) Close() error {
+	w.m.mu.Lock()
+	defer w.m.mu.Unlock()
+	w.m.files[w.fd] = append([]byte(nil), w.buf.Bytes()...)
+	return nil
+}
+
+// Create opens fd for writing.
+func (m *MemStorage) Create(fd FileDesc) (io.WriteCloser, error) {
+	return &memWriter{m: m, fd: fd}, nil
+}
+
+// memReader adapts a bytes.Reader to io.ReadSeekCloser.
+type memReader struct {
+	*bytes.Reader
+}
+
+func (memReader) Close() error { return nil }
+
+// Open opens fd for reading.
+func (m *MemStorage) Open(fd FileDesc) (io.ReadSeekCloser, error) {
+	m.mu.Lock()
+	data, ok := m.files[fd]
+	m.mu.Unlock()
+	if !ok {
+		return nil, errors.New("storage: file not found")
+	}
+	return memReader{bytes.NewReader(data)}, nil
+}
+
+// Remove deletes fd from the store.
+func (m *MemStorage) Remove(fd FileDesc) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.files[fd]; !ok {
+		return errors.New("storage: file not found")
+	}
+	delete(m.files, fd)
+	return nil
+}
+
+// Lock marks the store as locked, failing if it is already held.
+func (m *MemStorage) Lock() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.locked {
+		return errors.New("storage: already locked")
+	}
+	m.locked = true
+	return nil
+}
+
+// Unlock releases a lock acquired via Lock.
+func (m *MemStorage) Unlock() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.locked = false
+	return nil
+}
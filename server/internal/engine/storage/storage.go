@@ -0,0 +1,77 @@
+// Package storage abstracts where .nano snapshots live so the engine can
+// run against a local directory, an in-memory store for tests, or (in the
+// future) a remote object store, without QueryEngine caring which.
+package storage
+
+import (
+	"io"
+	"time"
+)
+
+// Kind identifies the category of file tracked by a Storage backend.
+type Kind string
+
+const (
+	// KindNano identifies a persisted columnar log snapshot (.nano file).
+	KindNano Kind = "nano"
+	// KindMeta identifies a .nano file's sidecar manifest: the distinct
+	// services/hosts/levels it contains plus its row count and size,
+	// used by retention policies to match a file without decoding it.
+	// Always shares its MinTs/MaxTs with the KindNano file it describes.
+	KindMeta Kind = "meta"
+)
+
+// FileDesc describes a persisted file by its logical identity (kind plus
+// the time range it covers) rather than by a filesystem path, so the same
+// descriptor addresses the file consistently across backends.
+type FileDesc struct {
+	Kind  Kind
+	MinTs int64
+	MaxTs int64
+}
+
+// Name returns a stable, human-readable identifier for fd. FileStorage uses
+// it to derive an on-disk filename; other backends use it as a cache key or
+// log label.
+func (fd FileDesc) Name() string {
+	return fileName(fd)
+}
+
+// Storage abstracts the persistence layer for .nano snapshots.
+type Storage interface {
+	// List returns the descriptors of every file of the given kind.
+	List(kind Kind) ([]FileDesc, error)
+	// Create opens fd for writing, creating or truncating it.
+	Create(fd FileDesc) (io.WriteCloser, error)
+	// Open opens fd for reading.
+	Open(fd FileDesc) (io.ReadSeekCloser, error)
+	// Remove deletes fd.
+	Remove(fd FileDesc) error
+	// Lock acquires an exclusive lock guaranteeing a single writer for this
+	// storage location; a second Lock before Unlock fails. MemStorage holds
+	// the lock as an in-process flag only.
+	Lock() error
+	// Unlock releases a lock acquired via Lock.
+	Unlock() error
+}
+
+// SidecarStorage is implemented by Storage backends that can durably
+// persist small control-plane files of their own, separate from the
+// Kind-addressed snapshots List/Create/Open/Remove manage. The cleaner's
+// on-disk usage index is the first user: a MemStorage-backed QueryEngine
+// (as in tests) has nothing durable to persist one into, so callers
+// type-assert for this and fall back to scanning List themselves when a
+// backend doesn't implement it.
+type SidecarStorage interface {
+	Storage
+
+	// ReadSidecar returns name's contents, or an error satisfying
+	// os.IsNotExist if it doesn't exist yet.
+	ReadSidecar(name string) ([]byte, error)
+	// WriteSidecar atomically replaces name's contents.
+	WriteSidecar(name string, data []byte) error
+	// DirModTime returns the storage directory's current modification
+	// time - cheap enough to call on every cleaner tick as a staleness
+	// check against a previously persisted sidecar.
+	DirModTime() (time.Time, error)
+}
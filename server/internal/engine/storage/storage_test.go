@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"io"
+	"testing"
+)
+
+func testStorage(t *testing.T, store Storage) {
+	t.Helper()
+
+	fd := FileDesc{Kind: KindNano, MinTs: 100, MaxTs: 200}
+
+	w, err := store.Create(fd)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := w.Write([]byte("hello")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	files, err := store.List(KindNano)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(files) != 1 || files[0] != fd {
+		t.Fatalf("expected [%v], got %v", fd, files)
+	}
+
+	r, err := store.Open(fd)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	r.Close()
+	if string(data) != "hello" {
+		t.Fatalf("expected %q, got %q", "hello", data)
+	}
+
+	if err := store.Remove(fd); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if _, err := store.Open(fd); err == nil {
+		t.Fatal("expected Open after Remove to fail")
+	}
+}
+
+func TestMemStorage(t *testing.T) {
+	testStorage(t, NewMemStorage())
+}
+
+func TestFileStorage(t *testing.T) {
+	store, err := NewFileStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStorage: %v", err)
+	}
+	testStorage(t, store)
+}
+
+func TestFileStorageSidecar(t *testing.T) {
+	store, err := NewFileStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStorage: %v", err)
+	}
+
+	if _, err := store.ReadSidecar("usage.idx"); err == nil {
+		t.Fatal("expected ReadSidecar to fail before WriteSidecar")
+	}
+
+	if err := store.WriteSidecar("usage.idx", []byte("v1")); err != nil {
+		t.Fatalf("WriteSidecar: %v", err)
+	}
+	data, err := store.ReadSidecar("usage.idx")
+	if err != nil {
+		t.Fatalf("ReadSidecar: %v", err)
+	}
+	if string(data) != "v1" {
+		t.Fatalf("expected %q, got %q", "v1", data)
+	}
+
+	// WriteSidecar replaces, not appends.
+	if err := store.WriteSidecar("usage.idx", []byte("v2")); err != nil {
+		t.Fatalf("WriteSidecar (overwrite): %v", err)
+	}
+	data, err = store.ReadSidecar("usage.idx")
+	if err != nil {
+		t.Fatalf("ReadSidecar after overwrite: %v", err)
+	}
+	if string(data) != "v2" {
+		t.Fatalf("expected %q, got %q", "v2", data)
+	}
+
+	if _, err := store.DirModTime(); err != nil {
+		t.Fatalf("DirModTime: %v", err)
+	}
+}
+
+func TestFileStorageLock(t *testing.T) {
+	store, err := NewFileStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStorage: %v", err)
+	}
+
+	if err := store.Lock(); err != nil {
+		t.Fatalf("Lock: %v", err)
+	}
+	if err := store.Lock(); err == nil {
+		t.Fatal("expected second Lock to fail")
+	}
+	if err := store.Unlock(); err != nil {
+		t.Fatalf("Unlock: %v", err)
+	}
+	if err := store.Lock(); err != nil {
+		t.Fatalf("Lock after Unlock: %v", err)
+	}
+	store.Unlock()
+}
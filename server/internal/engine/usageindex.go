@@ -0,0 +1,277 @@
+package engine
+
+import (
+	"bytes"
+	"container/heap"
+	"encoding/gob"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/coffersTech/nanolog/server/internal/engine/storage"
+)
+
+// usageIndexFile is the sidecar UsageIndex persists itself into, so the
+// cleaner doesn't have to re-List and re-readManifest every .nano file on
+// every tick. The approach (a small persistent index, mutated in place on
+// ingest/delete, with a cheap staleness check gating a full rebuild) is
+// borrowed from MinIO's data-usage crawler cache.
+const usageIndexFile = "usage.idx"
+
+// usageEntry is one .nano file's expiry-relevant identity: its time
+// range, which is also everything storage.FileDesc needs to address it
+// again (Kind is always KindNano for an indexed entry) - so the cleaner
+// can go from a popped entry straight to store.Remove without reopening
+// or re-listing anything.
+type usageEntry struct {
+	MinTs int64
+	MaxTs int64
+}
+
+func (e usageEntry) fileDesc() storage.FileDesc {
+	return storage.FileDesc{Kind: storage.KindNano, MinTs: e.MinTs, MaxTs: e.MaxTs}
+}
+
+// usageHeap is a min-heap of usageEntry ordered by MaxTs, so the file
+// soonest to expire is always at the root: popping expired entries is
+// O(log N) instead of re-scanning and re-sorting every file on every
+// cleaner tick.
+type usageHeap []usageEntry
+
+func (h usageHeap) Len() int            { return len(h) }
+func (h usageHeap) Less(i, j int) bool  { return h[i].MaxTs < h[j].MaxTs }
+func (h usageHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *usageHeap) Push(x interface{}) { *h = append(*h, x.(usageEntry)) }
+
+func (h *usageHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}
+
+// usageIndexSnapshot is UsageIndex's on-disk form. DirMTime is the
+// storage directory's mtime as last observed *before* this snapshot was
+// written (see save), so it's a fast, if imperfect, "nothing has changed
+// since" check; ContentHash is the authoritative one, a order-independent
+// hash of exactly the .nano files Entries describes, which - unlike
+// DirMTime - this index's own sidecar rewrite can never perturb.
+type usageIndexSnapshot struct {
+	DirMTime    time.Time
+	ContentHash uint64
+	Entries     []usageEntry
+}
+
+// contentHash summarizes files' identities so the same set of .nano
+// files always hashes the same regardless of what order List happened to
+// return them in. It only covers MinTs/MaxTs (List gives us nothing
+// else), which is enough to detect files appearing or disappearing -
+// exactly what would make a cached UsageIndex wrong.
+func contentHash(files []storage.FileDesc) uint64 {
+	sorted := append([]storage.FileDesc(nil), files...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].MinTs != sorted[j].MinTs {
+			return sorted[i].MinTs < sorted[j].MinTs
+		}
+		return sorted[i].MaxTs < sorted[j].MaxTs
+	})
+
+	h := fnv.New64a()
+	for _, fd := range sorted {
+		fmt.Fprintf(h, "%d:%d;", fd.MinTs, fd.MaxTs)
+	}
+	return h.Sum64()
+}
+
+// UsageIndex is the cleaner's persistent view of which .nano files exist
+// and when they expire. It's mutated in place as files are flushed
+// (Add) and deleted (Remove), persisting the change back to its
+// SidecarStorage each time, so a restart loads it in one ReadSidecar
+// instead of re-listing and re-parsing the whole data directory.
+type UsageIndex struct {
+	mu    sync.Mutex
+	heap  usageHeap
+	store storage.SidecarStorage
+}
+
+// LoadUsageIndex loads store's persisted usage index, rebuilding it from
+// a full store.List scan - self-healing - if the sidecar is missing,
+// corrupt, or stale: its recorded dir mtime no longer matches (the cheap
+// case), and recomputing ContentHash from a fresh List disagrees too
+// (the only case that actually means the .nano files themselves changed).
+func LoadUsageIndex(store storage.SidecarStorage) (*UsageIndex, error) {
+	idx := &UsageIndex{store: store}
+
+	if entries, ok := idx.tryLoad(); ok {
+		idx.heap = entries
+		heap.Init(&idx.heap)
+		return idx, nil
+	}
+
+	if err := idx.rebuildLocked(); err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+// RebuildUsageIndex forces a full store.List rescan and overwrites
+// store's sidecar with the result, regardless of whether an existing one
+// looks fresh. It backs the `nanolog rebuild-index` subcommand for an
+// operator who changed the data directory out from under a stopped
+// server (restored a backup, manually deleted files) and doesn't want to
+// wait for the next start's staleness check to notice.
+func RebuildUsageIndex(store storage.SidecarStorage) (*UsageIndex, error) {
+	idx := &UsageIndex{store: store}
+	if err := idx.rebuildLocked(); err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+// tryLoad attempts to read and validate store's persisted sidecar,
+// reporting ok=false for anything that should trigger a rebuild instead.
+func (idx *UsageIndex) tryLoad() (usageHeap, bool) {
+	data, err := idx.store.ReadSidecar(usageIndexFile)
+	if err != nil {
+		return nil, false
+	}
+
+	var snap usageIndexSnapshot
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&snap); err != nil {
+		return nil, false
+	}
+
+	mtime, err := idx.store.DirModTime()
+	if err != nil {
+		return nil, false
+	}
+	if mtime.Equal(snap.DirMTime) {
+		// Nothing has touched the directory since save last stat'd it -
+		// trust the sidecar outright without even a List.
+		return snap.Entries, true
+	}
+
+	// The mtime moved on - which includes this index's own previous
+	// sidecar rewrite, not just real .nano churn - so fall back to the
+	// authoritative check: re-list and compare hashes.
+	files, err := idx.store.List(storage.KindNano)
+	if err != nil {
+		return nil, false
+	}
+	if contentHash(files) != snap.ContentHash {
+		return nil, false
+	}
+	return snap.Entries, true
+}
+
+// rebuildLocked replaces idx's in-memory state with a fresh store.List
+// scan and persists it. Despite the name it takes idx.mu itself, so the
+// caller must not already hold it.
+func (idx *UsageIndex) rebuildLocked() error {
+	files, err := idx.store.List(storage.KindNano)
+	if err != nil {
+		return err
+	}
+
+	h := make(usageHeap, 0, len(files))
+	for _, fd := range files {
+		h = append(h, usageEntry{MinTs: fd.MinTs, MaxTs: fd.MaxTs})
+	}
+	heap.Init(&h)
+
+	idx.mu.Lock()
+	idx.heap = h
+	idx.mu.Unlock()
+
+	return idx.save()
+}
+
+// Add records a newly flushed file in the index and persists the
+// change, so the next cleaner tick sees it without a rescan.
+func (idx *UsageIndex) Add(fd storage.FileDesc) error {
+	idx.mu.Lock()
+	heap.Push(&idx.heap, usageEntry{MinTs: fd.MinTs, MaxTs: fd.MaxTs})
+	idx.mu.Unlock()
+	return idx.save()
+}
+
+// Remove drops fd from the index - a no-op if it isn't present, e.g. a
+// file removed by something other than the cleaner - and persists the
+// change.
+func (idx *UsageIndex) Remove(fd storage.FileDesc) error {
+	idx.mu.Lock()
+	for i, e := range idx.heap {
+		if e.MinTs == fd.MinTs && e.MaxTs == fd.MaxTs {
+			heap.Remove(&idx.heap, i)
+			break
+		}
+	}
+	idx.mu.Unlock()
+	return idx.save()
+}
+
+// PopExpired removes and returns every indexed file with MaxTs below
+// threshold, in O(log N) per entry - the single-global-retention fast
+// path. purgeExpiredFiles falls back to Snapshot when per-file
+// RetentionPolicy matching is in play, since that requires reading each
+// file's manifest regardless of how the file list was produced.
+func (idx *UsageIndex) PopExpired(threshold int64) []storage.FileDesc {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	var expired []storage.FileDesc
+	for idx.heap.Len() > 0 && idx.heap[0].MaxTs < threshold {
+		e := heap.Pop(&idx.heap).(usageEntry)
+		expired = append(expired, e.fileDesc())
+	}
+	return expired
+}
+
+// Snapshot returns the storage.FileDesc of every currently indexed file,
+// in no particular order, without removing any of them.
+func (idx *UsageIndex) Snapshot() []storage.FileDesc {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	out := make([]storage.FileDesc, len(idx.heap))
+	for i, e := range idx.heap {
+		out[i] = e.fileDesc()
+	}
+	return out
+}
+
+// save persists idx's current entries, the directory mtime observed just
+// before doing so, and their content hash. DirMTime is stat'd before the
+// write rather than after deliberately: WriteSidecar itself (a tmp file
+// create plus rename) bumps the directory's mtime, so stamping the
+// post-write value would make every save invalidate itself on the very
+// next load. Recording the pre-write value means the mtime fast path
+// only hits when nothing - including a prior save - has touched the
+// directory since; ContentHash, computed purely from the .nano files
+// idx.heap tracks, is what tryLoad actually trusts otherwise.
+func (idx *UsageIndex) save() error {
+	mtime, err := idx.store.DirModTime()
+	if err != nil {
+		return err
+	}
+
+	idx.mu.Lock()
+	entries := append([]usageEntry(nil), idx.heap...)
+	idx.mu.Unlock()
+
+	files := make([]storage.FileDesc, len(entries))
+	for i, e := range entries {
+		files[i] = e.fileDesc()
+	}
+
+	snap := usageIndexSnapshot{DirMTime: mtime, ContentHash: contentHash(files), Entries: entries}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(snap); err != nil {
+		return err
+	}
+	return idx.store.WriteSidecar(usageIndexFile, buf.Bytes())
+}
@@ -0,0 +1,140 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/coffersTech/nanolog/server/internal/engine/storage"
+)
+
+func TestUsageIndex_AddPopExpired(t *testing.T) {
+	store, err := storage.NewFileStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStorage: %v", err)
+	}
+
+	idx, err := LoadUsageIndex(store)
+	if err != nil {
+		t.Fatalf("LoadUsageIndex: %v", err)
+	}
+
+	old := storage.FileDesc{Kind: storage.KindNano, MinTs: 100, MaxTs: 200}
+	fresh := storage.FileDesc{Kind: storage.KindNano, MinTs: 900, MaxTs: 1000}
+	if err := idx.Add(old); err != nil {
+		t.Fatalf("Add(old): %v", err)
+	}
+	if err := idx.Add(fresh); err != nil {
+		t.Fatalf("Add(fresh): %v", err)
+	}
+
+	expired := idx.PopExpired(500)
+	if len(expired) != 1 || expired[0] != old {
+		t.Fatalf("expected [%v], got %v", old, expired)
+	}
+
+	remaining := idx.Snapshot()
+	if len(remaining) != 1 || remaining[0] != fresh {
+		t.Fatalf("expected only %v left indexed, got %v", fresh, remaining)
+	}
+}
+
+func TestUsageIndex_RemoveIsNoopForUnknownFile(t *testing.T) {
+	store, err := storage.NewFileStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStorage: %v", err)
+	}
+	idx, err := LoadUsageIndex(store)
+	if err != nil {
+		t.Fatalf("LoadUsageIndex: %v", err)
+	}
+
+	unknown := storage.FileDesc{Kind: storage.KindNano, MinTs: 1, MaxTs: 2}
+	if err := idx.Remove(unknown); err != nil {
+		t.Fatalf("Remove of an unindexed file should be a no-op, got: %v", err)
+	}
+}
+
+func TestUsageIndex_PersistsAcrossLoad(t *testing.T) {
+	dir := t.TempDir()
+	store, err := storage.NewFileStorage(dir)
+	if err != nil {
+		t.Fatalf("NewFileStorage: %v", err)
+	}
+
+	idx, err := LoadUsageIndex(store)
+	if err != nil {
+		t.Fatalf("LoadUsageIndex: %v", err)
+	}
+	fd := storage.FileDesc{Kind: storage.KindNano, MinTs: 10, MaxTs: 20}
+	w, err := store.Create(fd)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := w.Write([]byte("x")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := idx.Add(fd); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	reloaded, err := LoadUsageIndex(store)
+	if err != nil {
+		t.Fatalf("LoadUsageIndex (reload): %v", err)
+	}
+	got := reloaded.Snapshot()
+	if len(got) != 1 || got[0] != fd {
+		t.Fatalf("expected the persisted entry %v to survive a reload, got %v", fd, got)
+	}
+}
+
+func TestUsageIndex_RebuildsWhenNanoFilesChangeBehindItsBack(t *testing.T) {
+	dir := t.TempDir()
+	store, err := storage.NewFileStorage(dir)
+	if err != nil {
+		t.Fatalf("NewFileStorage: %v", err)
+	}
+
+	idx, err := LoadUsageIndex(store)
+	if err != nil {
+		t.Fatalf("LoadUsageIndex: %v", err)
+	}
+	fd := storage.FileDesc{Kind: storage.KindNano, MinTs: 10, MaxTs: 20}
+	fw, err := store.Create(fd)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := fw.Write([]byte("x")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := fw.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := idx.Add(fd); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	// Simulate a file dropped into the data directory by something other
+	// than Add/Remove (a restore from backup), which the index doesn't
+	// know about.
+	w, err := store.Create(storage.FileDesc{Kind: storage.KindNano, MinTs: 30, MaxTs: 40})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := w.Write([]byte("x")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	reloaded, err := LoadUsageIndex(store)
+	if err != nil {
+		t.Fatalf("LoadUsageIndex (reload): %v", err)
+	}
+	got := reloaded.Snapshot()
+	if len(got) != 2 {
+		t.Fatalf("expected the out-of-band file to be picked up by a self-healed rebuild, got %v", got)
+	}
+}
@@ -3,32 +3,184 @@ package engine
 import (
 	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"hash/crc32"
 	"io"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 )
 
-// WAL handles write-ahead logging to prevent data loss during crashes.
+// recordMagic tags the start of every WAL record so Replay can tell a real
+// record header from garbage left behind by a torn write.
+const recordMagic uint8 = 0xA5
+
+// crc32cTable is the Castagnoli table, matching the framing LevelDB uses
+// for its own journal/batch records.
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+// DefaultMaxSegmentBytes is the segment size WAL rotates at unless
+// MaxSegmentBytes is set to something else after OpenWAL.
+const DefaultMaxSegmentBytes int64 = 128 * 1024 * 1024
+
+const (
+	walSegmentPrefix = "wal-"
+	walSegmentSuffix = ".log"
+	walManifestName  = "wal.manifest"
+)
+
+// ErrTornTail is returned by Replay when the WAL's most recent segment ends
+// in a partially-written or corrupted record. The caller should treat any
+// rows already recovered as durable and call TruncateTornTail before
+// resuming writes.
+var ErrTornTail = errors.New("wal: torn tail detected")
+
+// WAL is a directory of append-only segments (wal-<seq>.log). Write appends
+// to the active segment and rotates to a new one once it reaches
+// MaxSegmentBytes, so a single unbounded file is never truncated or rewound
+// under lock the way the old single-file design was. Rotate lets callers
+// force a boundary (QueryEngine uses this when swapping MemTables) so each
+// MemTable's records land in a pinned, non-overlapping segment range:
+// Checkpoint then only deletes the segments a completed flush actually
+// covers, instead of wiping everything written since.
+//
+// Record format: [magic uint8][len uint32][crc32c uint32][payload]
+// The CRC covers len||payload so a corrupted length field is caught before
+// we ever try to read `len` bytes of payload.
 type WAL struct {
-	file *os.File
-	path string
-	mu   sync.Mutex
+	dir string
+	mu  sync.Mutex
+
+	MaxSegmentBytes int64
+
+	active     *os.File
+	activeSeq  uint64
+	activeSize int64
+
+	checkpoint uint64 // segments with seq <= checkpoint are safe to delete
+
+	// tornSeq/tornOffset remember what Replay found torn, for
+	// TruncateTornTail to act on. tornSeq == 0 means nothing is pending.
+	tornSeq    uint64
+	tornOffset int64
 }
 
-// OpenWAL opens or creates a WAL file at the specified path.
-func OpenWAL(path string) (*WAL, error) {
-	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0644)
+// OpenWAL opens or creates the WAL directory dir, resuming from whatever
+// checkpoint and segments are already there.
+func OpenWAL(dir string) (*WAL, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	checkpoint, err := readWALCheckpoint(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	segs, err := listSegments(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	seq := uint64(1)
+	if len(segs) > 0 {
+		seq = segs[len(segs)-1]
+	}
+
+	f, err := openSegment(dir, seq)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
 	if err != nil {
+		f.Close()
 		return nil, err
 	}
+
 	return &WAL{
-		file: f,
-		path: path,
+		dir:             dir,
+		MaxSegmentBytes: DefaultMaxSegmentBytes,
+		active:          f,
+		activeSeq:       seq,
+		activeSize:      info.Size(),
+		checkpoint:      checkpoint,
 	}, nil
 }
 
-// Write records a log row to the WAL.
+func openSegment(dir string, seq uint64) (*os.File, error) {
+	return os.OpenFile(segmentPath(dir, seq), os.O_APPEND|os.O_CREATE|os.O_RDWR, 0644)
+}
+
+func segmentPath(dir string, seq uint64) string {
+	return filepath.Join(dir, fmt.Sprintf("%s%020d%s", walSegmentPrefix, seq, walSegmentSuffix))
+}
+
+func parseSegmentName(name string) (uint64, bool) {
+	if !strings.HasPrefix(name, walSegmentPrefix) || !strings.HasSuffix(name, walSegmentSuffix) {
+		return 0, false
+	}
+	numPart := strings.TrimSuffix(strings.TrimPrefix(name, walSegmentPrefix), walSegmentSuffix)
+	seq, err := strconv.ParseUint(numPart, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return seq, true
+}
+
+// listSegments returns every segment sequence number found in dir, sorted
+// ascending.
+func listSegments(dir string) ([]uint64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var segs []uint64
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		if seq, ok := parseSegmentName(e.Name()); ok {
+			segs = append(segs, seq)
+		}
+	}
+	sort.Slice(segs, func(i, j int) bool { return segs[i] < segs[j] })
+	return segs, nil
+}
+
+func readWALCheckpoint(dir string) (uint64, error) {
+	data, err := os.ReadFile(filepath.Join(dir, walManifestName))
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	seq, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		// A corrupt manifest shouldn't prevent startup; worst case we
+		// replay (and keep) segments that were already flushed.
+		return 0, nil
+	}
+	return seq, nil
+}
+
+// writeWALCheckpoint durably records seq as the checkpoint via a
+// temp-file rename, so a crash mid-write never leaves a half-written
+// manifest behind.
+func writeWALCheckpoint(dir string, seq uint64) error {
+	tmp := filepath.Join(dir, walManifestName+".tmp")
+	if err := os.WriteFile(tmp, []byte(strconv.FormatUint(seq, 10)), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(dir, walManifestName))
+}
+
+// Write records a log row to the active segment, rotating to a new segment
+// first if the record would push it past MaxSegmentBytes.
 func (w *WAL) Write(ts int64, level, service, host, msg string) error {
 	w.mu.Lock()
 	defer w.mu.Unlock()
@@ -46,76 +198,308 @@ func (w *WAL) Write(ts int64, level, service, host, msg string) error {
 		return err
 	}
 
-	// Format: [Len uint32][JSON Bytes]
 	lenBuf := make([]byte, 4)
 	binary.LittleEndian.PutUint32(lenBuf, uint32(len(data)))
+	crc := crc32.Checksum(append(lenBuf, data...), crc32cTable)
+	crcBuf := make([]byte, 4)
+	binary.LittleEndian.PutUint32(crcBuf, crc)
 
-	if _, err := w.file.Write(lenBuf); err != nil {
+	record := make([]byte, 0, 1+4+4+len(data))
+	record = append(record, recordMagic)
+	record = append(record, lenBuf...)
+	record = append(record, crcBuf...)
+	record = append(record, data...)
+
+	if w.activeSize > 0 && w.activeSize+int64(len(record)) > w.MaxSegmentBytes {
+		if err := w.rotateLocked(); err != nil {
+			return err
+		}
+	}
+
+	n, err := w.active.Write(record)
+	w.activeSize += int64(n)
+	return err
+}
+
+// Rotate forces the WAL to seal the active segment and start a new one,
+// returning the sequence number of the segment that was just sealed.
+// QueryEngine calls this when swapping MemTables so every table's records
+// land in a segment range nothing else will ever write into.
+func (w *WAL) Rotate() (uint64, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	closedSeq := w.activeSeq
+	if err := w.rotateLocked(); err != nil {
+		return 0, err
+	}
+	return closedSeq, nil
+}
+
+func (w *WAL) rotateLocked() error {
+	if err := w.active.Close(); err != nil {
 		return err
 	}
-	if _, err := w.file.Write(data); err != nil {
+	w.activeSeq++
+	f, err := openSegment(w.dir, w.activeSeq)
+	if err != nil {
 		return err
 	}
-
+	w.active = f
+	w.activeSize = 0
 	return nil
 }
 
-// Sync flushes the WAL file buffers to disk.
-func (w *WAL) Sync() error {
+// ActiveSeq returns the sequence number of the segment currently being
+// appended to.
+func (w *WAL) ActiveSeq() uint64 {
 	w.mu.Lock()
 	defer w.mu.Unlock()
-	return w.file.Sync()
+	return w.activeSeq
 }
 
-// Reset truncates the WAL file.
-func (w *WAL) Reset() error {
+// Checkpoint records segSeq as the new durable low-water mark: every
+// segment with seq <= segSeq has been flushed to a .nano file and is no
+// longer needed for crash recovery. It persists the mark to wal.manifest
+// and removes the now-covered segment files. The active segment is never
+// removed, even if its seq is <= segSeq. Checkpoint never moves the mark
+// backwards, so calls from out-of-order flush completions are harmless.
+func (w *WAL) Checkpoint(segSeq uint64) error {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
-	if err := w.file.Truncate(0); err != nil {
+	if segSeq > w.checkpoint {
+		w.checkpoint = segSeq
+	}
+	if err := writeWALCheckpoint(w.dir, w.checkpoint); err != nil {
 		return err
 	}
-	_, err := w.file.Seek(0, 0)
-	return err
+
+	segs, err := listSegments(w.dir)
+	if err != nil {
+		return err
+	}
+	for _, seq := range segs {
+		if seq > w.checkpoint || seq == w.activeSeq {
+			continue
+		}
+		if err := os.Remove(segmentPath(w.dir, seq)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("wal: removing checkpointed segment %d: %w", seq, err)
+		}
+	}
+	return nil
+}
+
+// Sync flushes the active segment's buffers to disk.
+func (w *WAL) Sync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.active.Sync()
 }
 
-// Close closes the WAL file.
+// Close closes the active segment.
 func (w *WAL) Close() error {
-	return w.file.Close()
+	return w.active.Close()
+}
+
+// readRecord reads one record starting at r's current offset. It returns
+// the raw payload, the number of bytes consumed, and an error. A short read
+// of the magic byte (and nothing else yet written) surfaces as io.EOF; any
+// other short read or a CRC/magic mismatch surfaces as ErrTornTail.
+func readRecord(r io.Reader) (payload []byte, consumed int64, err error) {
+	magic := make([]byte, 1)
+	if _, err := io.ReadFull(r, magic); err != nil {
+		if err == io.EOF {
+			return nil, 0, io.EOF
+		}
+		return nil, 0, ErrTornTail
+	}
+	if magic[0] != recordMagic {
+		return nil, 1, ErrTornTail
+	}
+
+	lenBuf := make([]byte, 4)
+	if _, err := io.ReadFull(r, lenBuf); err != nil {
+		return nil, 1, ErrTornTail
+	}
+
+	crcBuf := make([]byte, 4)
+	if _, err := io.ReadFull(r, crcBuf); err != nil {
+		return nil, 5, ErrTornTail
+	}
+
+	length := binary.LittleEndian.Uint32(lenBuf)
+	if int64(length) > DefaultMaxSegmentBytes {
+		// A torn write can leave lenBuf holding arbitrary bytes; without
+		// this cap a corrupt length would drive a multi-gigabyte
+		// allocation before the CRC check below ever gets a chance to
+		// reject the record.
+		return nil, 9, ErrTornTail
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, 9, ErrTornTail
+	}
+
+	wantCRC := binary.LittleEndian.Uint32(crcBuf)
+	gotCRC := crc32.Checksum(append(lenBuf, data...), crc32cTable)
+	if gotCRC != wantCRC {
+		return nil, 9 + int64(length), ErrTornTail
+	}
+
+	return data, 9 + int64(length), nil
 }
 
-// Replay reads the WAL and returns all log rows.
+// Replay reads every segment after the checkpoint, in order, and returns
+// all recovered log rows. If the most recent segment ends in a torn record,
+// Replay returns the good prefix together with ErrTornTail and remembers
+// where to cut; the caller should follow up with TruncateTornTail before
+// resuming writes.
 func (w *WAL) Replay() ([]LogRow, error) {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
-	if _, err := w.file.Seek(0, 0); err != nil {
+	segs, err := listSegments(w.dir)
+	if err != nil {
 		return nil, err
 	}
 
 	var rows []LogRow
-	for {
-		lenBuf := make([]byte, 4)
-		_, err := io.ReadFull(w.file, lenBuf)
-		if err == io.EOF {
-			break
+	for _, seq := range segs {
+		if seq <= w.checkpoint {
+			continue
+		}
+
+		segRows, tornOffset, err := w.replaySegmentLocked(seq)
+		rows = append(rows, segRows...)
+		if err == ErrTornTail {
+			w.tornSeq = seq
+			w.tornOffset = tornOffset
+			return rows, ErrTornTail
 		}
 		if err != nil {
-			return rows, fmt.Errorf("WAL replay error (len): %v", err)
+			return rows, fmt.Errorf("WAL replay error (segment %d): %w", seq, err)
 		}
+	}
+	return rows, nil
+}
 
-		length := binary.LittleEndian.Uint32(lenBuf)
-		data := make([]byte, length)
-		if _, err := io.ReadFull(w.file, data); err != nil {
-			return rows, fmt.Errorf("WAL replay error (data): %v", err)
+// replaySegmentLocked reads every record from segment seq. Caller holds
+// w.mu. It returns the rows, the byte offset to truncate at if the tail is
+// torn, and ErrTornTail in that case.
+func (w *WAL) replaySegmentLocked(seq uint64) ([]LogRow, int64, error) {
+	var r io.Reader
+	if seq == w.activeSeq {
+		if _, err := w.active.Seek(0, io.SeekStart); err != nil {
+			return nil, 0, err
+		}
+		r = w.active
+	} else {
+		f, err := os.Open(segmentPath(w.dir, seq))
+		if err != nil {
+			return nil, 0, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var rows []LogRow
+	var offset int64
+	for {
+		data, consumed, err := readRecord(r)
+		if err == io.EOF {
+			return rows, -1, nil
+		}
+		if err == ErrTornTail {
+			return rows, offset, ErrTornTail
+		}
+		if err != nil {
+			return rows, offset, err
 		}
 
 		var row LogRow
-		if err := json.Unmarshal(data, &row); err != nil {
-			return rows, fmt.Errorf("WAL replay error (unmarshal): %v", err)
+		if jsonErr := json.Unmarshal(data, &row); jsonErr != nil {
+			// A structurally valid, CRC-correct record that fails to
+			// unmarshal is still a form of corruption we want to stop at
+			// rather than propagate as a fatal error.
+			return rows, offset, ErrTornTail
 		}
 		rows = append(rows, row)
+		offset += consumed
 	}
+}
 
-	return rows, nil
+// TruncateTornTail truncates the segment Replay most recently found torn,
+// cutting off the dangling partial record at the offset Replay recorded.
+// It is a no-op if Replay hasn't reported a torn tail.
+func (w *WAL) TruncateTornTail() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.tornSeq == 0 {
+		return nil
+	}
+	defer func() { w.tornSeq = 0 }()
+
+	if w.tornSeq == w.activeSeq {
+		if err := w.active.Truncate(w.tornOffset); err != nil {
+			return err
+		}
+		w.activeSize = w.tornOffset
+		_, err := w.active.Seek(0, io.SeekEnd)
+		return err
+	}
+
+	f, err := os.OpenFile(segmentPath(w.dir, w.tornSeq), os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return f.Truncate(w.tornOffset)
+}
+
+// Verify walks the active segment without decoding JSON payloads and
+// reports the byte offset of its first bad record, or -1 if the active
+// segment is entirely well-formed. Sealed segments are never rewritten
+// after rotation, so only the active one can still be torn; Replay covers
+// all of them regardless, for defense in depth.
+func (w *WAL) Verify() (int64, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, err := w.active.Seek(0, io.SeekStart); err != nil {
+		return -1, err
+	}
+
+	var offset int64
+	for {
+		_, consumed, err := readRecord(w.active)
+		if err == io.EOF {
+			return -1, nil
+		}
+		if err == ErrTornTail {
+			return offset, nil
+		}
+		if err != nil {
+			return offset, err
+		}
+		offset += consumed
+	}
+}
+
+// Truncate drops everything in the active segment past offset, in place.
+// Unlike TruncateTornTail, offset is caller-supplied - typically the value
+// Verify just returned - rather than recalled from Replay's own torn-tail
+// bookkeeping, so a caller can verify and repair the WAL without first
+// replaying every row back into memory.
+func (w *WAL) Truncate(offset int64) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.active.Truncate(offset); err != nil {
+		return err
+	}
+	w.activeSize = offset
+	_, err := w.active.Seek(0, io.SeekEnd)
+	return err
 }
@@ -0,0 +1,309 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newTestWAL(t *testing.T) (*WAL, string) {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "wal")
+	w, err := OpenWAL(dir)
+	if err != nil {
+		t.Fatalf("OpenWAL: %v", err)
+	}
+	return w, dir
+}
+
+func activeSegmentSize(t *testing.T, w *WAL) int64 {
+	t.Helper()
+	info, err := os.Stat(segmentPath(w.dir, w.ActiveSeq()))
+	if err != nil {
+		t.Fatalf("stat active segment: %v", err)
+	}
+	return info.Size()
+}
+
+func flipByte(t *testing.T, path string, offset int64) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatalf("open for flip: %v", err)
+	}
+	defer f.Close()
+
+	b := make([]byte, 1)
+	if _, err := f.ReadAt(b, offset); err != nil {
+		t.Fatalf("read for flip: %v", err)
+	}
+	b[0] ^= 0xFF
+	if _, err := f.WriteAt(b, offset); err != nil {
+		t.Fatalf("write for flip: %v", err)
+	}
+}
+
+func TestWALReplayGoodRecords(t *testing.T) {
+	w, _ := newTestWAL(t)
+	defer w.Close()
+
+	if err := w.Write(1, "INFO", "svc-a", "host-a", "hello"); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := w.Write(2, "ERROR", "svc-b", "host-b", "world"); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	rows, err := w.Replay()
+	if err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("expected 2 rows, got %d", len(rows))
+	}
+	if rows[0].Message != "hello" || rows[1].Message != "world" {
+		t.Errorf("unexpected rows: %+v", rows)
+	}
+}
+
+func TestWALReplayTornTailLengthCorruption(t *testing.T) {
+	w, dir := newTestWAL(t)
+	if err := w.Write(1, "INFO", "svc-a", "host-a", "good"); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	firstRecordEnd := activeSegmentSize(t, w)
+	if err := w.Write(2, "INFO", "svc-a", "host-a", "torn"); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	segPath := segmentPath(dir, w.ActiveSeq())
+	w.Close()
+
+	// Flip a bit in the length field of the second record (offset +1 skips the magic byte).
+	flipByte(t, segPath, firstRecordEnd+1)
+
+	w2, err := OpenWAL(dir)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer w2.Close()
+
+	rows, err := w2.Replay()
+	if err != ErrTornTail {
+		t.Fatalf("expected ErrTornTail, got %v", err)
+	}
+	if len(rows) != 1 || rows[0].Message != "good" {
+		t.Fatalf("expected only the good prefix, got %+v", rows)
+	}
+}
+
+func TestWALReplayTornTailCRCCorruption(t *testing.T) {
+	w, dir := newTestWAL(t)
+	if err := w.Write(1, "INFO", "svc-a", "host-a", "good"); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	firstRecordEnd := activeSegmentSize(t, w)
+	if err := w.Write(2, "INFO", "svc-a", "host-a", "torn"); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	segPath := segmentPath(dir, w.ActiveSeq())
+	w.Close()
+
+	// Flip a byte inside the second record's payload (past its 9-byte header),
+	// leaving the length field intact so only the CRC check can catch it.
+	flipByte(t, segPath, firstRecordEnd+9)
+
+	w2, err := OpenWAL(dir)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer w2.Close()
+
+	rows, err := w2.Replay()
+	if err != ErrTornTail {
+		t.Fatalf("expected ErrTornTail, got %v", err)
+	}
+	if len(rows) != 1 || rows[0].Message != "good" {
+		t.Fatalf("expected only the good prefix, got %+v", rows)
+	}
+}
+
+func TestWALReplayTornTailShortWrite(t *testing.T) {
+	w, dir := newTestWAL(t)
+	if err := w.Write(1, "INFO", "svc-a", "host-a", "good"); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	segPath := segmentPath(dir, w.ActiveSeq())
+	w.Close()
+
+	// Simulate a crash mid-write: append a truncated record header.
+	f, err := os.OpenFile(segPath, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("open for append: %v", err)
+	}
+	if _, err := f.Write([]byte{recordMagic, 0x10, 0x00}); err != nil {
+		t.Fatalf("append partial record: %v", err)
+	}
+	f.Close()
+
+	w2, err := OpenWAL(dir)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer w2.Close()
+
+	rows, err := w2.Replay()
+	if err != ErrTornTail {
+		t.Fatalf("expected ErrTornTail, got %v", err)
+	}
+	if len(rows) != 1 || rows[0].Message != "good" {
+		t.Fatalf("expected only the good prefix, got %+v", rows)
+	}
+}
+
+func TestWALTruncateTornTail(t *testing.T) {
+	w, dir := newTestWAL(t)
+	if err := w.Write(1, "INFO", "svc-a", "host-a", "good"); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	goodEnd := activeSegmentSize(t, w)
+	if err := w.Write(2, "INFO", "svc-a", "host-a", "torn"); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	segPath := segmentPath(dir, w.ActiveSeq())
+	w.Close()
+
+	flipByte(t, segPath, goodEnd+10)
+
+	w2, err := OpenWAL(dir)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer w2.Close()
+
+	rows, err := w2.Replay()
+	if err != ErrTornTail {
+		t.Fatalf("expected ErrTornTail, got %v", err)
+	}
+	if len(rows) != 1 {
+		t.Fatalf("expected 1 good row before truncation, got %d", len(rows))
+	}
+
+	if err := w2.TruncateTornTail(); err != nil {
+		t.Fatalf("TruncateTornTail: %v", err)
+	}
+
+	rows, err = w2.Replay()
+	if err != nil {
+		t.Fatalf("Replay after truncate: %v", err)
+	}
+	if len(rows) != 1 {
+		t.Fatalf("expected 1 row after truncate, got %d", len(rows))
+	}
+}
+
+func TestWALVerifyAndTruncate(t *testing.T) {
+	w, dir := newTestWAL(t)
+	if err := w.Write(1, "INFO", "svc-a", "host-a", "good"); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if offset, err := w.Verify(); err != nil || offset != -1 {
+		t.Fatalf("Verify on well-formed WAL: offset=%d err=%v", offset, err)
+	}
+
+	goodEnd := activeSegmentSize(t, w)
+	if err := w.Write(2, "INFO", "svc-a", "host-a", "torn"); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	segPath := segmentPath(dir, w.ActiveSeq())
+	w.Close()
+
+	flipByte(t, segPath, goodEnd+10)
+
+	w2, err := OpenWAL(dir)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer w2.Close()
+
+	badOffset, err := w2.Verify()
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if badOffset != goodEnd {
+		t.Fatalf("expected bad offset %d, got %d", goodEnd, badOffset)
+	}
+
+	if err := w2.Truncate(badOffset); err != nil {
+		t.Fatalf("Truncate: %v", err)
+	}
+
+	rows, err := w2.Replay()
+	if err != nil {
+		t.Fatalf("Replay after truncate: %v", err)
+	}
+	if len(rows) != 1 || rows[0].Message != "good" {
+		t.Fatalf("expected only the good row after truncate, got %+v", rows)
+	}
+}
+
+func TestWALRotationOnSize(t *testing.T) {
+	w, dir := newTestWAL(t)
+	defer w.Close()
+
+	w.MaxSegmentBytes = 64 // force rotation on nearly every record
+
+	for i := 0; i < 20; i++ {
+		if err := w.Write(int64(i), "INFO", "svc-a", "host-a", "hello world"); err != nil {
+			t.Fatalf("Write %d: %v", i, err)
+		}
+	}
+
+	segs, err := listSegments(dir)
+	if err != nil {
+		t.Fatalf("listSegments: %v", err)
+	}
+	if len(segs) < 2 {
+		t.Fatalf("expected multiple segments from rotation, got %d", len(segs))
+	}
+
+	rows, err := w.Replay()
+	if err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+	if len(rows) != 20 {
+		t.Fatalf("expected 20 rows across segments, got %d", len(rows))
+	}
+}
+
+func TestWALCheckpointDeletesOnlyCoveredSegments(t *testing.T) {
+	w, dir := newTestWAL(t)
+	defer w.Close()
+
+	if err := w.Write(1, "INFO", "svc-a", "host-a", "first"); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	firstSeq, err := w.Rotate()
+	if err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+
+	if err := w.Write(2, "INFO", "svc-a", "host-a", "second"); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	if err := w.Checkpoint(firstSeq); err != nil {
+		t.Fatalf("Checkpoint: %v", err)
+	}
+
+	if _, err := os.Stat(segmentPath(dir, firstSeq)); !os.IsNotExist(err) {
+		t.Errorf("expected checkpointed segment %d to be removed, stat err=%v", firstSeq, err)
+	}
+
+	rows, err := w.Replay()
+	if err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+	if len(rows) != 1 || rows[0].Message != "second" {
+		t.Fatalf("expected only the post-checkpoint record to survive, got %+v", rows)
+	}
+}
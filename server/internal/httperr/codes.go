@@ -0,0 +1,29 @@
+package httperr
+
+import "net/http"
+
+// Stable, machine-readable error codes returned in Error.Code.
+// Programmatic clients should switch on these, not on Message, which may
+// change wording without notice.
+const (
+	CodeAuthMissingToken         = "AUTH_MISSING_TOKEN"
+	CodeAuthExpired              = "AUTH_EXPIRED"
+	CodeAuthInvalidUser          = "AUTH_INVALID_CREDENTIALS"
+	CodeAuthForbidden            = "AUTH_FORBIDDEN"
+	CodeIngestInvalidJSON        = "INGEST_INVALID_JSON"
+	CodeQueryBadFilter           = "QUERY_BAD_FILTER"
+	CodeConfigConflict           = "CONFIG_CONFLICT"
+	CodeClusterAggregationFailed = "CLUSTER_AGGREGATION_FAILED"
+	CodeTooManyStreams           = "TOO_MANY_STREAMS"
+	CodeRateLimited              = "RATE_LIMITED"
+	CodeMethodNotAllowed         = "METHOD_NOT_ALLOWED"
+	CodeBadRequest               = "BAD_REQUEST"
+	CodeNotFound                 = "NOT_FOUND"
+	CodeInternal                 = "INTERNAL_ERROR"
+)
+
+// MethodNotAllowed is a convenience constructor for the single most common
+// error across handlers in this package's callers.
+func MethodNotAllowed() *Error {
+	return New(http.StatusMethodNotAllowed, CodeMethodNotAllowed, "method not allowed")
+}
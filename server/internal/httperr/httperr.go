@@ -0,0 +1,51 @@
+// Package httperr is nanolog's typed HTTP error body, modeled on etcd's
+// httptypes.HTTPError: every handler that fails returns one of these
+// instead of calling http.Error with a plain-text string, so programmatic
+// clients (the Go SDK, future SDKs) can switch on a stable Code instead of
+// parsing prose, while the JSON body still carries a human Message for
+// whoever's looking at it in a browser or curl.
+package httperr
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Error is the JSON body written for a failed request. Status is the HTTP
+// status code it's written with; it's excluded from the body since it's
+// already on the response line. RequestID, when set, lets an operator
+// correlate this response with the structured log line the request-ID
+// middleware wrote for the same request.
+type Error struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	Status    int    `json:"-"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// New creates an Error with no RequestID set; callers typically chain
+// WithRequestID before WriteTo.
+func New(status int, code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+// WithRequestID returns a copy of e with RequestID set. It copies rather
+// than mutating so the same *Error can be built once per failure mode and
+// reused across requests without a data race.
+func (e Error) WithRequestID(id string) *Error {
+	e.RequestID = id
+	return &e
+}
+
+func (e *Error) Error() string { return e.Message }
+
+// WriteTo writes e as a JSON body with status e.Status, stamping
+// X-Request-ID on the response if RequestID is set.
+func (e *Error) WriteTo(w http.ResponseWriter) {
+	if e.RequestID != "" {
+		w.Header().Set("X-Request-ID", e.RequestID)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(e.Status)
+	json.NewEncoder(w).Encode(e)
+}
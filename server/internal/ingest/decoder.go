@@ -0,0 +1,53 @@
+// Package ingest decodes ingestion protocols other than nanolog's native
+// JSON object/array shape (handled directly by server.handleIngest) into
+// engine.LogEvent values for QueryEngine.Ingest. Adding a protocol means
+// implementing ProtocolDecoder and registering a route for it in
+// server.RegisterIngesterRoutes, so existing agents (Filebeat, Promtail,
+// the OTEL collector, Elasticsearch bulk loaders) can point at nanolog
+// directly with no shim in front of it.
+package ingest
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/coffersTech/nanolog/server/internal/engine"
+)
+
+// ProtocolDecoder decodes a request body into individual log events,
+// handing each to emit as soon as it's parsed.
+type ProtocolDecoder interface {
+	Decode(r io.Reader, emit func(engine.LogEvent) error) error
+}
+
+// ItemError is one record's decode failure within a batch, indexed the
+// same way Elasticsearch's _bulk response indexes per-action failures.
+type ItemError struct {
+	Index int
+	Err   error
+}
+
+// BatchError reports that a batch decoded partially: every record not
+// listed in Failed was emitted successfully. Decoders return it instead of
+// a plain error so callers can still sync what was accepted and report
+// only the failed indexes, rather than rejecting the whole batch over one
+// bad line.
+type BatchError struct {
+	Accepted int
+	Failed   []ItemError
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("%d of %d records failed to decode", len(e.Failed), e.Accepted+len(e.Failed))
+}
+
+// firstNonEmpty returns the first non-empty string in vals, or "" if all
+// are empty.
+func firstNonEmpty(vals ...string) string {
+	for _, v := range vals {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
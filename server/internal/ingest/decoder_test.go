@@ -0,0 +1,90 @@
+package ingest
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/coffersTech/nanolog/server/internal/engine"
+)
+
+func collect(t *testing.T, d ProtocolDecoder, body string) ([]engine.LogEvent, error) {
+	t.Helper()
+	var got []engine.LogEvent
+	err := d.Decode(strings.NewReader(body), func(ev engine.LogEvent) error {
+		got = append(got, ev)
+		return nil
+	})
+	return got, err
+}
+
+func TestNDJSONDecoder(t *testing.T) {
+	body := `{"service":"checkout","host":"h1","level":"INFO","message":"ok"}
+{"service":"checkout","msg":"fallback field"}
+not json
+`
+	got, err := collect(t, NDJSONDecoder{}, body)
+
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) {
+		t.Fatalf("expected a *BatchError for the malformed line, got %v", err)
+	}
+	if batchErr.Accepted != 2 || len(batchErr.Failed) != 1 || batchErr.Failed[0].Index != 2 {
+		t.Fatalf("unexpected batch error: %+v", batchErr)
+	}
+	if len(got) != 2 || got[1].Message != "fallback field" {
+		t.Fatalf("unexpected events: %+v", got)
+	}
+}
+
+func TestESBulkDecoder(t *testing.T) {
+	body := `{"index":{"_index":"logs"}}
+{"message":"first","service":"api"}
+{"delete":{"_index":"logs","_id":"1"}}
+{"create":{"_index":"logs"}}
+{"message":"second","@timestamp":"2024-01-01T00:00:00Z"}
+`
+	got, err := collect(t, ESBulkDecoder{}, body)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected 2 events (delete skipped), got %d: %+v", len(got), got)
+	}
+	if got[0].Service != "api" || got[1].Service != "default" {
+		t.Fatalf("unexpected service defaulting: %+v", got)
+	}
+	if got[1].Timestamp == 0 {
+		t.Fatalf("expected @timestamp fallback to be parsed")
+	}
+}
+
+func TestLokiDecoder(t *testing.T) {
+	body := `{"streams":[{"stream":{"service_name":"frontend","host":"h2"},"values":[["1700000000000000000","hello"],["bad","oops"]]}]}`
+	got, err := collect(t, LokiDecoder{}, body)
+
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) {
+		t.Fatalf("expected a *BatchError for the malformed timestamp, got %v", err)
+	}
+	if batchErr.Accepted != 1 || len(batchErr.Failed) != 1 {
+		t.Fatalf("unexpected batch error: %+v", batchErr)
+	}
+	if len(got) != 1 || got[0].Service != "frontend" || got[0].Host != "h2" || got[0].Message != "hello" {
+		t.Fatalf("unexpected events: %+v", got)
+	}
+}
+
+func TestOTLPJSONDecoder(t *testing.T) {
+	body := `{"resourceLogs":[{"resource":{"attributes":[{"key":"service.name","value":{"stringValue":"checkout"}}]},"scopeLogs":[{"logRecords":[{"timeUnixNano":"1700000000000000000","severityNumber":17,"body":{"stringValue":"boom"}}]}]}]}`
+	got, err := collect(t, OTLPJSONDecoder{}, body)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(got))
+	}
+	if got[0].Service != "checkout" || got[0].Level != "ERROR" || got[0].Message != "boom" {
+		t.Fatalf("unexpected event: %+v", got[0])
+	}
+}
@@ -0,0 +1,114 @@
+package ingest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/coffersTech/nanolog/server/internal/engine"
+)
+
+// esAction is one line of an Elasticsearch _bulk request's action/metadata
+// pair. Only the action type matters here: index/create/update are each
+// followed by a document line, delete is not.
+type esAction struct {
+	Index  json.RawMessage `json:"index"`
+	Create json.RawMessage `json:"create"`
+	Update json.RawMessage `json:"update"`
+	Delete json.RawMessage `json:"delete"`
+}
+
+// esDocument is the subset of an indexed document's fields nanolog knows
+// how to map to a LogEvent. "@timestamp" is Elastic Common Schema's
+// standard RFC3339 timestamp field, used when "timestamp" is absent.
+type esDocument struct {
+	Timestamp int64  `json:"timestamp"`
+	ECSTime   string `json:"@timestamp"`
+	Level     string `json:"level"`
+	Service   string `json:"service"`
+	Host      string `json:"host"`
+	Message   string `json:"message"`
+}
+
+// ESBulkDecoder decodes Elasticsearch's _bulk newline-delimited
+// action/document pairs, treating every index/create/update action's
+// document as one log event. Delete actions are skipped (nanolog has no
+// delete-by-id equivalent) and consume no document line, matching the
+// real _bulk format.
+type ESBulkDecoder struct{}
+
+func (ESBulkDecoder) Decode(r io.Reader, emit func(engine.LogEvent) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var failed []ItemError
+	accepted := 0
+	idx := 0
+
+	for scanner.Scan() {
+		actionLine := scanner.Bytes()
+		actionIdx := idx
+		idx++
+		if len(bytes.TrimSpace(actionLine)) == 0 {
+			continue
+		}
+
+		var action esAction
+		if err := json.Unmarshal(actionLine, &action); err != nil {
+			failed = append(failed, ItemError{Index: actionIdx, Err: err})
+			continue
+		}
+		if action.Delete != nil {
+			continue
+		}
+
+		if !scanner.Scan() {
+			failed = append(failed, ItemError{Index: actionIdx, Err: errors.New("bulk: action with no following document line")})
+			break
+		}
+		docLine := scanner.Bytes()
+		docIdx := idx
+		idx++
+
+		var doc esDocument
+		if err := json.Unmarshal(docLine, &doc); err != nil {
+			failed = append(failed, ItemError{Index: docIdx, Err: err})
+			continue
+		}
+
+		ev := engine.LogEvent{
+			Timestamp: doc.Timestamp,
+			Level:     doc.Level,
+			Service:   doc.Service,
+			Host:      doc.Host,
+			Message:   doc.Message,
+		}
+		if ev.Timestamp == 0 && doc.ECSTime != "" {
+			if t, err := time.Parse(time.RFC3339Nano, doc.ECSTime); err == nil {
+				ev.Timestamp = t.UnixNano()
+			}
+		}
+		if ev.Timestamp == 0 {
+			ev.Timestamp = time.Now().UnixNano()
+		}
+		if ev.Service == "" {
+			ev.Service = "default"
+		}
+
+		if err := emit(ev); err != nil {
+			return err
+		}
+		accepted++
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	if len(failed) > 0 {
+		return &BatchError{Accepted: accepted, Failed: failed}
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package ingest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/coffersTech/nanolog/server/internal/engine"
+)
+
+// lokiPushRequest is the JSON body Grafana Loki's push API
+// (POST /loki/api/v1/push) accepts: a set of streams, each carrying a
+// label set and a list of [timestamp_ns_string, line] pairs. nanolog only
+// supports the JSON variant, not the snappy-compressed protobuf one.
+type lokiPushRequest struct {
+	Streams []lokiStream `json:"streams"`
+}
+
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][]string        `json:"values"`
+}
+
+// LokiDecoder decodes a Loki push request. Labels "service"/"service_name"
+// and "host"/"hostname" map onto the matching LogEvent fields if present;
+// every other label is ignored, same as NDJSONDecoder ignores unknown
+// object fields.
+type LokiDecoder struct{}
+
+func (LokiDecoder) Decode(r io.Reader, emit func(engine.LogEvent) error) error {
+	var req lokiPushRequest
+	if err := json.NewDecoder(r).Decode(&req); err != nil {
+		return err
+	}
+
+	var failed []ItemError
+	accepted := 0
+	idx := 0
+
+	for _, stream := range req.Streams {
+		service := firstNonEmpty(stream.Stream["service"], stream.Stream["service_name"], "default")
+		host := firstNonEmpty(stream.Stream["host"], stream.Stream["hostname"])
+		level := stream.Stream["level"]
+
+		for _, pair := range stream.Values {
+			recordIdx := idx
+			idx++
+
+			if len(pair) != 2 {
+				failed = append(failed, ItemError{Index: recordIdx, Err: fmt.Errorf("expected [timestamp, line], got %d elements", len(pair))})
+				continue
+			}
+			ts, err := strconv.ParseInt(pair[0], 10, 64)
+			if err != nil {
+				failed = append(failed, ItemError{Index: recordIdx, Err: err})
+				continue
+			}
+
+			if err := emit(engine.LogEvent{
+				Timestamp: ts,
+				Level:     level,
+				Service:   service,
+				Host:      host,
+				Message:   pair[1],
+			}); err != nil {
+				return err
+			}
+			accepted++
+		}
+	}
+
+	if len(failed) > 0 {
+		return &BatchError{Accepted: accepted, Failed: failed}
+	}
+	return nil
+}
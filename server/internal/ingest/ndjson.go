@@ -0,0 +1,74 @@
+package ingest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/coffersTech/nanolog/server/internal/engine"
+)
+
+// ndjsonLine mirrors the field names and fallbacks server.handleIngest
+// uses for its JSON object/array shape, so a log line round-trips
+// identically regardless of which entrypoint it arrived through.
+type ndjsonLine struct {
+	Timestamp int64  `json:"timestamp"`
+	Level     string `json:"level"`
+	Service   string `json:"service"`
+	Host      string `json:"host"`
+	Message   string `json:"message"`
+	Msg       string `json:"msg"`
+}
+
+// NDJSONDecoder decodes newline-delimited JSON log objects, one per line.
+type NDJSONDecoder struct{}
+
+func (NDJSONDecoder) Decode(r io.Reader, emit func(engine.LogEvent) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var failed []ItemError
+	accepted := 0
+
+	for idx := 0; scanner.Scan(); idx++ {
+		line := scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		var l ndjsonLine
+		if err := json.Unmarshal(line, &l); err != nil {
+			failed = append(failed, ItemError{Index: idx, Err: err})
+			continue
+		}
+
+		ev := engine.LogEvent{
+			Timestamp: l.Timestamp,
+			Level:     l.Level,
+			Service:   l.Service,
+			Host:      l.Host,
+			Message:   firstNonEmpty(l.Message, l.Msg),
+		}
+		if ev.Timestamp == 0 {
+			ev.Timestamp = time.Now().UnixNano()
+		}
+		if ev.Service == "" {
+			ev.Service = "default"
+		}
+
+		if err := emit(ev); err != nil {
+			return err
+		}
+		accepted++
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	if len(failed) > 0 {
+		return &BatchError{Accepted: accepted, Failed: failed}
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package ingest
+
+import (
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/coffersTech/nanolog/server/internal/engine"
+)
+
+// The OTLP/JSON types below mirror the protojson encoding of
+// opentelemetry.proto.collector.logs.v1.ExportLogsServiceRequest: field
+// names are camelCase and the 64-bit timeUnixNano is a JSON string.
+// server/internal/server/grpc.Server.Export accepts the same shape over
+// gRPC/protobuf; this decoder lets HTTP-only shippers reach the same
+// ingestion path.
+type otlpExportRequest struct {
+	ResourceLogs []otlpResourceLogs `json:"resourceLogs"`
+}
+
+type otlpResourceLogs struct {
+	Resource  otlpResource    `json:"resource"`
+	ScopeLogs []otlpScopeLogs `json:"scopeLogs"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeLogs struct {
+	LogRecords []otlpLogRecord `json:"logRecords"`
+}
+
+type otlpLogRecord struct {
+	TimeUnixNano         string         `json:"timeUnixNano"`
+	ObservedTimeUnixNano string         `json:"observedTimeUnixNano"`
+	SeverityText         string         `json:"severityText"`
+	SeverityNumber       int            `json:"severityNumber"`
+	Body                 otlpAnyValue   `json:"body"`
+	Attributes           []otlpKeyValue `json:"attributes"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+// OTLPJSONDecoder decodes an OTLP ExportLogsServiceRequest body encoded as
+// JSON.
+type OTLPJSONDecoder struct{}
+
+func (OTLPJSONDecoder) Decode(r io.Reader, emit func(engine.LogEvent) error) error {
+	var req otlpExportRequest
+	if err := json.NewDecoder(r).Decode(&req); err != nil {
+		return err
+	}
+
+	var failed []ItemError
+	accepted := 0
+	idx := 0
+
+	for _, rl := range req.ResourceLogs {
+		service := otlpResourceAttr(rl.Resource, "service.name")
+		if service == "" {
+			service = "default"
+		}
+		host := otlpResourceAttr(rl.Resource, "host.name")
+
+		for _, sl := range rl.ScopeLogs {
+			for _, rec := range sl.LogRecords {
+				recordIdx := idx
+				idx++
+
+				ts, err := parseOTLPTime(rec.TimeUnixNano, rec.ObservedTimeUnixNano)
+				if err != nil {
+					failed = append(failed, ItemError{Index: recordIdx, Err: err})
+					continue
+				}
+
+				level := rec.SeverityText
+				if level == "" {
+					level = otlpSeverityToLevel(rec.SeverityNumber)
+				}
+
+				if err := emit(engine.LogEvent{
+					Timestamp: ts,
+					Level:     level,
+					Service:   service,
+					Host:      host,
+					Message:   rec.Body.StringValue,
+				}); err != nil {
+					return err
+				}
+				accepted++
+			}
+		}
+	}
+
+	if len(failed) > 0 {
+		return &BatchError{Accepted: accepted, Failed: failed}
+	}
+	return nil
+}
+
+func otlpResourceAttr(resource otlpResource, key string) string {
+	for _, kv := range resource.Attributes {
+		if kv.Key == key {
+			return kv.Value.StringValue
+		}
+	}
+	return ""
+}
+
+// parseOTLPTime parses OTLP's string-encoded nanosecond timestamps,
+// falling back from TimeUnixNano to ObservedTimeUnixNano to now, in the
+// same order the collector's own log processors do.
+func parseOTLPTime(timeUnixNano, observedTimeUnixNano string) (int64, error) {
+	s := timeUnixNano
+	if s == "" || s == "0" {
+		s = observedTimeUnixNano
+	}
+	if s == "" || s == "0" {
+		return time.Now().UnixNano(), nil
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
+
+// otlpSeverityToLevel maps an OTLP SeverityNumber onto nanolog's level
+// names. OTLP reserves 4 numbers per level (TRACE 1-4, DEBUG 5-8, INFO
+// 9-12, WARN 13-16, ERROR 17-20, FATAL 21-24) for finer-grained tools;
+// nanolog only distinguishes the coarse level, so every number in a band
+// maps to the same name.
+func otlpSeverityToLevel(sev int) string {
+	switch {
+	case sev >= 21:
+		return "FATAL"
+	case sev >= 17:
+		return "ERROR"
+	case sev >= 13:
+		return "WARN"
+	case sev >= 9:
+		return "INFO"
+	case sev >= 1:
+		return "DEBUG"
+	default:
+		return "INFO"
+	}
+}
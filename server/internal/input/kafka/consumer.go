@@ -0,0 +1,143 @@
+// Package kafka consumes log records from Kafka topics, as a
+// consumer-group reader, and appends them straight to the shared
+// *engine.MemTable used by the HTTP ingest path. It's an alternative
+// entrypoint for pipelines that already ship logs to Kafka rather than an
+// HTTP endpoint, and it coexists with that path unmodified: it only ever
+// calls MemTable.Append and MemTable.GetSize, both already public.
+package kafka
+
+import (
+	"context"
+	"log"
+	"time"
+
+	kafkago "github.com/segmentio/kafka-go"
+
+	"github.com/coffersTech/nanolog/server/internal/engine"
+)
+
+// Format selects how a Kafka message value is decoded into log fields.
+type Format string
+
+const (
+	FormatJSON   Format = "json"
+	FormatSyslog Format = "syslog"
+	FormatRaw    Format = "raw"
+)
+
+// DefaultSoftCapBytes is the MemTable size above which Consumer pauses
+// partition consumption when Config.SoftCapBytes is left at zero. It
+// mirrors QueryEngine's own default MaxTableSize so Kafka ingestion
+// throttles at roughly the same point HTTP ingestion's flush swap does.
+const DefaultSoftCapBytes = 64 * 1024 * 1024
+
+// Config configures a Consumer.
+type Config struct {
+	Brokers []string
+	Topics  []string
+	GroupID string
+	Format  Format
+
+	// SoftCapBytes is the MemTable size above which partition consumption
+	// pauses until the QueryEngine flushes it back down. Zero uses
+	// DefaultSoftCapBytes.
+	SoftCapBytes int64
+}
+
+// Consumer reads Config.Topics as a consumer group and appends each
+// decoded record to MemTable, committing the Kafka offset only after the
+// append succeeds so a crash between fetch and append is reprocessed
+// rather than silently dropped.
+type Consumer struct {
+	cfg     Config
+	mt      *engine.MemTable
+	readers []*kafkago.Reader
+	stopCh  chan struct{}
+}
+
+// NewConsumer creates a Consumer that appends decoded records to mt. Call
+// Start to begin consuming.
+func NewConsumer(cfg Config, mt *engine.MemTable) *Consumer {
+	if cfg.SoftCapBytes <= 0 {
+		cfg.SoftCapBytes = DefaultSoftCapBytes
+	}
+	return &Consumer{cfg: cfg, mt: mt, stopCh: make(chan struct{})}
+}
+
+// Start launches one consumption goroutine per configured topic, each
+// joining Config.GroupID, and returns once the readers are constructed.
+func (c *Consumer) Start() error {
+	for _, topic := range c.cfg.Topics {
+		r := kafkago.NewReader(kafkago.ReaderConfig{
+			Brokers: c.cfg.Brokers,
+			Topic:   topic,
+			GroupID: c.cfg.GroupID,
+		})
+		c.readers = append(c.readers, r)
+		go c.consumeLoop(r)
+	}
+	return nil
+}
+
+// Stop closes every topic reader, unblocking consumeLoop, and waits for
+// in-flight fetches to return.
+func (c *Consumer) Stop() error {
+	close(c.stopCh)
+	var firstErr error
+	for _, r := range c.readers {
+		if err := r.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (c *Consumer) consumeLoop(r *kafkago.Reader) {
+	ctx := context.Background()
+	topic := r.Config().Topic
+
+	for {
+		select {
+		case <-c.stopCh:
+			return
+		default:
+		}
+
+		if !c.awaitCapacity() {
+			return
+		}
+
+		m, err := r.FetchMessage(ctx)
+		if err != nil {
+			select {
+			case <-c.stopCh:
+				return
+			default:
+				log.Printf("kafka: fetch error on topic %s: %v", topic, err)
+				time.Sleep(time.Second)
+				continue
+			}
+		}
+
+		ts, level, service, host, msg := decode(c.cfg.Format, m.Value)
+		c.mt.Append(ts, level, service, host, msg)
+
+		if err := r.CommitMessages(ctx, m); err != nil {
+			log.Printf("kafka: commit error on topic %s: %v", topic, err)
+		}
+	}
+}
+
+// awaitCapacity blocks while the MemTable is over SoftCapBytes, polling
+// since a flush completing isn't otherwise observable from this package.
+// Returns false if Stop was called while waiting.
+func (c *Consumer) awaitCapacity() bool {
+	for c.mt.GetSize() > c.cfg.SoftCapBytes {
+		select {
+		case <-c.stopCh:
+			return false
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+	return true
+}
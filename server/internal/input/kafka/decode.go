@@ -0,0 +1,79 @@
+package kafka
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// jsonRecord is the wire shape of a "json"-format Kafka message, matching
+// the field names and fallbacks the HTTP ingest endpoint already uses so
+// the same producer payload works over either path.
+type jsonRecord struct {
+	Timestamp int64  `json:"timestamp"`
+	Level     string `json:"level"`
+	Service   string `json:"service"`
+	Host      string `json:"host"`
+	Message   string `json:"message"`
+	Msg       string `json:"msg"`
+}
+
+// syslogHeader matches an RFC5424 message: the one syslog shape nanolog's
+// own shippers (and most modern forwarders) emit.
+var syslogHeader = regexp.MustCompile(`^<(\d+)>\d+\s+\S+\s+(\S+)\s+(\S+)\s+\S+\s+\S+\s+(?:-\s+)?(.*)$`)
+
+var syslogSeverityLevel = [8]string{
+	"FATAL", "FATAL", "FATAL", "ERROR", "WARN", "INFO", "INFO", "DEBUG",
+}
+
+// decode parses a Kafka message value per format, returning fields ready
+// for MemTable.Append. Any parse failure falls back to a raw INFO record
+// rather than dropping the message, since partial/garbled data from a
+// producer is still more useful ingested than discarded silently.
+func decode(format Format, value []byte) (ts int64, level, service, host, msg string) {
+	switch format {
+	case FormatJSON:
+		return decodeJSON(value)
+	case FormatSyslog:
+		return decodeSyslog(value)
+	default:
+		return time.Now().UnixNano(), "INFO", "kafka", "", string(value)
+	}
+}
+
+func decodeJSON(value []byte) (ts int64, level, service, host, msg string) {
+	var rec jsonRecord
+	if err := json.Unmarshal(value, &rec); err != nil {
+		return time.Now().UnixNano(), "INFO", "kafka", "", string(value)
+	}
+
+	ts = rec.Timestamp
+	if ts == 0 {
+		ts = time.Now().UnixNano()
+	}
+	service = rec.Service
+	if service == "" {
+		service = "kafka"
+	}
+	msg = rec.Message
+	if msg == "" {
+		msg = rec.Msg
+	}
+	return ts, rec.Level, service, rec.Host, msg
+}
+
+func decodeSyslog(value []byte) (ts int64, level, service, host, msg string) {
+	line := strings.TrimRight(string(value), "\r\n")
+	m := syslogHeader.FindStringSubmatch(line)
+	if m == nil {
+		return time.Now().UnixNano(), "INFO", "kafka", "", line
+	}
+	pri, err := strconv.Atoi(m[1])
+	if err != nil {
+		return time.Now().UnixNano(), "INFO", "kafka", "", line
+	}
+	return time.Now().UnixNano(), syslogSeverityLevel[pri&0x07], "syslog", m[2], fmt.Sprintf("%s: %s", m[3], m[4])
+}
@@ -0,0 +1,37 @@
+package kafka
+
+import "testing"
+
+func TestDecodeJSON(t *testing.T) {
+	ts, level, service, host, msg := decode(FormatJSON, []byte(`{"level":"ERROR","service":"svc","host":"h1","message":"boom"}`))
+	if level != "ERROR" || service != "svc" || host != "h1" || msg != "boom" {
+		t.Errorf("unexpected decode: level=%s service=%s host=%s msg=%q", level, service, host, msg)
+	}
+	if ts == 0 {
+		t.Errorf("expected a non-zero timestamp")
+	}
+
+	_, _, service, _, msg = decode(FormatJSON, []byte(`{"level":"WARN","msg":"fallback"}`))
+	if service != "kafka" || msg != "fallback" {
+		t.Errorf("expected default service and msg fallback, got service=%s msg=%q", service, msg)
+	}
+}
+
+func TestDecodeSyslog(t *testing.T) {
+	_, level, service, host, msg := decode(FormatSyslog, []byte("<12>1 2026-07-29T12:00:00Z myhost myapp 1234 ID47 - connection reset"))
+	if level != "WARN" || service != "syslog" || host != "myhost" || msg != "myapp: connection reset" {
+		t.Errorf("unexpected decode: level=%s service=%s host=%s msg=%q", level, service, host, msg)
+	}
+
+	_, level, service, _, msg = decode(FormatSyslog, []byte("not syslog"))
+	if level != "INFO" || service != "kafka" || msg != "not syslog" {
+		t.Errorf("expected raw fallback for unparsable syslog, got level=%s service=%s msg=%q", level, service, msg)
+	}
+}
+
+func TestDecodeRaw(t *testing.T) {
+	_, level, service, host, msg := decode(FormatRaw, []byte("plain text line"))
+	if level != "INFO" || service != "kafka" || host != "" || msg != "plain text line" {
+		t.Errorf("unexpected decode: level=%s service=%s host=%s msg=%q", level, service, host, msg)
+	}
+}
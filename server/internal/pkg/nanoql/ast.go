@@ -1,5 +1,7 @@
 package nanoql
 
+import "regexp"
+
 // Node is the interface implemented by all AST nodes.
 type Node interface {
 	node() // marker method
@@ -30,3 +32,40 @@ type NotExpr struct {
 }
 
 func (NotExpr) node() {}
+
+// RangeExpr represents a one- or two-sided range over a single field, e.g.
+// ts:[1700000000000..1700000100000], level:>=WARN, or ts:<1700000100000.
+// A one-sided comparison leaves the unused bound's Has flag false.
+type RangeExpr struct {
+	Key    string
+	HasLo  bool
+	Lo     string
+	LoIncl bool
+	HasHi  bool
+	Hi     string
+	HiIncl bool
+}
+
+func (RangeExpr) node() {}
+
+// RegexExpr represents a key~"pattern" regex match. Re is compiled once by
+// the parser and cached here so repeated evaluation against many rows
+// doesn't recompile the pattern.
+type RegexExpr struct {
+	Key     string
+	Pattern string
+	Re      *regexp.Regexp
+}
+
+func (RegexExpr) node() {}
+
+// InExpr represents a key IN (v1, v2, ...) set-membership match: it's
+// an OR of case-insensitive equality over Values, parsed and evaluated as
+// a single node instead of a BinaryExpr chain. Unlike MatchExpr{Op: "="},
+// glob wildcards in Values are compared literally.
+type InExpr struct {
+	Key    string
+	Values []string
+}
+
+func (InExpr) node() {}
@@ -1,6 +1,7 @@
 package nanoql
 
 import (
+	"path"
 	"strconv"
 	"strings"
 )
@@ -28,11 +29,124 @@ func Match(node Node, row LogRecord) bool {
 		return evalMatch(n, row)
 	case NotExpr:
 		return !Match(n.Expr, row)
+	case RangeExpr:
+		return evalRange(n, row)
+	case RegexExpr:
+		return evalRegex(n, row)
+	case InExpr:
+		return evalIn(n, row)
 	default:
 		return false
 	}
 }
 
+// evalRange reports whether row's field named by expr.Key falls within the
+// (possibly one-sided) range. ts and level compare numerically; every other
+// field falls back to a case-insensitive lexicographic comparison.
+func evalRange(expr RangeExpr, row LogRecord) bool {
+	cmp := rangeComparer(expr.Key, row)
+
+	if expr.HasLo {
+		c := cmp(expr.Lo)
+		if expr.LoIncl {
+			if c < 0 {
+				return false
+			}
+		} else if c <= 0 {
+			return false
+		}
+	}
+	if expr.HasHi {
+		c := cmp(expr.Hi)
+		if expr.HiIncl {
+			if c > 0 {
+				return false
+			}
+		} else if c >= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// rangeComparer returns a function comparing row's field to a bound string,
+// negative/zero/positive per the usual comparison convention. It builds on
+// getFieldValue's typed Value rather than re-deriving ts/level from row
+// itself, so numeric fields only need special-casing once.
+func rangeComparer(key string, row LogRecord) func(bound string) int {
+	fv := getFieldValue(key, row)
+
+	if fv.IsNum {
+		isLevel := strings.ToLower(key) == "level" || strings.ToLower(key) == "lvl"
+		return func(bound string) int {
+			var b int64
+			if isLevel {
+				b = int64(levelRank(bound))
+			} else {
+				var err error
+				b, err = strconv.ParseInt(bound, 10, 64)
+				if err != nil {
+					return 0
+				}
+			}
+			switch {
+			case fv.Num < b:
+				return -1
+			case fv.Num > b:
+				return 1
+			default:
+				return 0
+			}
+		}
+	}
+
+	upper := strings.ToUpper(fv.Str)
+	return func(bound string) int {
+		return strings.Compare(upper, strings.ToUpper(bound))
+	}
+}
+
+// levelRank maps a level name to its numeric rank for range comparisons,
+// returning -1 for unrecognized names so they sort below DEBUG.
+func levelRank(name string) int {
+	switch strings.ToUpper(name) {
+	case "DEBUG":
+		return 0
+	case "INFO":
+		return 1
+	case "WARN", "WARNING":
+		return 2
+	case "ERROR":
+		return 3
+	case "FATAL":
+		return 4
+	default:
+		return -1
+	}
+}
+
+// evalRegex reports whether row's field named by expr.Key matches the
+// precompiled pattern.
+func evalRegex(expr RegexExpr, row LogRecord) bool {
+	if expr.Re == nil {
+		return false
+	}
+	return expr.Re.MatchString(getFieldValue(expr.Key, row).Str)
+}
+
+// evalIn reports whether row's field named by expr.Key equals any of
+// expr.Values, using the same case-insensitive comparison as a single "="
+// but without its glob matching on host/service.
+func evalIn(expr InExpr, row LogRecord) bool {
+	fv := getFieldValue(expr.Key, row).Str
+	for _, v := range expr.Values {
+		if matchEqual(fv, v) {
+			return true
+		}
+	}
+	return false
+}
+
 func evalBinary(expr BinaryExpr, row LogRecord) bool {
 	left := Match(expr.Left, row)
 	right := Match(expr.Right, row)
@@ -54,13 +168,19 @@ func evalMatch(expr MatchExpr, row LogRecord) bool {
 	}
 
 	// Get the field value
-	fieldValue := getFieldValue(expr.Key, row)
+	fieldValue := getFieldValue(expr.Key, row).Str
 
 	// Evaluate based on operator
 	switch expr.Op {
 	case "=":
+		if isGlobField(expr.Key) && isGlobPattern(expr.Value) {
+			return matchGlob(fieldValue, expr.Value)
+		}
 		return matchEqual(fieldValue, expr.Value)
 	case "!=":
+		if isGlobField(expr.Key) && isGlobPattern(expr.Value) {
+			return !matchGlob(fieldValue, expr.Value)
+		}
 		return !matchEqual(fieldValue, expr.Value)
 	case "CONTAINS":
 		return containsIgnoreCase(fieldValue, expr.Value)
@@ -69,24 +189,65 @@ func evalMatch(expr MatchExpr, row LogRecord) bool {
 	}
 }
 
+// Value is a field's value as getFieldValue resolved it: Str always holds
+// the string form (for equality/CONTAINS/regex matching), and Num/IsNum
+// additionally hold a numeric form for ts/level so callers like
+// rangeComparer can compare integers instead of reparsing a string on
+// every row.
+type Value struct {
+	Str   string
+	Num   int64
+	IsNum bool
+}
+
 // getFieldValue returns the value of a field by name.
-func getFieldValue(key string, row LogRecord) string {
+func getFieldValue(key string, row LogRecord) Value {
 	switch strings.ToLower(key) {
 	case "service", "svc":
-		return row.GetService()
+		return Value{Str: row.GetService()}
 	case "host", "ip", "hostname":
-		return row.GetHost()
+		return Value{Str: row.GetHost()}
 	case "message", "msg":
-		return row.GetMessage()
+		return Value{Str: row.GetMessage()}
 	case "level", "lvl":
-		return levelToString(row.GetLevel())
+		lvl := row.GetLevel()
+		return Value{Str: levelToString(lvl), Num: int64(lvl), IsNum: true}
 	case "timestamp", "ts":
-		return strconv.FormatInt(row.GetTimestamp(), 10)
+		ts := row.GetTimestamp()
+		return Value{Str: strconv.FormatInt(ts, 10), Num: ts, IsNum: true}
 	default:
-		return ""
+		return Value{}
 	}
 }
 
+// isGlobField reports whether key is one of the fields glob wildcards
+// (*, ?) are supported on. Restricted to host/service rather than every
+// field since those are the identifiers operators actually glob against
+// (e.g. "host-*" or "checkout-?"); a glob on a free-text message field
+// would rarely do what's intended.
+func isGlobField(key string) bool {
+	switch strings.ToLower(key) {
+	case "host", "ip", "hostname", "service", "svc":
+		return true
+	default:
+		return false
+	}
+}
+
+// isGlobPattern reports whether value contains a glob wildcard character.
+func isGlobPattern(value string) bool {
+	return strings.ContainsAny(value, "*?")
+}
+
+// matchGlob reports whether value matches pattern using shell-style glob
+// wildcards (* and ?), case-insensitively. An invalid pattern never
+// matches rather than erroring, consistent with matchEqual/containsIgnoreCase
+// never failing a query outright over a single row's data.
+func matchGlob(value, pattern string) bool {
+	ok, err := path.Match(strings.ToLower(pattern), strings.ToLower(value))
+	return err == nil && ok
+}
+
 // matchEqual performs case-insensitive equality check.
 func matchEqual(fieldValue, queryValue string) bool {
 	return strings.EqualFold(fieldValue, queryValue)
@@ -18,7 +18,17 @@ const (
 	TokenAnd
 	TokenOr
 	TokenNot
-	TokenNeq // !=
+	TokenNeq      // !=
+	TokenLt       // <
+	TokenLte      // <=
+	TokenGt       // >
+	TokenGte      // >=
+	TokenTilde    // ~
+	TokenLBracket // [
+	TokenRBracket // ]
+	TokenTo       // TO (Lucene-style range separator, e.g. [lo TO hi])
+	TokenIn       // IN (set-membership, e.g. key IN (a, b, c))
+	TokenComma    // , (separator inside an IN list)
 )
 
 // Token represents a lexical token.
@@ -68,6 +78,32 @@ func (l *Lexer) NextToken() Token {
 		return l.readIdent()
 	case '"':
 		return l.readString()
+	case '<':
+		if l.pos+1 < len(l.input) && l.input[l.pos+1] == '=' {
+			l.pos += 2
+			return Token{Type: TokenLte, Value: "<="}
+		}
+		l.pos++
+		return Token{Type: TokenLt, Value: "<"}
+	case '>':
+		if l.pos+1 < len(l.input) && l.input[l.pos+1] == '=' {
+			l.pos += 2
+			return Token{Type: TokenGte, Value: ">="}
+		}
+		l.pos++
+		return Token{Type: TokenGt, Value: ">"}
+	case '~':
+		l.pos++
+		return Token{Type: TokenTilde, Value: "~"}
+	case '[':
+		l.pos++
+		return Token{Type: TokenLBracket, Value: "["}
+	case ']':
+		l.pos++
+		return Token{Type: TokenRBracket, Value: "]"}
+	case ',':
+		l.pos++
+		return Token{Type: TokenComma, Value: ","}
 	}
 
 	// Keywords and identifiers
@@ -119,16 +155,27 @@ func (l *Lexer) readIdent() Token {
 		return Token{Type: TokenOr, Value: upper}
 	case "NOT":
 		return Token{Type: TokenNot, Value: upper}
+	case "TO":
+		return Token{Type: TokenTo, Value: upper}
+	case "IN":
+		return Token{Type: TokenIn, Value: upper}
 	}
 
 	return Token{Type: TokenIdent, Value: value}
 }
 
+// isIdentStart reports whether ch can begin an identifier or bare value.
+// Digits are included so numeric literals like the 100 in "ts:<100" or
+// "[1700000000 TO 1700003600]" lex as a single TokenIdent instead of being
+// silently skipped as unknown characters.
 func isIdentStart(ch byte) bool {
-	return unicode.IsLetter(rune(ch)) || ch == '_'
+	return unicode.IsLetter(rune(ch)) || unicode.IsDigit(rune(ch)) || ch == '_'
 }
 
+// isIdentChar reports whether ch can continue an identifier or bare value.
+// '*' and '?' are included so glob patterns like "web-*" or "host-?"
+// lex as a single TokenIdent instead of stopping at the wildcard.
 func isIdentChar(ch byte) bool {
 	r := rune(ch)
-	return unicode.IsLetter(r) || unicode.IsDigit(r) || ch == '_' || ch == '-' || ch == '.'
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || ch == '_' || ch == '-' || ch == '.' || ch == '*' || ch == '?'
 }
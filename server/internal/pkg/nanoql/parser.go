@@ -2,6 +2,8 @@ package nanoql
 
 import (
 	"fmt"
+	"regexp"
+	"strings"
 )
 
 // Parser parses NanoQL queries into an AST.
@@ -112,6 +114,18 @@ func (p *Parser) parsePrimary() (Node, error) {
 			return p.parseValue(key, "!=")
 		}
 
+		// Check for ~ (key~"pattern" regex match)
+		if p.current.Type == TokenTilde {
+			p.advance()
+			return p.parseRegexValue(key)
+		}
+
+		// Check for IN (key IN (a, b, c) set-membership match)
+		if p.current.Type == TokenIn {
+			p.advance()
+			return p.parseInValues(key)
+		}
+
 		// Bare identifier: treat as full-text search
 		return MatchExpr{Key: "", Value: key, Op: "CONTAINS"}, nil
 
@@ -123,8 +137,19 @@ func (p *Parser) parsePrimary() (Node, error) {
 	}
 }
 
-// parseValue parses the value part after key: or key!=
+// parseValue parses the value part after key: or key!=. It also handles the
+// range/comparison forms that are only valid after a colon: key:[lo..hi],
+// key:>=value, key:<=value, key:>value, key:<value.
 func (p *Parser) parseValue(key, op string) (Node, error) {
+	if op == "=" {
+		switch p.current.Type {
+		case TokenLBracket:
+			return p.parseRangeValue(key)
+		case TokenLt, TokenLte, TokenGt, TokenGte:
+			return p.parseComparisonValue(key, p.current.Type)
+		}
+	}
+
 	var value string
 
 	switch p.current.Type {
@@ -140,3 +165,149 @@ func (p *Parser) parseValue(key, op string) (Node, error) {
 
 	return MatchExpr{Key: key, Value: value, Op: op}, nil
 }
+
+// parseRangeValue parses a bracketed range literal after the lexer has
+// produced a TokenLBracket. It accepts both the Lucene/KQL-style
+// "[lo TO hi]" syntax and the older "[lo..hi]" form (a single bound
+// containing ".." with no TokenTo), so existing saved queries keep
+// working. Either bound may be empty for an open-ended range, e.g.
+// "[TO 100]" or "[100 TO ]".
+func (p *Parser) parseRangeValue(key string) (Node, error) {
+	p.advance() // consume '['
+
+	expr := RangeExpr{Key: key, LoIncl: true, HiIncl: true}
+
+	first, err := p.parseRangeBound(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.Contains(first, "..") {
+		parts := strings.SplitN(first, "..", 2)
+		lo := strings.TrimSpace(parts[0])
+		hi := strings.TrimSpace(parts[1])
+		if lo != "" {
+			expr.HasLo, expr.Lo = true, lo
+		}
+		if hi != "" {
+			expr.HasHi, expr.Hi = true, hi
+		}
+		if p.current.Type != TokenRBracket {
+			return nil, fmt.Errorf("expected ']' but got %v", p.current)
+		}
+		p.advance()
+		return expr, nil
+	}
+
+	if first != "" {
+		expr.HasLo, expr.Lo = true, first
+	}
+
+	if p.current.Type != TokenTo {
+		return nil, fmt.Errorf("expected TO in range for %q but got %v", key, p.current)
+	}
+	p.advance()
+
+	second, err := p.parseRangeBound(key)
+	if err != nil {
+		return nil, err
+	}
+	if second != "" {
+		expr.HasHi, expr.Hi = true, second
+	}
+
+	if p.current.Type != TokenRBracket {
+		return nil, fmt.Errorf("expected ']' but got %v", p.current)
+	}
+	p.advance()
+	return expr, nil
+}
+
+// parseRangeBound reads one side of a bracketed range: an ident/string
+// bound, or nothing (an open bound immediately followed by TO or ']').
+func (p *Parser) parseRangeBound(key string) (string, error) {
+	switch p.current.Type {
+	case TokenIdent, TokenString:
+		v := p.current.Value
+		p.advance()
+		return v, nil
+	case TokenTo, TokenRBracket:
+		return "", nil
+	default:
+		return "", fmt.Errorf("unexpected token %v in range for %q", p.current, key)
+	}
+}
+
+// parseComparisonValue parses key:>=value, key:<=value, key:>value, and
+// key:<value into a one-sided RangeExpr.
+func (p *Parser) parseComparisonValue(key string, op TokenType) (Node, error) {
+	p.advance() // consume the comparison operator
+
+	var value string
+	switch p.current.Type {
+	case TokenString, TokenIdent:
+		value = p.current.Value
+	default:
+		return nil, fmt.Errorf("expected value after comparison operator for %q but got %v", key, p.current)
+	}
+	p.advance()
+
+	expr := RangeExpr{Key: key}
+	switch op {
+	case TokenGt:
+		expr.HasLo, expr.Lo, expr.LoIncl = true, value, false
+	case TokenGte:
+		expr.HasLo, expr.Lo, expr.LoIncl = true, value, true
+	case TokenLt:
+		expr.HasHi, expr.Hi, expr.HiIncl = true, value, false
+	case TokenLte:
+		expr.HasHi, expr.Hi, expr.HiIncl = true, value, true
+	}
+	return expr, nil
+}
+
+// parseInValues parses the comma-separated list after key IN, e.g.
+// "service IN (checkout, billing, auth)", into an InExpr.
+func (p *Parser) parseInValues(key string) (Node, error) {
+	if p.current.Type != TokenLParen {
+		return nil, fmt.Errorf("expected '(' after 'IN' for %q but got %v", key, p.current)
+	}
+	p.advance()
+
+	var values []string
+	for {
+		switch p.current.Type {
+		case TokenString, TokenIdent:
+			values = append(values, p.current.Value)
+			p.advance()
+		default:
+			return nil, fmt.Errorf("expected value in IN list for %q but got %v", key, p.current)
+		}
+		if p.current.Type != TokenComma {
+			break
+		}
+		p.advance()
+	}
+
+	if p.current.Type != TokenRParen {
+		return nil, fmt.Errorf("expected ')' but got %v", p.current)
+	}
+	p.advance()
+	return InExpr{Key: key, Values: values}, nil
+}
+
+// parseRegexValue parses the quoted pattern after key~, compiling it once
+// so Match never needs to recompile it per row.
+func (p *Parser) parseRegexValue(key string) (Node, error) {
+	if p.current.Type != TokenString {
+		return nil, fmt.Errorf("expected quoted pattern after '%s~' but got %v", key, p.current)
+	}
+	pattern := p.current.Value
+	p.advance()
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regex for %q: %w", key, err)
+	}
+	return RegexExpr{Key: key, Pattern: pattern, Re: re}, nil
+}
@@ -185,6 +185,251 @@ func TestMatch(t *testing.T) {
 	}
 }
 
+func TestLexerRangeAndComparisonTokens(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []TokenType
+	}{
+		{"ts:[100..200]", []TokenType{TokenIdent, TokenColon, TokenLBracket, TokenIdent, TokenRBracket, TokenEOF}},
+		{"ts:[100 TO 200]", []TokenType{TokenIdent, TokenColon, TokenLBracket, TokenIdent, TokenTo, TokenIdent, TokenRBracket, TokenEOF}},
+		{"level:>=WARN", []TokenType{TokenIdent, TokenColon, TokenGte, TokenIdent, TokenEOF}},
+		{"level:<=WARN", []TokenType{TokenIdent, TokenColon, TokenLte, TokenIdent, TokenEOF}},
+		{"ts:>100", []TokenType{TokenIdent, TokenColon, TokenGt, TokenIdent, TokenEOF}},
+		{"ts:<100", []TokenType{TokenIdent, TokenColon, TokenLt, TokenIdent, TokenEOF}},
+		{`msg~"timeout"`, []TokenType{TokenIdent, TokenTilde, TokenString, TokenEOF}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			lexer := NewLexer(tt.input)
+			for i, expected := range tt.expected {
+				tok := lexer.NextToken()
+				if tok.Type != expected {
+					t.Errorf("token %d: expected %v, got %v (%q)", i, expected, tok.Type, tok.Value)
+				}
+			}
+		})
+	}
+}
+
+func TestParseRange(t *testing.T) {
+	node, err := Parse("ts:[1700000000000..1700000100000]")
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	r, ok := node.(RangeExpr)
+	if !ok {
+		t.Fatalf("expected RangeExpr, got %+v", node)
+	}
+	if !r.HasLo || r.Lo != "1700000000000" || !r.LoIncl {
+		t.Errorf("unexpected lo bound: %+v", r)
+	}
+	if !r.HasHi || r.Hi != "1700000100000" || !r.HiIncl {
+		t.Errorf("unexpected hi bound: %+v", r)
+	}
+}
+
+func TestParseRangeTo(t *testing.T) {
+	node, err := Parse("ts:[1700000000 TO 1700003600]")
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	r, ok := node.(RangeExpr)
+	if !ok {
+		t.Fatalf("expected RangeExpr, got %+v", node)
+	}
+	if !r.HasLo || r.Lo != "1700000000" || !r.LoIncl {
+		t.Errorf("unexpected lo bound: %+v", r)
+	}
+	if !r.HasHi || r.Hi != "1700003600" || !r.HiIncl {
+		t.Errorf("unexpected hi bound: %+v", r)
+	}
+}
+
+func TestParseRangeToOpenEnded(t *testing.T) {
+	node, err := Parse("ts:[1700000000 TO ]")
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	r, ok := node.(RangeExpr)
+	if !ok {
+		t.Fatalf("expected RangeExpr, got %+v", node)
+	}
+	if !r.HasLo || r.Lo != "1700000000" || r.HasHi {
+		t.Errorf("unexpected bounds: %+v", r)
+	}
+}
+
+func TestParseComparison(t *testing.T) {
+	tests := []struct {
+		query string
+		check func(RangeExpr) bool
+	}{
+		{"level:>=WARN", func(r RangeExpr) bool { return r.HasLo && r.Lo == "WARN" && r.LoIncl && !r.HasHi }},
+		{"level:>WARN", func(r RangeExpr) bool { return r.HasLo && r.Lo == "WARN" && !r.LoIncl && !r.HasHi }},
+		{"ts:<=100", func(r RangeExpr) bool { return r.HasHi && r.Hi == "100" && r.HiIncl && !r.HasLo }},
+		{"ts:<100", func(r RangeExpr) bool { return r.HasHi && r.Hi == "100" && !r.HiIncl && !r.HasLo }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			node, err := Parse(tt.query)
+			if err != nil {
+				t.Fatalf("parse error: %v", err)
+			}
+			r, ok := node.(RangeExpr)
+			if !ok || !tt.check(r) {
+				t.Errorf("unexpected result for %q: %+v", tt.query, node)
+			}
+		})
+	}
+}
+
+func TestParseRegex(t *testing.T) {
+	node, err := Parse(`msg~"timeout\s+\d+ms"`)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	r, ok := node.(RegexExpr)
+	if !ok {
+		t.Fatalf("expected RegexExpr, got %+v", node)
+	}
+	if r.Key != "msg" || r.Pattern != `timeout\s+\d+ms` || r.Re == nil {
+		t.Errorf("unexpected regex node: %+v", r)
+	}
+}
+
+func TestParseRangePrecedence(t *testing.T) {
+	// Range/comparison predicates should combine with AND/OR like any
+	// other primary expression.
+	node, err := Parse("service:order AND ts:[100..200] OR level:ERROR")
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	bin, ok := node.(BinaryExpr)
+	if !ok || bin.Op != "OR" {
+		t.Fatalf("expected OR at root, got %+v", node)
+	}
+	left, ok := bin.Left.(BinaryExpr)
+	if !ok || left.Op != "AND" {
+		t.Fatalf("expected AND on left, got %+v", bin.Left)
+	}
+	if _, ok := left.Right.(RangeExpr); !ok {
+		t.Errorf("expected RangeExpr on right of AND, got %+v", left.Right)
+	}
+}
+
+func TestMatchRangeAndRegex(t *testing.T) {
+	row := &testLogRow{
+		timestamp: 1700000050000,
+		level:     2, // WARN
+		service:   "order-service",
+		message:   "request timed out after 1500ms",
+	}
+
+	tests := []struct {
+		query    string
+		expected bool
+	}{
+		{"ts:[1700000000000..1700000100000]", true},
+		{"ts:[1700000100000..1700000200000]", false},
+		{"level:>=WARN", true},
+		{"level:>WARN", false},
+		{"level:<=WARN", true},
+		{"level:<WARN", false},
+		{`msg~"timed out after \d+ms"`, true},
+		{`msg~"^never matches$"`, false},
+		{"service:order-service AND level:>=WARN", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			node, err := Parse(tt.query)
+			if err != nil {
+				t.Fatalf("parse error: %v", err)
+			}
+			if result := Match(node, row); result != tt.expected {
+				t.Errorf("Match(%q) = %v, want %v", tt.query, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseIn(t *testing.T) {
+	node, err := Parse("service IN (checkout, billing, auth)")
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	in, ok := node.(InExpr)
+	if !ok {
+		t.Fatalf("expected InExpr, got %+v", node)
+	}
+	if in.Key != "service" {
+		t.Errorf("expected key %q, got %q", "service", in.Key)
+	}
+	want := []string{"checkout", "billing", "auth"}
+	if len(in.Values) != len(want) {
+		t.Fatalf("expected %d values, got %+v", len(want), in.Values)
+	}
+	for i, v := range want {
+		if in.Values[i] != v {
+			t.Errorf("value %d: expected %q, got %q", i, v, in.Values[i])
+		}
+	}
+}
+
+func TestMatchIn(t *testing.T) {
+	row := &testLogRow{service: "billing"}
+
+	tests := []struct {
+		query    string
+		expected bool
+	}{
+		{"service IN (checkout, billing, auth)", true},
+		{"service IN (checkout, auth)", false},
+		{"service IN (BILLING)", true}, // case-insensitive, same as "="
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			node, err := Parse(tt.query)
+			if err != nil {
+				t.Fatalf("parse error: %v", err)
+			}
+			if result := Match(node, row); result != tt.expected {
+				t.Errorf("Match(%q) = %v, want %v", tt.query, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMatchGlob(t *testing.T) {
+	row := &testLogRow{service: "checkout-worker-3", host: "web-02"}
+
+	tests := []struct {
+		query    string
+		expected bool
+	}{
+		{"service:checkout-worker-*", true},
+		{"service:payment-worker-*", false},
+		{"host:web-0?", true},
+		{"host:web-1?", false},
+		{"service:checkout-worker-3", true}, // no wildcard, exact match still works
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			node, err := Parse(tt.query)
+			if err != nil {
+				t.Fatalf("parse error: %v", err)
+			}
+			if result := Match(node, row); result != tt.expected {
+				t.Errorf("Match(%q) = %v, want %v", tt.query, result, tt.expected)
+			}
+		})
+	}
+}
+
 func TestMatchCaseInsensitive(t *testing.T) {
 	row := &testLogRow{
 		level:   3,
@@ -0,0 +1,73 @@
+// Package ratelimit implements a simple per-key token-bucket limiter,
+// used to cap requests-per-second on a per-API-token basis.
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// bucket is a classic token bucket: it holds up to rps tokens, refilling
+// continuously at rps tokens/sec, and Allow consumes one.
+type bucket struct {
+	mu         sync.Mutex
+	rps        float64
+	tokens     float64
+	lastRefill time.Time
+}
+
+func newBucket(rps int) *bucket {
+	return &bucket{
+		rps:        float64(rps),
+		tokens:     float64(rps),
+		lastRefill: time.Now(),
+	}
+}
+
+func (b *bucket) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	elapsed := now.Sub(b.lastRefill).Seconds()
+	b.lastRefill = now
+
+	b.tokens += elapsed * b.rps
+	if b.tokens > b.rps {
+		b.tokens = b.rps
+	}
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// Limiters holds one token bucket per key (typically a token ID),
+// created lazily on first use.
+type Limiters struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+// New creates an empty set of per-key limiters.
+func New() *Limiters {
+	return &Limiters{buckets: make(map[string]*bucket)}
+}
+
+// Allow reports whether a request for key is allowed under a token
+// bucket capped at rps tokens/sec, creating that key's bucket on first
+// use. rps must be positive; callers that want no limiting shouldn't
+// call Allow at all.
+func (l *Limiters) Allow(key string, rps int) bool {
+	l.mu.Lock()
+	b, ok := l.buckets[key]
+	if !ok {
+		b = newBucket(rps)
+		l.buckets[key] = b
+	}
+	l.mu.Unlock()
+
+	return b.allow()
+}
@@ -0,0 +1,32 @@
+package ratelimit
+
+import "testing"
+
+func TestAllowWithinBurst(t *testing.T) {
+	l := New()
+	for i := 0; i < 5; i++ {
+		if !l.Allow("tok-a", 5) {
+			t.Fatalf("request %d should be allowed within the initial burst", i)
+		}
+	}
+}
+
+func TestAllowRejectsOverBurst(t *testing.T) {
+	l := New()
+	for i := 0; i < 3; i++ {
+		l.Allow("tok-a", 3)
+	}
+	if l.Allow("tok-a", 3) {
+		t.Fatal("request beyond the burst should be rejected")
+	}
+}
+
+func TestAllowPerKeyIsolated(t *testing.T) {
+	l := New()
+	for i := 0; i < 2; i++ {
+		l.Allow("tok-a", 2)
+	}
+	if !l.Allow("tok-b", 2) {
+		t.Fatal("a different key should have its own independent bucket")
+	}
+}
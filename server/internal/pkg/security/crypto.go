@@ -4,25 +4,72 @@ import (
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
+	"encoding/binary"
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"hash/fnv"
 	"io"
 	"os"
 	"strings"
 )
 
-// MasterKey is the global 32-byte key used for data encryption.
-var MasterKey []byte
+// envelopeMagic and envelopeVersion prefix every envelope-encrypted blob,
+// so Decrypt can tell it apart from a pre-envelope bare AES-GCM blob
+// (whose first bytes are a random nonce) with overwhelming probability.
+const (
+	envelopeMagic   byte = 0xE1
+	envelopeVersion byte = 0x01
+	dekSize              = 32
+)
+
+// KeyProvider wraps and unwraps a per-Encrypt-call data-encryption key
+// (DEK) with a key-encryption key (KEK) it manages. Encrypt/Decrypt never
+// see the KEK itself - only the wrapped DEK travels in the ciphertext
+// header - so rotating a KeyProvider's KEK only requires rewrapping
+// existing DEKs (or, for KMS-backed providers, nothing at all) instead of
+// re-encrypting every .nano file and metadata blob.
+type KeyProvider interface {
+	// KeyID identifies which KEK wrapped a DEK, so Unwrap can pick the
+	// right key even after the active KEK has rotated.
+	KeyID() string
+	Wrap(dek []byte) (wrapped []byte, err error)
+	Unwrap(keyID string, wrapped []byte) (dek []byte, err error)
+}
+
+// legacyKEK is implemented by KeyProviders whose key material previously
+// stood in directly as the old global MasterKey, before envelope
+// encryption existed. It lets Decrypt fall back to the pre-envelope
+// format (a bare AES-GCM nonce+ciphertext) for blobs written before this
+// upgrade; the next Encrypt call on that data rewrites it in the envelope
+// format automatically.
+type legacyKEK interface {
+	rawKey() []byte
+}
+
+// activeProvider is the KeyProvider used by Encrypt/Decrypt. InitMasterKey
+// sets it to a FileKeyProvider; deployments wanting a different KMS call
+// SetKeyProvider instead.
+var activeProvider KeyProvider
+
+// SetKeyProvider installs the KeyProvider used by Encrypt/Decrypt,
+// overriding whatever InitMasterKey would otherwise configure. Call this
+// after InitMasterKey (or instead of it) to use AWSKMSProvider,
+// VaultTransitProvider, or GCPKMSProvider.
+func SetKeyProvider(p KeyProvider) {
+	activeProvider = p
+}
 
-// InitMasterKey initializes the master key from environment, file, or generates a new one.
-// Returns (true, nil) if a new key was generated.
+// InitMasterKey initializes a FileKeyProvider as the active KeyProvider
+// from environment, file, or a newly generated key. Returns (true, nil)
+// if a new key was generated. This is the same bootstrap every
+// single-node deployment used before envelope encryption existed.
 func InitMasterKey(keyPath string) (bool, error) {
 	// 1. Check Environmental Variable
 	if envKey := os.Getenv("NANOLOG_MASTER_KEY"); envKey != "" {
 		key, err := hex.DecodeString(envKey)
 		if err == nil && len(key) == 32 {
-			MasterKey = key
+			activeProvider = newFileKeyProvider(key)
 			return false, nil
 		}
 	}
@@ -37,7 +84,7 @@ func InitMasterKey(keyPath string) (bool, error) {
 		keyStr := strings.TrimSpace(string(data))
 		key, err := hex.DecodeString(keyStr)
 		if err == nil && len(key) == 32 {
-			MasterKey = key
+			activeProvider = newFileKeyProvider(key)
 			return false, nil
 		}
 	}
@@ -53,56 +100,152 @@ func InitMasterKey(keyPath string) (bool, error) {
 		return false, fmt.Errorf("failed to save master key to %s: %w", keyPath, err)
 	}
 
-	MasterKey = key
+	activeProvider = newFileKeyProvider(key)
 	return true, nil
 }
 
-// Encrypt encrypts plaintext using AES-GCM and returns Nonce + Ciphertext.
+// Encrypt envelope-encrypts plaintext: a fresh DEK encrypts plaintext
+// under AES-GCM, and the active KeyProvider wraps the DEK. The result is
+// envelopeMagic|version|len(keyID)|keyID|len(wrapped)|wrapped|nonce+ciphertext.
 func Encrypt(plaintext []byte) ([]byte, error) {
-	if len(MasterKey) != 32 {
-		return nil, errors.New("master key not initialized or invalid length")
+	if activeProvider == nil {
+		return nil, errors.New("key provider not initialized")
 	}
 
-	block, err := aes.NewCipher(MasterKey)
-	if err != nil {
+	dek := make([]byte, dekSize)
+	if _, err := io.ReadFull(rand.Reader, dek); err != nil {
 		return nil, err
 	}
 
-	gcm, err := cipher.NewGCM(block)
+	wrapped, err := activeProvider.Wrap(dek)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("wrap dek: %w", err)
 	}
 
-	nonce := make([]byte, gcm.NonceSize())
-	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+	sealed, err := gcmSeal(dek, plaintext)
+	if err != nil {
 		return nil, err
 	}
 
-	// Seal returns nonce + ciphertext
-	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+	return encodeEnvelope(activeProvider.KeyID(), wrapped, sealed), nil
 }
 
-// Decrypt decrypts ciphertext (Nonce + Ciphertext) using AES-GCM.
+// Decrypt reverses Encrypt. It also accepts the pre-envelope format (a
+// bare AES-GCM nonce+ciphertext encrypted directly under the provider's
+// raw key), so upgrading from a plain MasterKey is transparent: existing
+// blobs keep decrypting, and the next Encrypt call on that data rewrites
+// it in the envelope format.
 func Decrypt(data []byte) ([]byte, error) {
-	if len(MasterKey) != 32 {
-		return nil, errors.New("master key not initialized or invalid length")
+	if activeProvider == nil {
+		return nil, errors.New("key provider not initialized")
+	}
+
+	if keyID, wrapped, sealed, ok := decodeEnvelope(data); ok {
+		dek, err := activeProvider.Unwrap(keyID, wrapped)
+		if err != nil {
+			return nil, fmt.Errorf("unwrap dek: %w", err)
+		}
+		return gcmOpen(dek, sealed)
+	}
+
+	lk, ok := activeProvider.(legacyKEK)
+	if !ok {
+		return nil, errors.New("data is not a valid envelope and the active key provider has no legacy key")
+	}
+	return gcmOpen(lk.rawKey(), data)
+}
+
+func encodeEnvelope(keyID string, wrapped, sealed []byte) []byte {
+	out := make([]byte, 0, 2+2+len(keyID)+2+len(wrapped)+len(sealed))
+	out = append(out, envelopeMagic, envelopeVersion)
+
+	var lenBuf [2]byte
+	binary.BigEndian.PutUint16(lenBuf[:], uint16(len(keyID)))
+	out = append(out, lenBuf[:]...)
+	out = append(out, keyID...)
+
+	binary.BigEndian.PutUint16(lenBuf[:], uint16(len(wrapped)))
+	out = append(out, lenBuf[:]...)
+	out = append(out, wrapped...)
+
+	return append(out, sealed...)
+}
+
+func decodeEnvelope(data []byte) (keyID string, wrapped, sealed []byte, ok bool) {
+	if len(data) < 2 || data[0] != envelopeMagic || data[1] != envelopeVersion {
+		return "", nil, nil, false
 	}
+	pos := 2
 
-	block, err := aes.NewCipher(MasterKey)
+	if len(data) < pos+2 {
+		return "", nil, nil, false
+	}
+	keyIDLen := int(binary.BigEndian.Uint16(data[pos : pos+2]))
+	pos += 2
+	if len(data) < pos+keyIDLen {
+		return "", nil, nil, false
+	}
+	keyID = string(data[pos : pos+keyIDLen])
+	pos += keyIDLen
+
+	if len(data) < pos+2 {
+		return "", nil, nil, false
+	}
+	wrappedLen := int(binary.BigEndian.Uint16(data[pos : pos+2]))
+	pos += 2
+	if len(data) < pos+wrappedLen {
+		return "", nil, nil, false
+	}
+	wrapped = data[pos : pos+wrappedLen]
+	pos += wrappedLen
+
+	return keyID, wrapped, data[pos:], true
+}
+
+// localKeyID fingerprints a locally-held key so the envelope header can
+// identify which KEK wrapped a DEK without ever storing the key itself.
+func localKeyID(prefix string, key []byte) string {
+	h := fnv.New64a()
+	h.Write(key)
+	return prefix + ":" + hex.EncodeToString(h.Sum(nil))
+}
+
+// errUnknownKeyID is returned by local KeyProviders when asked to unwrap
+// a DEK wrapped under a key ID that doesn't match their own - most often
+// because the KEK was rotated without rewrapping existing DEKs.
+func errUnknownKeyID(keyID string) error {
+	return fmt.Errorf("unknown key id %q", keyID)
+}
+
+func gcmSeal(key, plaintext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, err
 	}
-
 	gcm, err := cipher.NewGCM(block)
 	if err != nil {
 		return nil, err
 	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
 
+func gcmOpen(key, data []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
 	nonceSize := gcm.NonceSize()
 	if len(data) < nonceSize {
 		return nil, errors.New("ciphertext too short")
 	}
-
 	nonce, ciphertext := data[:nonceSize], data[nonceSize:]
 	return gcm.Open(nil, nonce, ciphertext, nil)
 }
@@ -0,0 +1,48 @@
+package security
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+)
+
+// AWSKMSProvider wraps DEKs with an AWS KMS customer master key (CMK), so
+// the KEK itself never leaves AWS. Rotating the CMK (or its backing key
+// material, via KMS's own automatic rotation) needs no rewrap step on
+// nanolog's side: KMS resolves Decrypt calls against whichever key
+// version originally encrypted the ciphertext.
+type AWSKMSProvider struct {
+	client *kms.Client
+	keyID  string // CMK ARN or alias, e.g. "alias/nanolog-kek"
+}
+
+// NewAWSKMSProvider wraps an existing *kms.Client (built from the
+// caller's own aws.Config, so nanolog doesn't own credential resolution).
+func NewAWSKMSProvider(client *kms.Client, keyID string) *AWSKMSProvider {
+	return &AWSKMSProvider{client: client, keyID: keyID}
+}
+
+func (p *AWSKMSProvider) KeyID() string { return p.keyID }
+
+func (p *AWSKMSProvider) Wrap(dek []byte) ([]byte, error) {
+	out, err := p.client.Encrypt(context.Background(), &kms.EncryptInput{
+		KeyId:     aws.String(p.keyID),
+		Plaintext: dek,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.CiphertextBlob, nil
+}
+
+func (p *AWSKMSProvider) Unwrap(keyID string, wrapped []byte) ([]byte, error) {
+	out, err := p.client.Decrypt(context.Background(), &kms.DecryptInput{
+		KeyId:          aws.String(keyID),
+		CiphertextBlob: wrapped,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.Plaintext, nil
+}
@@ -0,0 +1,44 @@
+package security
+
+import (
+	"encoding/hex"
+	"errors"
+	"os"
+)
+
+// EnvKeyProvider reads a 32-byte hex-encoded KEK from an environment
+// variable at construction time, for deployments that inject the key via
+// their secrets manager's env-var integration rather than a mounted file.
+type EnvKeyProvider struct {
+	keyID string
+	key   []byte
+}
+
+// NewEnvKeyProvider reads envVar (e.g. "NANOLOG_MASTER_KEY") and decodes
+// it as a 32-byte hex key.
+func NewEnvKeyProvider(envVar string) (*EnvKeyProvider, error) {
+	raw := os.Getenv(envVar)
+	if raw == "" {
+		return nil, errors.New("environment variable " + envVar + " is not set")
+	}
+	key, err := hex.DecodeString(raw)
+	if err != nil || len(key) != 32 {
+		return nil, errors.New("environment variable " + envVar + " must hold a 32-byte hex key")
+	}
+	return &EnvKeyProvider{keyID: localKeyID("env", key), key: key}, nil
+}
+
+func (p *EnvKeyProvider) KeyID() string { return p.keyID }
+
+func (p *EnvKeyProvider) Wrap(dek []byte) ([]byte, error) {
+	return gcmSeal(p.key, dek)
+}
+
+func (p *EnvKeyProvider) Unwrap(keyID string, wrapped []byte) ([]byte, error) {
+	if keyID != p.keyID {
+		return nil, errUnknownKeyID(keyID)
+	}
+	return gcmOpen(p.key, wrapped)
+}
+
+func (p *EnvKeyProvider) rawKey() []byte { return p.key }
@@ -0,0 +1,50 @@
+package security
+
+import (
+	"encoding/base64"
+	"fmt"
+
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+// VaultTransitProvider wraps DEKs using Vault's transit secrets engine.
+// The KEK lives entirely inside Vault, so rotation is just `vault write
+// transit/keys/<keyName>/rotate`: Vault keeps every prior key version
+// around internally and Unwrap keeps working for DEKs wrapped under any
+// of them.
+type VaultTransitProvider struct {
+	client  *vaultapi.Client
+	keyName string // transit key name, e.g. "nanolog-kek"
+}
+
+// NewVaultTransitProvider wraps an existing authenticated *vaultapi.Client.
+func NewVaultTransitProvider(client *vaultapi.Client, keyName string) *VaultTransitProvider {
+	return &VaultTransitProvider{client: client, keyName: keyName}
+}
+
+func (p *VaultTransitProvider) KeyID() string { return p.keyName }
+
+func (p *VaultTransitProvider) Wrap(dek []byte) ([]byte, error) {
+	secret, err := p.client.Logical().Write(fmt.Sprintf("transit/encrypt/%s", p.keyName), map[string]interface{}{
+		"plaintext": base64.StdEncoding.EncodeToString(dek),
+	})
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, _ := secret.Data["ciphertext"].(string)
+	if ciphertext == "" {
+		return nil, fmt.Errorf("vault transit encrypt returned no ciphertext for key %q", p.keyName)
+	}
+	return []byte(ciphertext), nil
+}
+
+func (p *VaultTransitProvider) Unwrap(keyID string, wrapped []byte) ([]byte, error) {
+	secret, err := p.client.Logical().Write(fmt.Sprintf("transit/decrypt/%s", keyID), map[string]interface{}{
+		"ciphertext": string(wrapped),
+	})
+	if err != nil {
+		return nil, err
+	}
+	plaintextB64, _ := secret.Data["plaintext"].(string)
+	return base64.StdEncoding.DecodeString(plaintextB64)
+}
@@ -0,0 +1,147 @@
+package registry
+
+import (
+	"sync"
+
+	"github.com/coffersTech/nanolog/server/internal/controller"
+)
+
+// DefaultConfig is the ConfigResponse applied to any instance with no
+// service or instance override configured.
+var DefaultConfig = ConfigResponse{Level: "INFO", SampleRate: 100}
+
+// ConfigStore resolves the effective ConfigResponse for an instance from
+// three precedence tiers: a per-instance override wins over a per-service
+// override, which wins over the default. This lets an operator turn on
+// debug logging for a single misbehaving instance or service without
+// touching anything else.
+//
+// The default and per-service tiers are mirrored into metaStore (when
+// set) so they're persisted through restarts and so Subscribe can wake a
+// long-poll client when an operator changes one. Per-instance overrides
+// stay in-memory only, matching the Instance registrations they apply to
+// - those are ephemeral too, re-established on the SDK's next handshake.
+type ConfigStore struct {
+	mu       sync.RWMutex
+	def      ConfigResponse
+	service  map[string]ConfigResponse
+	instance map[string]ConfigResponse
+
+	metaStore *controller.Store
+}
+
+// NewConfigStore creates a ConfigStore seeded with DefaultConfig and no
+// persistence, for deployments (and tests) with no metaStore.
+func NewConfigStore() *ConfigStore {
+	return &ConfigStore{
+		def:      DefaultConfig,
+		service:  make(map[string]ConfigResponse),
+		instance: make(map[string]ConfigResponse),
+	}
+}
+
+// NewConfigStoreFromMetaStore creates a ConfigStore whose default and
+// per-service tiers are seeded from ms's persisted Config, and mirrors
+// every later SetDefault/SetServiceOverride call back into ms.
+func NewConfigStoreFromMetaStore(ms *controller.Store) *ConfigStore {
+	c := NewConfigStore()
+	c.metaStore = ms
+
+	cfg := ms.GetData().Config
+	if cfg.DefaultLogConfig.Level != "" {
+		c.def = fromServiceLogConfig(cfg.DefaultLogConfig)
+	}
+	for svc, slc := range cfg.ServiceConfig {
+		c.service[svc] = fromServiceLogConfig(slc)
+	}
+	return c
+}
+
+// Watchable reports whether changes to this ConfigStore's persisted
+// tiers can be waited on via Subscribe, i.e. whether it's backed by a
+// metaStore.
+func (c *ConfigStore) Watchable() bool {
+	return c.metaStore != nil
+}
+
+// Subscribe returns a channel that receives a value every time the
+// persisted default or per-service config changes, mirroring
+// controller.Store.SubscribeConfig. Only valid when Watchable() is true.
+func (c *ConfigStore) Subscribe() (<-chan controller.Config, func()) {
+	return c.metaStore.SubscribeConfig()
+}
+
+// SetDefault replaces the fallback config used when no override matches.
+func (c *ConfigStore) SetDefault(cfg ConfigResponse) error {
+	c.mu.Lock()
+	c.def = cfg
+	c.mu.Unlock()
+	return c.persist(func(mc *controller.Config) {
+		mc.DefaultLogConfig = toServiceLogConfig(cfg)
+	})
+}
+
+// SetServiceOverride sets the config applied to every instance of service
+// that has no more specific per-instance override.
+func (c *ConfigStore) SetServiceOverride(service string, cfg ConfigResponse) error {
+	c.mu.Lock()
+	c.service[service] = cfg
+	c.mu.Unlock()
+	return c.persist(func(mc *controller.Config) {
+		if mc.ServiceConfig == nil {
+			mc.ServiceConfig = make(map[string]controller.ServiceLogConfig)
+		}
+		mc.ServiceConfig[service] = toServiceLogConfig(cfg)
+	})
+}
+
+// SetInstanceOverride sets the config applied to instanceID, taking
+// precedence over any service or default config. Not persisted - see the
+// ConfigStore doc comment.
+func (c *ConfigStore) SetInstanceOverride(instanceID string, cfg ConfigResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.instance[instanceID] = cfg
+}
+
+// Resolve returns the effective config for instanceID, whose instances
+// belong to serviceName, applying instance > service > default precedence.
+func (c *ConfigStore) Resolve(instanceID, serviceName string) ConfigResponse {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if cfg, ok := c.instance[instanceID]; ok {
+		return cfg
+	}
+	if cfg, ok := c.service[serviceName]; ok {
+		return cfg
+	}
+	return c.def
+}
+
+// persist applies mutate to metaStore's Config and saves it, a no-op
+// when c has no metaStore configured.
+func (c *ConfigStore) persist(mutate func(*controller.Config)) error {
+	if c.metaStore == nil {
+		return nil
+	}
+	return c.metaStore.DoLockedAction("", func(cfg *controller.Config) error {
+		mutate(cfg)
+		return nil
+	})
+}
+
+func toServiceLogConfig(cfg ConfigResponse) controller.ServiceLogConfig {
+	return controller.ServiceLogConfig{
+		Level:             cfg.Level,
+		SampleRate:        cfg.SampleRate,
+		TailSamplingRules: cfg.TailSamplingRules,
+	}
+}
+
+func fromServiceLogConfig(slc controller.ServiceLogConfig) ConfigResponse {
+	return ConfigResponse{
+		Level:             slc.Level,
+		SampleRate:        slc.SampleRate,
+		TailSamplingRules: slc.TailSamplingRules,
+	}
+}
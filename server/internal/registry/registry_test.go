@@ -2,11 +2,16 @@ package registry
 
 import (
 	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/coffersTech/nanolog/server/internal/controller"
 )
 
 func TestStore_Cleanup(t *testing.T) {
@@ -65,3 +70,215 @@ func TestServer_HandleHandshake(t *testing.T) {
 		t.Error("Instance should be registered")
 	}
 }
+
+func TestServer_HandleHandshake_SessionEnvelope(t *testing.T) {
+	store := NewStore()
+	server := NewServer(store)
+
+	body := `{"instance_id":"sdk-123", "service_name":"my-service", "sdk_version":"1.0"}`
+	req := httptest.NewRequest("POST", "/api/registry/handshake", strings.NewReader(body))
+	w := httptest.NewRecorder()
+
+	server.HandleHandshake(w, req)
+
+	var resp HandshakeResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.SessionToken == "" {
+		t.Error("expected a non-empty SessionToken")
+	}
+	if resp.SessionTTLSeconds != int(sessionTTL.Seconds()) {
+		t.Errorf("expected SessionTTLSeconds %d, got %d", int(sessionTTL.Seconds()), resp.SessionTTLSeconds)
+	}
+	if resp.MaxBatchSize != defaultMaxBatchSize {
+		t.Errorf("expected MaxBatchSize %d, got %d", defaultMaxBatchSize, resp.MaxBatchSize)
+	}
+	if resp.APIKey != "" {
+		t.Error("expected no APIKey rotation with no metaStore configured")
+	}
+}
+
+func TestServer_HandleHandshake_RetentionHint(t *testing.T) {
+	metaStore := controller.NewStore(filepath.Join(t.TempDir(), "meta.enc"))
+	if err := metaStore.AddRetentionPolicy(controller.RetentionPolicy{
+		Name: "checkout-short", MaxAge: "24h", Predicate: "service:checkout",
+	}); err != nil {
+		t.Fatalf("AddRetentionPolicy: %v", err)
+	}
+
+	store := NewStore()
+	server := NewServerWithMetaStore(store, metaStore)
+
+	matched := `{"instance_id":"sdk-1","service_name":"checkout"}`
+	req := httptest.NewRequest("POST", "/api/registry/handshake", strings.NewReader(matched))
+	w := httptest.NewRecorder()
+	server.HandleHandshake(w, req)
+	var resp HandshakeResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.RetentionHint != "24h" {
+		t.Errorf("expected the matching policy's MaxAge %q, got %q", "24h", resp.RetentionHint)
+	}
+
+	unmatched := `{"instance_id":"sdk-2","service_name":"billing"}`
+	req = httptest.NewRequest("POST", "/api/registry/handshake", strings.NewReader(unmatched))
+	w = httptest.NewRecorder()
+	server.HandleHandshake(w, req)
+	resp = HandshakeResponse{}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.RetentionHint != "168h" {
+		t.Errorf("expected the global retention %q for an unmatched service, got %q", "168h", resp.RetentionHint)
+	}
+}
+
+func TestServer_HandleHandshake_RotatesExpiringToken(t *testing.T) {
+	metaStore := controller.NewStore(filepath.Join(t.TempDir(), "meta.enc"))
+	old := controller.APIToken{
+		ID:              "tok-1",
+		Name:            "checkout SDK",
+		Token:           "sk-old",
+		AllowedServices: []string{"checkout"},
+		ExpiresAt:       time.Now().Add(time.Hour).Unix(), // within tokenRotationWindow
+	}
+	if err := metaStore.AddToken(old); err != nil {
+		t.Fatalf("AddToken: %v", err)
+	}
+
+	server := NewServerWithMetaStore(NewStore(), metaStore)
+
+	body := `{"instance_id":"sdk-1","service_name":"checkout"}`
+	req := httptest.NewRequest("POST", "/api/registry/handshake", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer sk-old")
+	w := httptest.NewRecorder()
+	server.HandleHandshake(w, req)
+
+	var resp HandshakeResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.APIKey == "" || resp.APIKey == "sk-old" {
+		t.Fatalf("expected a freshly rotated APIKey, got %q", resp.APIKey)
+	}
+
+	if _, ok := metaStore.GetTokenByValue("sk-old"); ok {
+		t.Error("expected the rotated-out token to be revoked")
+	}
+	next, ok := metaStore.GetTokenByValue(resp.APIKey)
+	if !ok {
+		t.Fatal("expected the rotated token to be persisted")
+	}
+	if !reflect.DeepEqual(next.AllowedServices, old.AllowedServices) {
+		t.Errorf("expected the rotated token to keep AllowedServices %v, got %v", old.AllowedServices, next.AllowedServices)
+	}
+}
+
+func TestConfigStore_Precedence(t *testing.T) {
+	c := NewConfigStore()
+
+	if got := c.Resolve("inst-1", "svc-a"); !reflect.DeepEqual(got, DefaultConfig) {
+		t.Errorf("expected default config, got %+v", got)
+	}
+
+	c.SetServiceOverride("svc-a", ConfigResponse{Level: "WARN", SampleRate: 50})
+	if got := c.Resolve("inst-1", "svc-a"); got.Level != "WARN" || got.SampleRate != 50 {
+		t.Errorf("expected service override, got %+v", got)
+	}
+
+	c.SetInstanceOverride("inst-1", ConfigResponse{Level: "DEBUG", SampleRate: 100})
+	if got := c.Resolve("inst-1", "svc-a"); got.Level != "DEBUG" || got.SampleRate != 100 {
+		t.Errorf("expected instance override to win over service override, got %+v", got)
+	}
+
+	if got := c.Resolve("inst-2", "svc-a"); got.Level != "WARN" {
+		t.Errorf("expected other instances of svc-a to still see the service override, got %+v", got)
+	}
+}
+
+func TestServer_HandleConfig(t *testing.T) {
+	store := NewStore()
+	server := NewServer(store)
+	store.RegisterOrUpdate(Instance{InstanceID: "inst-1", ServiceName: "svc-a"})
+	server.configs.SetServiceOverride("svc-a", ConfigResponse{Level: "DEBUG", SampleRate: 10})
+
+	req := httptest.NewRequest("GET", "/api/config?instance_id=inst-1", nil)
+	w := httptest.NewRecorder()
+	server.HandleConfig(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	var resp ConfigResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.Level != "DEBUG" || resp.SampleRate != 10 {
+		t.Errorf("expected service override in response, got %+v", resp)
+	}
+
+	inst, ok := store.GetInstance("inst-1")
+	if !ok {
+		t.Fatal("instance should still be registered")
+	}
+	if time.Now().Unix()-inst.LastSeenAt > 2 {
+		t.Errorf("expected HandleConfig to refresh LastSeenAt, got %d", inst.LastSeenAt)
+	}
+}
+
+func TestServer_HandleAdminConfig(t *testing.T) {
+	store := NewStore()
+	server := NewServer(store)
+
+	body := `{"level":"ERROR","sample_rate":5}`
+	req := httptest.NewRequest("POST", "/api/registry/config/service/svc-b", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	server.HandleAdminServiceConfig(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204, got %d", w.Code)
+	}
+
+	got := server.configs.Resolve("any-instance", "svc-b")
+	if got.Level != "ERROR" || got.SampleRate != 5 {
+		t.Errorf("expected service override to be applied, got %+v", got)
+	}
+}
+
+// TestServer_HandleConfigWatchNoMetaStore confirms HandleConfigWatch
+// behaves like a plain HandleConfig call (returns immediately instead of
+// blocking) when the ConfigStore has no metaStore to wait on.
+func TestServer_HandleConfigWatchNoMetaStore(t *testing.T) {
+	store := NewStore()
+	server := NewServer(store)
+	store.RegisterOrUpdate(Instance{InstanceID: "inst-1", ServiceName: "svc-a"})
+	server.configs.SetServiceOverride("svc-a", ConfigResponse{Level: "WARN", SampleRate: 25})
+
+	body := `{"instance_id":"inst-1"}`
+	req := httptest.NewRequest("POST", "/api/registry/config/watch", strings.NewReader(body))
+	w := httptest.NewRecorder()
+
+	done := make(chan struct{})
+	go func() {
+		server.HandleConfigWatch(w, req)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected HandleConfigWatch to return immediately with no metaStore")
+	}
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	var resp ConfigResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.Level != "WARN" || resp.SampleRate != 25 {
+		t.Errorf("expected service override in response, got %+v", resp)
+	}
+}
@@ -1,24 +1,108 @@
 package registry
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"log"
 	"net/http"
+	"reflect"
 	"strings"
+	"time"
+
+	"github.com/coffersTech/nanolog/server/internal/controller"
 )
 
+// configWatchTimeout bounds how long HandleConfigWatch blocks waiting for
+// a config change before returning the instance's current config anyway,
+// so a long-poll connection can't hang forever behind a proxy or load
+// balancer.
+const configWatchTimeout = 30 * time.Second
+
+// defaultMaxBatchSize is the HandshakeResponse.MaxBatchSize advertised to
+// SDKs unless SetMaxBatchSize overrides it, matching the Go SDK's own
+// built-in batch threshold (see NanoHandler.runLoop).
+const defaultMaxBatchSize = 100
+
+// sessionTTL bounds how long a handshake session is considered current.
+// The Go SDK's background refresher re-handshakes at a fraction of this,
+// well before expiry - see nanolog's sessionRefreshFraction.
+const sessionTTL = 5 * time.Minute
+
+// tokenRotationWindow is how far ahead of an APIToken's ExpiresAt
+// HandleHandshake proactively mints and returns a replacement via
+// HandshakeResponse.APIKey, so a long-running SDK instance's key never
+// goes from valid to rejected between two handshakes. Tokens with
+// ExpiresAt == 0 never expire and are never rotated.
+const tokenRotationWindow = 24 * time.Hour
+
 // Server handles registry-related HTTP requests.
 type Server struct {
-	store *Store
+	store   *Store
+	configs *ConfigStore
+	// metaStore is nil unless this Server was built with
+	// NewServerWithMetaStore. HandleHandshake uses it directly (not
+	// just through configs) to resolve a RetentionHint and to rotate
+	// API keys, neither of which ConfigStore's precedence tiers model.
+	metaStore *controller.Store
+
+	ingestEndpoints []string
+	maxBatchSize    int
 }
 
-// NewServer creates a new registry server.
+// NewServer creates a registry server with no config persistence; admin
+// config changes are lost on restart. Use NewServerWithMetaStore to
+// persist them through the encrypted controller.Store.
 func NewServer(store *Store) *Server {
 	return &Server{
-		store: store,
+		store:        store,
+		configs:      NewConfigStore(),
+		maxBatchSize: defaultMaxBatchSize,
 	}
 }
 
-// HandleHandshake handles SDK registration and heartbeat requests.
+// NewServerWithMetaStore creates a registry server whose default and
+// per-service config tiers are persisted through metaStore, so a level
+// change an operator makes in the UI survives a restart and can be
+// observed by HandleConfigWatch. It also lets HandleHandshake resolve a
+// RetentionHint from metaStore's RetentionPolicies and rotate API keys
+// nearing expiry.
+func NewServerWithMetaStore(store *Store, metaStore *controller.Store) *Server {
+	return &Server{
+		store:        store,
+		configs:      NewConfigStoreFromMetaStore(metaStore),
+		metaStore:    metaStore,
+		maxBatchSize: defaultMaxBatchSize,
+	}
+}
+
+// SetIngestEndpoints overrides the ingest endpoint(s) advertised to SDKs
+// in HandshakeResponse.IngestEndpoints, e.g. a set of DataNode addresses
+// in a clustered deployment. Leaving this unset keeps the default of
+// "send ingest traffic to whatever server URL you handshook against".
+func (s *Server) SetIngestEndpoints(endpoints []string) {
+	s.ingestEndpoints = endpoints
+}
+
+// SetMaxBatchSize overrides the HandshakeResponse.MaxBatchSize advertised
+// to SDKs, in place of defaultMaxBatchSize.
+func (s *Server) SetMaxBatchSize(n int) {
+	s.maxBatchSize = n
+}
+
+// HandleHandshake handles SDK registration and heartbeat requests,
+// returning a full HandshakeResponse: the effective ConfigResponse
+// (resolved the same way HandleConfig does) plus the session envelope -
+// ingest endpoint(s), max batch size, a retention hint, a rotated API
+// key if one is due, and a session token with TTL for the SDK's
+// background refresher to renew ahead of expiry.
+//
+// HandleHandshake performs no auth check of its own - it trusts the mux
+// to have gated the request the same way it gates ingest, requiring a
+// valid ingest:write-scoped token before this handler ever runs (see
+// IngestServer's wiring of /api/registry/handshake), rather than
+// re-checking a bearer token here against a Server that's also used
+// directly, without a metaStore, in tests.
 // POST /api/registry/handshake
 func (s *Server) HandleHandshake(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -48,18 +132,115 @@ func (s *Server) HandleHandshake(w http.ResponseWriter, r *http.Request) {
 
 	s.store.RegisterOrUpdate(instance)
 
+	sessionToken, err := newSessionToken()
+	if err != nil {
+		http.Error(w, "failed to issue session token", http.StatusInternalServerError)
+		return
+	}
 
-	// Mock Configuration Logic
-	// Future: Fetch from DB or Config Store using instance.ServiceName
-	resp := ConfigResponse{
-		Level:      "INFO",
-		SampleRate: 100,
+	resp := HandshakeResponse{
+		ConfigResponse:    s.configs.Resolve(instance.InstanceID, instance.ServiceName),
+		IngestEndpoints:   s.ingestEndpoints,
+		MaxBatchSize:      s.maxBatchSize,
+		RetentionHint:     s.retentionHint(instance.ServiceName),
+		SessionToken:      sessionToken,
+		SessionTTLSeconds: int(sessionTTL.Seconds()),
+	}
+	if key, rotated := s.rotateAPIKey(bearerToken(r)); rotated {
+		resp.APIKey = key
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resp)
 }
 
+// bearerToken extracts the caller's token from the Authorization header,
+// or "" if it isn't a bearer token.
+func bearerToken(r *http.Request) string {
+	const prefix = "Bearer "
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, prefix) {
+		return ""
+	}
+	return strings.TrimPrefix(auth, prefix)
+}
+
+// newSessionToken generates an opaque session identifier for
+// HandshakeResponse.SessionToken. It carries no authority of its own -
+// it isn't tracked or validated server-side - it just gives the SDK and
+// an operator reading logs something stable to correlate a handshake
+// and its later renewals by.
+func newSessionToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "sess-" + hex.EncodeToString(b), nil
+}
+
+// retentionHint resolves a best-effort retention description for
+// service: the MaxAge of the most specific RetentionPolicy whose
+// Predicate names it exactly (a "service:<name>" match), falling back
+// to the global Retention. Evaluating a policy's full NanoQL predicate
+// against a file's actual attributes is the engine cleaner's job at
+// purge time; this is only a hint for the SDK to display or log, not a
+// guarantee of what will actually be kept.
+func (s *Server) retentionHint(service string) string {
+	if s.metaStore == nil {
+		return ""
+	}
+	cfg := s.metaStore.GetData().Config
+	want := "service:" + service
+	for _, p := range cfg.RetentionPolicies {
+		if p.Predicate == want {
+			return p.MaxAge
+		}
+	}
+	return cfg.Retention
+}
+
+// rotateAPIKey mints and persists a replacement for token, returning its
+// value and true, if token is a known APIToken within
+// tokenRotationWindow of its ExpiresAt. Tokens with no metaStore
+// configured, an unrecognized value, or ExpiresAt == 0 (never expires)
+// are left alone.
+func (s *Server) rotateAPIKey(token string) (string, bool) {
+	if s.metaStore == nil || token == "" {
+		return "", false
+	}
+	old, ok := s.metaStore.GetTokenByValue(token)
+	if !ok || old.ExpiresAt == 0 {
+		return "", false
+	}
+	if time.Until(time.Unix(old.ExpiresAt, 0)) > tokenRotationWindow {
+		return "", false
+	}
+
+	idBytes := make([]byte, 8)
+	if _, err := rand.Read(idBytes); err != nil {
+		return "", false
+	}
+	tokenBytes := make([]byte, 16)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return "", false
+	}
+
+	next := old
+	next.ID = hex.EncodeToString(idBytes)
+	next.Token = "sk-" + hex.EncodeToString(tokenBytes)
+	next.ExpiresAt = time.Now().Add(2 * tokenRotationWindow).Unix()
+	next.LastUsedAt = 0
+
+	if err := s.metaStore.AddToken(next); err != nil {
+		log.Printf("registry: failed to mint rotated token for %s: %v", old.ID, err)
+		return "", false
+	}
+	if err := s.metaStore.DeleteToken(old.ID); err != nil {
+		log.Printf("registry: failed to revoke rotated token %s: %v", old.ID, err)
+	}
+	return next.Token, true
+}
+
 // HandleListInstances returns a list of registered instances.
 // GET /api/registry/instances
 func (s *Server) HandleListInstances(w http.ResponseWriter, r *http.Request) {
@@ -72,3 +253,173 @@ func (s *Server) HandleListInstances(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(instances)
 }
+
+// HandleConfig returns the effective ConfigResponse for instance_id. SDKs
+// poll this endpoint periodically, so it also records a keep-alive for the
+// instance — no separate heartbeat call is needed.
+// GET /api/config?instance_id=...
+func (s *Server) HandleConfig(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	instanceID := r.URL.Query().Get("instance_id")
+	if instanceID == "" {
+		http.Error(w, "instance_id is required", http.StatusBadRequest)
+		return
+	}
+
+	var serviceName string
+	if inst, ok := s.store.GetInstance(instanceID); ok {
+		serviceName = inst.ServiceName
+	}
+	s.store.KeepAlive(instanceID)
+
+	resp := s.configs.Resolve(instanceID, serviceName)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// HandleConfigWatch is a long-poll variant of HandleConfig: instead of
+// returning immediately, it blocks up to configWatchTimeout waiting for
+// the instance's effective config to change, so an SDK can react to an
+// operator flipping a service from INFO to DEBUG within seconds instead
+// of waiting for its next regular poll interval. It still records a
+// keep-alive immediately, the same as HandleConfig. With no metaStore
+// configured there's nothing to wait on, so it behaves like HandleConfig.
+// POST /api/registry/config/watch
+func (s *Server) HandleConfigWatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		InstanceID string `json:"instance_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.InstanceID == "" {
+		http.Error(w, "instance_id is required", http.StatusBadRequest)
+		return
+	}
+
+	var serviceName string
+	if inst, ok := s.store.GetInstance(req.InstanceID); ok {
+		serviceName = inst.ServiceName
+	}
+	s.store.KeepAlive(req.InstanceID)
+
+	current := s.configs.Resolve(req.InstanceID, serviceName)
+
+	if s.configs.Watchable() {
+		ch, unsubscribe := s.configs.Subscribe()
+		defer unsubscribe()
+
+		timer := time.NewTimer(configWatchTimeout)
+		defer timer.Stop()
+
+		for {
+			select {
+			case <-ch:
+				if resp := s.configs.Resolve(req.InstanceID, serviceName); !reflect.DeepEqual(resp, current) {
+					current = resp
+					w.Header().Set("Content-Type", "application/json")
+					json.NewEncoder(w).Encode(current)
+					return
+				}
+			case <-timer.C:
+				w.Header().Set("Content-Type", "application/json")
+				json.NewEncoder(w).Encode(current)
+				return
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(current)
+}
+
+// HandleAdminDefaultConfig sets the fallback config applied to any instance
+// with no service or instance override.
+// POST /api/registry/config/default
+func (s *Server) HandleAdminDefaultConfig(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var cfg ConfigResponse
+	if err := json.NewDecoder(r.Body).Decode(&cfg); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if err := s.configs.SetDefault(cfg); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleAdminServiceConfig sets a config override for every instance of the
+// service named by the URL path's final segment.
+// POST /api/registry/config/service/{service}
+func (s *Server) HandleAdminServiceConfig(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	service := lastPathSegment(r.URL.Path)
+	if service == "" {
+		http.Error(w, "service name is required", http.StatusBadRequest)
+		return
+	}
+	var cfg ConfigResponse
+	if err := json.NewDecoder(r.Body).Decode(&cfg); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if err := s.configs.SetServiceOverride(service, cfg); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleAdminInstanceConfig sets a config override for the single instance
+// ID named by the URL path's final segment, taking precedence over any
+// service or default config.
+// POST /api/registry/config/instance/{instance_id}
+func (s *Server) HandleAdminInstanceConfig(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	instanceID := lastPathSegment(r.URL.Path)
+	if instanceID == "" {
+		http.Error(w, "instance_id is required", http.StatusBadRequest)
+		return
+	}
+	var cfg ConfigResponse
+	if err := json.NewDecoder(r.Body).Decode(&cfg); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	s.configs.SetInstanceOverride(instanceID, cfg)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// lastPathSegment returns the final "/"-separated segment of path, used to
+// pull a path parameter (service name, instance ID) out of a request served
+// on a trailing-slash pattern.
+func lastPathSegment(path string) string {
+	parts := strings.Split(strings.TrimRight(path, "/"), "/")
+	return parts[len(parts)-1]
+}
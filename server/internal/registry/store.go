@@ -4,6 +4,8 @@ import (
 	"context"
 	"sync"
 	"time"
+
+	"github.com/coffersTech/nanolog/server/internal/controller"
 )
 
 // Instance represents a registered SDK instance.
@@ -16,12 +18,75 @@ type Instance struct {
 	Language     string `json:"language"`
 	RegisteredAt int64  `json:"registered_at"`
 	LastSeenAt   int64  `json:"last_seen_at"`
+
+	// Capabilities negotiated at handshake time, below. None of these
+	// affect RegisterOrUpdate or PruneStaleInstances - they're purely
+	// informational (surfaced in HandleListInstances for an operator to
+	// inspect); HandleHandshake stores them but its HandshakeResponse
+	// does not depend on them.
+
+	// SupportedFormats are the wire formats this instance can decode a
+	// HandshakeResponse.IngestEndpoints response in, e.g. "json",
+	// "msgpack", "protobuf". Empty means JSON-only (every SDK predates
+	// this field supports at least that).
+	SupportedFormats []string `json:"supported_formats,omitempty"`
+	// CompressionCodecs are the codecs this instance can apply to its
+	// ingest batches, e.g. "gzip", "zstd".
+	CompressionCodecs []string `json:"compression_codecs,omitempty"`
+	// BufferSize is the instance's local queue capacity, in records.
+	BufferSize int `json:"buffer_size,omitempty"`
+	// NanoQLOperatorLevel is the highest NanoQL operator set this
+	// instance understands, for SDKs that evaluate tail-sampling
+	// predicates locally instead of sending every record to the server.
+	NanoQLOperatorLevel int `json:"nanoql_operator_level,omitempty"`
 }
 
 // ConfigResponse represents the dynamic configuration sent back to the SDK.
 type ConfigResponse struct {
 	Level      string `json:"level"`       // "INFO", "DEBUG"
 	SampleRate int    `json:"sample_rate"` // 0-100
+	// TailSamplingRules overrides SampleRate for records matching a
+	// specific shape (e.g. always keep records mentioning "panic").
+	TailSamplingRules []controller.TailSamplingRule `json:"tail_sampling_rules,omitempty"`
+}
+
+// HandshakeResponse is the full session envelope returned by
+// HandleHandshake, a superset of the ConfigResponse returned by the
+// lighter HandleConfig/HandleConfigWatch polls: in addition to the level
+// and sampling config every SDK understands, it carries the
+// server-assigned details a full capability exchange negotiates - where
+// to send ingest traffic, how large a batch to build, a hint about how
+// long this service's data will be retained, and the session the SDK
+// should present (and proactively renew) on its next handshake.
+type HandshakeResponse struct {
+	ConfigResponse
+
+	// IngestEndpoints are the address(es) the SDK should send ingest
+	// batches to. Empty means "keep using whatever server URL you
+	// handshook against" - the common case for a single-node deployment
+	// where the handshake and ingest endpoints are the same address.
+	IngestEndpoints []string `json:"ingest_endpoints,omitempty"`
+	// MaxBatchSize caps how many records the SDK should accumulate
+	// before flushing a batch, overriding its own built-in default.
+	MaxBatchSize int `json:"max_batch_size"`
+	// RetentionHint is a best-effort description (e.g. "720h") of how
+	// long this instance's service's data will be kept, resolved from
+	// the matching named RetentionPolicy if one applies, else the
+	// global retention. It's informational only - the engine's cleaner
+	// is the source of truth and may use criteria an SDK can't see.
+	RetentionHint string `json:"retention_hint,omitempty"`
+	// APIKey, when non-empty, replaces the bearer token the SDK has
+	// been using: the server has rotated it and the SDK must switch to
+	// it on every subsequent request, including the next handshake.
+	APIKey string `json:"api_key,omitempty"`
+	// SessionToken identifies this handshake so the SDK's background
+	// refresher can log which session it's renewing; it carries no
+	// authority of its own (APIKey/the bearer token still gate every
+	// request).
+	SessionToken string `json:"session_token"`
+	// SessionTTLSeconds is how long SessionToken should be considered
+	// current. The SDK should re-handshake before it elapses.
+	SessionTTLSeconds int `json:"session_ttl_seconds"`
 }
 
 // Store handles the storage of SDK instances.
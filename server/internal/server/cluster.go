@@ -0,0 +1,113 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/coffersTech/nanolog/server/internal/cluster"
+	"github.com/coffersTech/nanolog/server/internal/engine"
+	"github.com/coffersTech/nanolog/server/internal/httperr"
+)
+
+// searchResponse, histogramResponse, and statsResponse wrap an
+// aggregated query's result with FanoutResult so a partial fan-out is
+// visible in the JSON body, not just the X-NanoLog-Partial header.
+type searchResponse struct {
+	Rows []engine.LogRow `json:"rows"`
+	cluster.FanoutResult
+}
+
+type histogramResponse struct {
+	Points []engine.HistogramPoint `json:"points"`
+	cluster.FanoutResult
+}
+
+type statsResponse struct {
+	engine.SystemStats
+	cluster.FanoutResult
+}
+
+// searchStreamTrailer is the final NDJSON line handleSearchStream writes
+// in console role, after every row: the same FanoutResult searchResponse carries in its
+// JSON body, plus a Cursor the caller can replay via ?cursor= to resume
+// a streamed search where this one left off.
+type searchStreamTrailer struct {
+	Cursor string `json:"cursor"`
+	cluster.FanoutResult
+}
+
+// writeFanoutResponse encodes resp as JSON, additionally setting
+// X-NanoLog-Partial so a caller that only cares about the boolean can
+// check a header instead of parsing the body.
+func writeFanoutResponse(w http.ResponseWriter, resp interface{ partial() bool }) {
+	if resp.partial() {
+		w.Header().Set("X-NanoLog-Partial", "true")
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (r searchResponse) partial() bool    { return r.Partial }
+func (r histogramResponse) partial() bool { return r.Partial }
+func (r statsResponse) partial() bool     { return r.Partial }
+
+// handleClusterKeepalive receives a heartbeat POSTed by an ingester node
+// every cluster.KeepaliveInterval and records it in the console's node
+// registry.
+func (s *IngestServer) handleClusterKeepalive(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.writeErr(w, r, httperr.MethodNotAllowed())
+		return
+	}
+
+	var info cluster.NodeInfo
+	if err := json.NewDecoder(r.Body).Decode(&info); err != nil {
+		s.writeErr(w, r, httperr.New(http.StatusBadRequest, httperr.CodeBadRequest, "invalid JSON"))
+		return
+	}
+	if info.NodeID == "" {
+		s.writeErr(w, r, httperr.New(http.StatusBadRequest, httperr.CodeBadRequest, "node_id is required"))
+		return
+	}
+
+	s.registry.Keepalive(info)
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleClusterNodes returns every known ingester node and its current
+// health, gated on super_admin by AuthMiddleware.
+func (s *IngestServer) handleClusterNodes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.writeErr(w, r, httperr.MethodNotAllowed())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.registry.Nodes())
+}
+
+// handleHealth serves GET /api/health: a cheap unauthenticated liveness
+// check polled by the console's cluster.CapabilityProber. It's separate
+// from the push-based keepalive a node sends to the console's Registry -
+// a node can be keepalive-healthy (still heartbeating) yet fail this
+// probe if its query path is otherwise wedged.
+func (s *IngestServer) handleHealth(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.writeErr(w, r, httperr.MethodNotAllowed())
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleCapabilities serves GET /api/capabilities with this node's
+// cluster.NodeCapabilities, so the console's CapabilityProber can
+// downgrade a query dispatched to it instead of assuming every node in
+// the cluster understands every NanoQL operator this one does.
+func (s *IngestServer) handleCapabilities(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.writeErr(w, r, httperr.MethodNotAllowed())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cluster.LocalCapabilities())
+}
@@ -0,0 +1,13 @@
+// Package grpc implements nanolog's gRPC surface: the native NanoLog
+// service (StreamIngest, Tail) defined in proto/nanolog.proto, plus the
+// OpenTelemetry collector logs.v1 Export RPC, both served from one
+// grpc.Server so any OTLP-compatible shipper or the Go SDK can ingest over
+// a single long-lived HTTP/2 connection instead of one HTTP request per
+// batch.
+//
+// nanologpb (generated from proto/nanolog.proto) is produced by protoc as
+// part of the build, not hand-written or committed here; the OTLP
+// collector logs stubs are imported from go.opentelemetry.io/proto/otlp:
+//
+//go:generate protoc --go_out=. --go-grpc_out=. proto/nanolog.proto
+package grpc
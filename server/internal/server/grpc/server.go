@@ -0,0 +1,258 @@
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	collectorlogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+
+	"github.com/coffersTech/nanolog/server/internal/controller"
+	"github.com/coffersTech/nanolog/server/internal/engine"
+	nanologpb "github.com/coffersTech/nanolog/server/internal/server/grpc/nanologpb"
+)
+
+// ackInterval is how many StreamIngest rows accumulate between Acks. A
+// smaller interval acks sooner (shrinking the client's retry buffer) at
+// the cost of more stream traffic; 100 mirrors the JSON batch endpoint's
+// own send cadence at typical throughput.
+const ackInterval = 100
+
+// Server implements both nanolog's native gRPC service and the OTLP
+// collector logs.v1 Export RPC against a shared MemTable, so a shipper can
+// use whichever surface fits it best without nanolog running two separate
+// ingestion code paths.
+type Server struct {
+	nanologpb.UnimplementedNanoLogServer
+	collectorlogspb.UnimplementedLogsServiceServer
+
+	mt        *engine.MemTable
+	metaStore *controller.Store
+}
+
+// NewServer creates a Server that ingests into and tails mt. metaStore
+// authenticates every RPC the same way HTTP ingest/query do (bearer-token
+// lookup via GetTokenByValue plus a scope check - see authenticate); pass
+// nil to run without auth, e.g. on a specialized Engine node.
+func NewServer(mt *engine.MemTable, metaStore *controller.Store) *Server {
+	return &Server{mt: mt, metaStore: metaStore}
+}
+
+// hasScope reports whether scopes grants required, treating "*" as a
+// wildcard, mirroring server.hasScope's HTTP-side equivalent.
+func hasScope(scopes []string, required string) bool {
+	for _, sc := range scopes {
+		if sc == required || sc == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// authenticate looks up the bearer token ctx carries and checks it grants
+// requiredScope, the same check every HTTP route with an equivalent scope
+// enforces via AuthMiddleware. It is a no-op (every RPC is allowed) when
+// metaStore is nil, e.g. on a specialized Engine node that runs without
+// token auth.
+func (s *Server) authenticate(ctx context.Context, requiredScope string) error {
+	if s.metaStore == nil {
+		return nil
+	}
+	token, exists := s.metaStore.GetTokenByValue(bearerToken(ctx))
+	if !exists {
+		return status.Error(codes.Unauthenticated, "missing or invalid bearer token")
+	}
+	if !hasScope(token.Scopes, requiredScope) {
+		return status.Errorf(codes.PermissionDenied, "token missing %s scope", requiredScope)
+	}
+	return nil
+}
+
+// bearerToken extracts the bearer token a gRPC client sent alongside its
+// call, mirroring how an OTLP/HTTP exporter sends it as an "Authorization:
+// Bearer <token>" header: gRPC clients send the same pair as request
+// metadata instead.
+func bearerToken(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	for _, v := range md.Get("authorization") {
+		if strings.HasPrefix(v, "Bearer ") {
+			return strings.TrimPrefix(v, "Bearer ")
+		}
+	}
+	return ""
+}
+
+// Register registers both services on srv.
+func (s *Server) Register(srv *grpc.Server) {
+	nanologpb.RegisterNanoLogServer(srv, s)
+	collectorlogspb.RegisterLogsServiceServer(srv, s)
+}
+
+// StreamIngest requires the same ingest:write scope Export does, then
+// appends every received LogRow to MemTable and, every ackInterval rows,
+// acks the sequence number of the highest row appended so far, so the
+// client's retry buffer can discard what's durably received and resend
+// only what wasn't.
+func (s *Server) StreamIngest(stream nanologpb.NanoLog_StreamIngestServer) error {
+	if err := s.authenticate(stream.Context(), "ingest:write"); err != nil {
+		return err
+	}
+
+	var sequence uint64
+	var sinceAck int
+
+	for {
+		row, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		s.mt.Append(row.Timestamp, engine.DecodeLevel(uint8(row.Level)), row.Service, row.Host, row.Message)
+		sequence++
+		sinceAck++
+
+		if sinceAck >= ackInterval {
+			if err := stream.Send(&nanologpb.Ack{Sequence: sequence}); err != nil {
+				return err
+			}
+			sinceAck = 0
+		}
+	}
+}
+
+// Tail requires a logs:read-scoped token, mirroring the HTTP /api/tail
+// route, then streams newly appended rows matching filter until the client
+// cancels the RPC or the subscription channel is closed.
+func (s *Server) Tail(filter *nanologpb.Filter, stream nanologpb.NanoLog_TailServer) error {
+	if err := s.authenticate(stream.Context(), "logs:read"); err != nil {
+		return err
+	}
+
+	ch, unsubscribe := s.mt.Subscribe(engine.Filter{
+		Level:   uint8(filter.Level),
+		Service: filter.Service,
+		Host:    filter.Host,
+		Query:   filter.Q,
+	})
+	defer unsubscribe()
+
+	for {
+		select {
+		case row, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			if err := stream.Send(&nanologpb.LogRow{
+				Timestamp: row.Timestamp,
+				Level:     uint32(row.Level),
+				Service:   row.Service,
+				Host:      row.Host,
+				Message:   row.Message,
+			}); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		}
+	}
+}
+
+// Export implements the OTLP collector logs.v1 service, mapping every
+// LogRecord in req into MemTable via the same Append path HTTP ingest and
+// StreamIngest use, so nanolog needs no OTLP-specific storage format.
+func (s *Server) Export(ctx context.Context, req *collectorlogspb.ExportLogsServiceRequest) (*collectorlogspb.ExportLogsServiceResponse, error) {
+	if err := s.authenticate(ctx, "ingest:write"); err != nil {
+		return nil, err
+	}
+
+	for _, rl := range req.ResourceLogs {
+		host := resourceAttr(rl.Resource, "host.name")
+		service := resourceAttr(rl.Resource, "service.name")
+		if service == "" {
+			service = "otlp"
+		}
+
+		for _, sl := range rl.ScopeLogs {
+			for _, rec := range sl.LogRecords {
+				ts := int64(rec.TimeUnixNano)
+				if ts == 0 {
+					ts = int64(rec.ObservedTimeUnixNano)
+				}
+				s.mt.AppendWithAttrs(ts, severityToLevel(rec.SeverityNumber), service, host, rec.Body.GetStringValue(), attrsToJSON(rec.Attributes))
+			}
+		}
+	}
+	return &collectorlogspb.ExportLogsServiceResponse{}, nil
+}
+
+// attrsToJSON encodes an OTLP LogRecord's structured attributes as a JSON
+// object, {key: value}, for storage in MemTable.AttrCol. Only string-typed
+// values are captured for now, matching resourceAttr's handling of
+// resource attributes elsewhere in this file; nil is returned (and
+// AttrCol's row left empty) when attrs carries nothing.
+func attrsToJSON(attrs []*commonpb.KeyValue) []byte {
+	if len(attrs) == 0 {
+		return nil
+	}
+	m := make(map[string]string, len(attrs))
+	for _, kv := range attrs {
+		m[kv.Key] = kv.Value.GetStringValue()
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return nil
+	}
+	return b
+}
+
+// resourceAttr returns the string value of the attribute named key on
+// resource, or "" if resource is nil, the key is absent, or its value
+// isn't a string.
+func resourceAttr(resource *resourcepb.Resource, key string) string {
+	if resource == nil {
+		return ""
+	}
+	for _, kv := range resource.Attributes {
+		if kv.Key == key {
+			return kv.Value.GetStringValue()
+		}
+	}
+	return ""
+}
+
+// severityToLevel maps an OTLP SeverityNumber onto nanolog's level codes.
+// OTLP reserves 4 severity numbers per level (TRACE 1-4, DEBUG 5-8, INFO
+// 9-12, WARN 13-16, ERROR 17-20, FATAL 21-24) for finer-grained tools;
+// nanolog only distinguishes the coarse level, so every number in a band
+// maps to the same code.
+func severityToLevel(sev logspb.SeverityNumber) string {
+	switch {
+	case sev >= 21:
+		return "FATAL"
+	case sev >= 17:
+		return "ERROR"
+	case sev >= 13:
+		return "WARN"
+	case sev >= 9:
+		return "INFO"
+	case sev >= 1:
+		return "DEBUG"
+	default:
+		return "INFO"
+	}
+}
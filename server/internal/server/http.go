@@ -3,9 +3,11 @@ package server
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"net"
 	"net/http"
 	"strconv"
 	"strings"
@@ -17,9 +19,16 @@ import (
 	"encoding/hex"
 	"sync"
 
+	oidcauth "github.com/coffersTech/nanolog/server/internal/auth/oidc"
+	"github.com/coffersTech/nanolog/server/internal/audit"
 	"github.com/coffersTech/nanolog/server/internal/cluster"
 	"github.com/coffersTech/nanolog/server/internal/controller"
 	"github.com/coffersTech/nanolog/server/internal/engine"
+	"github.com/coffersTech/nanolog/server/internal/httperr"
+	"github.com/coffersTech/nanolog/server/internal/ingest"
+	"github.com/coffersTech/nanolog/server/internal/pkg/nanoql"
+	"github.com/coffersTech/nanolog/server/internal/pkg/ratelimit"
+	sdkregistry "github.com/coffersTech/nanolog/server/internal/registry"
 	"github.com/valyala/fastjson"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -44,18 +53,85 @@ type IngestServer struct {
 	ingestRate    int64 // Requests per second (updated periodically)
 	role          string
 	aggregator    *cluster.Aggregator
+	registry      *cluster.Registry         // node health, populated by handleClusterKeepalive
+	prober        *cluster.CapabilityProber // nil unless aggregator != nil; probes registry's nodes for health/capabilities
+
+	// sdkRegistry serves the SDK-facing instance registry: effective-config
+	// poll/watch, the handshake/session exchange, and the admin endpoints
+	// that set a default/per-service/per-instance config override. Always
+	// non-nil; persists through metaStore when one is configured.
+	sdkRegistry *sdkregistry.Server
+
+	// OIDC single sign-on. oidcVerifier is lazily built from the current
+	// metaStore.GetData().Config.OIDC by oidcVerifierFor and rebuilt only
+	// when that config changes (oidcVerifierCfg tracks what it was built
+	// from). oidcPending holds in-flight authorization-code logins,
+	// keyed by their OAuth2 state value.
+	oidcMu          sync.Mutex
+	oidcVerifier    *oidcauth.Verifier
+	oidcVerifierCfg controller.OIDCConfig
+	oidcPending     map[string]oidcPendingAuth
+	oidcPendingMu   sync.Mutex
+
+	stopConfigWatch func() // unsubscribes queryEngine from metaStore's config updates
+
+	// Per-token concurrent /api/tail stream accounting. Guarded by
+	// tailSlotsMu.
+	tailSlots   map[string]int
+	tailSlotsMu sync.Mutex
+
+	// tokenLimiters enforces each APIToken's RateLimitRPS. auditLog
+	// records token lifecycle, failed auth, and admin actions; nil (e.g.
+	// dataDir couldn't be opened) disables audit logging rather than
+	// failing requests.
+	tokenLimiters *ratelimit.Limiters
+	auditLog      *audit.Logger
 }
 
 func NewIngestServer(qe *engine.QueryEngine, ms *controller.Store, webDir string, dataDir string, role string, aggregator *cluster.Aggregator) *IngestServer {
-	return &IngestServer{
-		queryEngine: qe,
-		metaStore:   ms,
-		webDir:      webDir,
-		dataDir:     dataDir,
-		sessions:    make(map[string]UserSession),
-		role:        role,
-		aggregator:  aggregator,
+	registry := cluster.NewRegistry()
+	var prober *cluster.CapabilityProber
+	if aggregator != nil {
+		aggregator.Registry = registry
+		prober = cluster.NewCapabilityProber(registry)
+		prober.Start()
 	}
+
+	var sdkReg *sdkregistry.Server
+	if ms != nil {
+		sdkReg = sdkregistry.NewServerWithMetaStore(sdkregistry.NewStore(), ms)
+	} else {
+		sdkReg = sdkregistry.NewServer(sdkregistry.NewStore())
+	}
+
+	s := &IngestServer{
+		queryEngine:   qe,
+		metaStore:     ms,
+		webDir:        webDir,
+		dataDir:       dataDir,
+		sessions:      make(map[string]UserSession),
+		role:          role,
+		aggregator:    aggregator,
+		registry:      registry,
+		prober:        prober,
+		sdkRegistry:   sdkReg,
+		tailSlots:     make(map[string]int),
+		tokenLimiters: ratelimit.New(),
+	}
+
+	if dataDir != "" {
+		if al, err := audit.NewLogger(dataDir); err != nil {
+			log.Printf("audit log disabled: failed to open audit.log under %s: %v", dataDir, err)
+		} else {
+			s.auditLog = al
+		}
+	}
+
+	if qe != nil && ms != nil {
+		s.stopConfigWatch = qe.WatchConfig(ms)
+	}
+
+	return s
 }
 
 // Start runs the HTTP server.
@@ -74,7 +150,7 @@ func (s *IngestServer) Start(addr string, role string) error {
 
 	s.srv = &http.Server{
 		Addr:    addr,
-		Handler: mux,
+		Handler: withRequestID(mux),
 	}
 
 	if err := s.srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
@@ -85,9 +161,12 @@ func (s *IngestServer) Start(addr string, role string) error {
 
 func (s *IngestServer) RegisterConsoleRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("/api/login", s.handleLogin)
+	mux.HandleFunc("/api/auth/oidc/login", s.handleOIDCLogin)
+	mux.HandleFunc("/api/auth/oidc/callback", s.handleOIDCCallback)
 	mux.HandleFunc("/api/system/status", s.handleSystemStatus)
 	mux.HandleFunc("/api/system/init", s.handleSystemInit)
 	mux.Handle("/api/system/config", s.AuthMiddleware(http.HandlerFunc(s.handleSystemConfig)))
+	mux.Handle("/api/system/config/", s.AuthMiddleware(http.HandlerFunc(s.handleSystemConfigField)))
 
 	// User management (SuperAdmin)
 	mux.Handle("/api/users", s.AuthMiddleware(http.HandlerFunc(s.handleUsers)))
@@ -97,10 +176,36 @@ func (s *IngestServer) RegisterConsoleRoutes(mux *http.ServeMux) {
 	mux.Handle("/api/tokens", s.AuthMiddleware(http.HandlerFunc(s.handleTokens)))
 	mux.Handle("/api/tokens/", s.AuthMiddleware(http.HandlerFunc(s.handleTokenItem)))
 
+	// Retention policy management (SuperAdmin)
+	mux.Handle("/api/admin/retention/policies", s.AuthMiddleware(http.HandlerFunc(s.handleRetentionPolicies)))
+	mux.Handle("/api/admin/retention/policies/", s.AuthMiddleware(http.HandlerFunc(s.handleRetentionPolicyItem)))
+
 	// Aggregated Search/Stats (Console specific)
 	mux.Handle("/api/search", s.AuthMiddleware(http.HandlerFunc(s.handleQuery)))
+	mux.Handle("/api/search/stream", s.AuthMiddleware(http.HandlerFunc(s.handleSearchStream)))
 	mux.Handle("/api/histogram", s.AuthMiddleware(http.HandlerFunc(s.handleHistogram)))
 	mux.Handle("/api/stats", s.AuthMiddleware(http.HandlerFunc(s.handleStats)))
+	mux.Handle("/api/tail", s.AuthMiddleware(http.HandlerFunc(s.handleTail)))
+
+	// Cluster node registry: ingesters heartbeat in, super_admins list
+	// the result.
+	mux.Handle("/api/cluster/keepalive", s.AuthMiddleware(http.HandlerFunc(s.handleClusterKeepalive)))
+	mux.Handle("/api/cluster/nodes", s.AuthMiddleware(http.HandlerFunc(s.handleClusterNodes)))
+
+	// Audit trail (SuperAdmin)
+	mux.Handle("/api/audit", s.AuthMiddleware(http.HandlerFunc(s.handleAudit)))
+
+	// SDK handshake/session exchange and effective-config poll/long-poll
+	// (Authenticated, same token as ingest), its admin overrides
+	// (SuperAdmin), and the registered-instance listing (SuperAdmin) -
+	// see sdkRegistry's doc comment.
+	mux.Handle("/api/registry/handshake", s.AuthMiddleware(http.HandlerFunc(s.sdkRegistry.HandleHandshake)))
+	mux.Handle("/api/config", s.AuthMiddleware(http.HandlerFunc(s.sdkRegistry.HandleConfig)))
+	mux.Handle("/api/registry/config/watch", s.AuthMiddleware(http.HandlerFunc(s.sdkRegistry.HandleConfigWatch)))
+	mux.Handle("/api/registry/instances", s.AuthMiddleware(http.HandlerFunc(s.sdkRegistry.HandleListInstances)))
+	mux.Handle("/api/registry/config/default", s.AuthMiddleware(http.HandlerFunc(s.sdkRegistry.HandleAdminDefaultConfig)))
+	mux.Handle("/api/registry/config/service/", s.AuthMiddleware(http.HandlerFunc(s.sdkRegistry.HandleAdminServiceConfig)))
+	mux.Handle("/api/registry/config/instance/", s.AuthMiddleware(http.HandlerFunc(s.sdkRegistry.HandleAdminInstanceConfig)))
 
 	// Static file serving for web directory
 	if s.webDir != "" {
@@ -110,41 +215,216 @@ func (s *IngestServer) RegisterConsoleRoutes(mux *http.ServeMux) {
 }
 
 func (s *IngestServer) RegisterIngesterRoutes(mux *http.ServeMux) {
+	// Unauthenticated liveness/capability probes: polled by the console's
+	// CapabilityProber (and anything else, e.g. a load balancer, that
+	// wants a cheap health check without presenting a token).
+	mux.HandleFunc("/api/health", s.handleHealth)
+	mux.HandleFunc("/api/capabilities", s.handleCapabilities)
+
 	// Ingest endpoint (Authenticated)
 	mux.Handle("/api/ingest", s.AuthMiddleware(http.HandlerFunc(s.handleIngest)))
 
+	// Protocol adapters: let existing agents (Filebeat, Promtail, the OTEL
+	// collector) point at nanolog directly, without a shim in front of it.
+	mux.Handle("/api/ingest/elasticsearch/_bulk", s.AuthMiddleware(s.protocolIngestHandler(ingest.ESBulkDecoder{}, writeBulkSuccess)))
+	mux.Handle("/loki/api/v1/push", s.AuthMiddleware(s.protocolIngestHandler(ingest.LokiDecoder{}, writeNoContent)))
+	mux.Handle("/v1/logs", s.AuthMiddleware(s.protocolIngestHandler(ingest.OTLPJSONDecoder{}, writeOTLPSuccess)))
+
 	// Internal/Local Query endpoints
 	// If it's standalone, these are already registered via RegisterConsoleRoutes with Auth
 	// If it's pure ingester, we might want different auth or specific internal endpoints.
 	if s.role == "ingester" {
 		mux.HandleFunc("/api/search", s.handleQuery)
+		mux.HandleFunc("/api/search/stream", s.handleSearchStream)
 		mux.HandleFunc("/api/histogram", s.handleHistogram)
 		mux.HandleFunc("/api/stats", s.handleStats)
+		mux.HandleFunc("/api/tail", s.handleTail)
 	}
 }
 
 // Shutdown gracefully shuts down the server.
 func (s *IngestServer) Shutdown(ctx context.Context) error {
+	if s.stopConfigWatch != nil {
+		s.stopConfigWatch()
+	}
+	if s.prober != nil {
+		s.prober.Stop()
+	}
+	if s.auditLog != nil {
+		s.auditLog.Close()
+	}
 	if s.srv != nil {
 		return s.srv.Shutdown(ctx)
 	}
 	return nil
 }
 
+// requiresSuperAdmin reports whether path is restricted to super_admin
+// users, for the auth branches that have a role to check against.
+func requiresSuperAdmin(path string) bool {
+	return strings.HasPrefix(path, "/api/users") ||
+		strings.HasPrefix(path, "/api/cluster/nodes") ||
+		strings.HasPrefix(path, "/api/audit") ||
+		strings.HasPrefix(path, "/api/registry/instances") ||
+		strings.HasPrefix(path, "/api/registry/config/default") ||
+		strings.HasPrefix(path, "/api/registry/config/service/") ||
+		strings.HasPrefix(path, "/api/registry/config/instance/")
+}
+
+// requiredScope returns the API token scope a route requires, or "" if
+// any authenticated token may call it. Session/OIDC auth is role-based
+// (see requiresSuperAdmin) and isn't subject to scopes.
+func requiredScope(path string) string {
+	switch {
+	case strings.HasPrefix(path, "/api/ingest"),
+		strings.HasPrefix(path, "/loki/"),
+		strings.HasPrefix(path, "/v1/logs"),
+		strings.HasPrefix(path, "/api/config"),
+		strings.HasPrefix(path, "/api/registry/handshake"),
+		strings.HasPrefix(path, "/api/registry/config/watch"):
+		return "ingest:write"
+	case strings.HasPrefix(path, "/api/users"):
+		return "admin:users"
+	case strings.HasPrefix(path, "/api/search"),
+		strings.HasPrefix(path, "/api/histogram"),
+		strings.HasPrefix(path, "/api/stats"),
+		strings.HasPrefix(path, "/api/tail"):
+		return "logs:read"
+	default:
+		return ""
+	}
+}
+
+// hasScope reports whether scopes grants required, treating "*" as a
+// wildcard that grants every scope.
+func hasScope(scopes []string, required string) bool {
+	for _, sc := range scopes {
+		if sc == required || sc == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// containsString reports whether list contains s.
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// hostAllowed reports whether ip matches one of cidrs. An empty cidrs
+// list means the token isn't restricted to any source.
+func hostAllowed(cidrs []string, ip string) bool {
+	if len(cidrs) == 0 {
+		return true
+	}
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, c := range cidrs {
+		_, network, err := net.ParseCIDR(c)
+		if err != nil {
+			continue
+		}
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP strips the port off r.RemoteAddr, the same way handleIngest
+// derives a fallback host for logs with no explicit "host" field.
+func clientIP(r *http.Request) string {
+	ip := r.RemoteAddr
+	if idx := strings.LastIndex(ip, ":"); idx != -1 {
+		ip = ip[:idx]
+	}
+	return ip
+}
+
+// audit appends an entry to the audit log if one is configured; it's a
+// no-op otherwise so a server started without dataDir (or that failed to
+// open audit.log) still serves requests normally.
+func (s *IngestServer) audit(actor, ip, action, outcome, detail string) {
+	if s.auditLog == nil {
+		return
+	}
+	if err := s.auditLog.Log(audit.Entry{
+		Timestamp: time.Now().Unix(),
+		Actor:     actor,
+		IP:        ip,
+		Action:    action,
+		Outcome:   outcome,
+		Detail:    detail,
+	}); err != nil {
+		log.Printf("audit log write failed: %v", err)
+	}
+}
+
+// sessionUsername resolves r's bearer token back to a logged-in
+// session's username, for stamping audit entries with an actor - the
+// same lookup handleTokens already does to set APIToken.CreatedBy.
+func (s *IngestServer) sessionUsername(r *http.Request) string {
+	s.sessionsMu.RLock()
+	defer s.sessionsMu.RUnlock()
+	return s.sessions[bearerToken(r)].Username
+}
+
+// handleAudit serves GET /api/audit, returning the most recent audit log
+// entries. Gated to super_admin by AuthMiddleware/requiresSuperAdmin.
+func (s *IngestServer) handleAudit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.writeErr(w, r, httperr.MethodNotAllowed())
+		return
+	}
+	if s.auditLog == nil {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode([]audit.Entry{})
+		return
+	}
+
+	limit := 200
+	if ls := r.URL.Query().Get("limit"); ls != "" {
+		if parsed, err := strconv.Atoi(ls); err == nil && parsed > 0 {
+			limit = parsed
+		}
+	}
+
+	entries, err := s.auditLog.Recent(limit)
+	if err != nil {
+		s.writeErr(w, r, httperr.New(http.StatusInternalServerError, httperr.CodeInternal, "failed to read audit log"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
+
+// bearerToken extracts the caller's token from the Authorization header,
+// falling back to the "token" query parameter for callers (e.g. an
+// EventSource in the browser) that can't set custom headers.
+func bearerToken(r *http.Request) string {
+	authHeader := r.Header.Get("Authorization")
+	if strings.HasPrefix(authHeader, "Bearer ") {
+		return strings.TrimPrefix(authHeader, "Bearer ")
+	}
+	return r.URL.Query().Get("token")
+}
+
 // AuthMiddleware checks for a valid token in the Authorization header.
 func (s *IngestServer) AuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		authHeader := r.Header.Get("Authorization")
-		var token string
-		if strings.HasPrefix(authHeader, "Bearer ") {
-			token = strings.TrimPrefix(authHeader, "Bearer ")
-		} else {
-			token = r.URL.Query().Get("token")
-		}
+		token := bearerToken(r)
 
 		if token == "" {
 			w.Header().Set("WWW-Authenticate", `Bearer realm="NanoLog"`)
-			http.Error(w, "Unauthorized: Missing token", http.StatusUnauthorized)
+			s.writeErr(w, r, httperr.New(http.StatusUnauthorized, httperr.CodeAuthMissingToken, "missing bearer token"))
 			return
 		}
 
@@ -157,12 +437,57 @@ func (s *IngestServer) AuthMiddleware(next http.Handler) http.Handler {
 
 		// Logic Branch A: SDK / API Key (from meta)
 		if apiToken, exists := s.metaStore.GetTokenByValue(token); exists {
-			// Attach user info to context if needed?
-			_ = apiToken
+			ip := clientIP(r)
+
+			if apiToken.ExpiresAt > 0 && time.Now().Unix() > apiToken.ExpiresAt {
+				s.audit(apiToken.ID, ip, "auth_failed", "failure", "token expired")
+				s.writeErr(w, r, httperr.New(http.StatusUnauthorized, httperr.CodeAuthExpired, "token expired"))
+				return
+			}
+
+			if !hostAllowed(apiToken.AllowedHosts, ip) {
+				s.audit(apiToken.ID, ip, "auth_failed", "failure", "source IP not allowed for token")
+				s.writeErr(w, r, httperr.New(http.StatusForbidden, httperr.CodeAuthForbidden, "source IP not allowed for this token"))
+				return
+			}
+
+			if scope := requiredScope(r.URL.Path); scope != "" && !hasScope(apiToken.Scopes, scope) {
+				s.audit(apiToken.ID, ip, "auth_failed", "failure", fmt.Sprintf("token missing scope %q", scope))
+				s.writeErr(w, r, httperr.New(http.StatusForbidden, httperr.CodeAuthForbidden, fmt.Sprintf("token missing required scope %q", scope)))
+				return
+			}
+
+			if apiToken.RateLimitRPS > 0 && !s.tokenLimiters.Allow(apiToken.ID, apiToken.RateLimitRPS) {
+				s.audit(apiToken.ID, ip, "auth_failed", "failure", "rate limit exceeded")
+				s.writeErr(w, r, httperr.New(http.StatusTooManyRequests, httperr.CodeRateLimited, "rate limit exceeded"))
+				return
+			}
+
+			s.metaStore.TouchTokenUsage(apiToken.ID, time.Now().Unix())
 			next.ServeHTTP(w, r)
 			return
 		}
 
+		// Logic Branch C: OIDC-issued JWT, verified directly against the
+		// configured provider. This lets a script or short-lived CLI
+		// token skip the authorization-code dance and present the ID
+		// token straight to the API.
+		if oidcCfg := s.metaStore.GetData().Config.OIDC; oidcCfg.IssuerURL != "" {
+			if v, err := s.oidcVerifierFor(oidcCfg); err == nil {
+				if claims, err := v.Verify(r.Context(), token); err == nil {
+					if role, ok := v.MapRole(claims); ok {
+						if requiresSuperAdmin(r.URL.Path) && role != "super_admin" {
+							s.audit(claims.Subject, clientIP(r), "auth_failed", "failure", "super_admin role required")
+							s.writeErr(w, r, httperr.New(http.StatusForbidden, httperr.CodeAuthForbidden, "super_admin role required"))
+							return
+						}
+						next.ServeHTTP(w, r)
+						return
+					}
+				}
+			}
+		}
+
 		// Logic Branch B: Web Session
 		s.sessionsMu.RLock()
 		session, exists := s.sessions[token]
@@ -173,14 +498,15 @@ func (s *IngestServer) AuthMiddleware(next http.Handler) http.Handler {
 				// Optional: Check if user also exists in meta (role check)
 				user, exists := s.metaStore.GetUser(session.Username)
 				if !exists {
-					http.Error(w, "User no longer exists", http.StatusUnauthorized)
+					s.writeErr(w, r, httperr.New(http.StatusUnauthorized, httperr.CodeAuthExpired, "user no longer exists"))
 					return
 				}
 
 				// Role check for specific routes
-				if strings.HasPrefix(r.URL.Path, "/api/users") {
+				if requiresSuperAdmin(r.URL.Path) {
 					if user.Role != "super_admin" {
-						http.Error(w, "Forbidden: SuperAdmin required", http.StatusForbidden)
+						s.audit(user.Username, clientIP(r), "auth_failed", "failure", "super_admin role required")
+						s.writeErr(w, r, httperr.New(http.StatusForbidden, httperr.CodeAuthForbidden, "super_admin role required"))
 						return
 					}
 				}
@@ -193,8 +519,9 @@ func (s *IngestServer) AuthMiddleware(next http.Handler) http.Handler {
 			s.sessionsMu.Unlock()
 		}
 
+		s.audit("", clientIP(r), "auth_failed", "failure", "invalid or expired token")
 		w.Header().Set("WWW-Authenticate", `Bearer realm="NanoLog"`)
-		http.Error(w, "Unauthorized: Invalid or expired token", http.StatusUnauthorized)
+		s.writeErr(w, r, httperr.New(http.StatusUnauthorized, httperr.CodeAuthExpired, "invalid or expired token"))
 	})
 }
 
@@ -214,12 +541,12 @@ func (s *IngestServer) handleSystemStatus(w http.ResponseWriter, r *http.Request
 // handleSystemInit initializes the system with the first SuperAdmin.
 func (s *IngestServer) handleSystemInit(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		s.writeErr(w, r, httperr.MethodNotAllowed())
 		return
 	}
 
 	if s.metaStore.IsInitialized() {
-		http.Error(w, "System already initialized", http.StatusBadRequest)
+		s.writeErr(w, r, httperr.New(http.StatusBadRequest, httperr.CodeBadRequest, "system already initialized"))
 		return
 	}
 
@@ -228,17 +555,17 @@ func (s *IngestServer) handleSystemInit(w http.ResponseWriter, r *http.Request)
 		Password string `json:"password"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		s.writeErr(w, r, httperr.New(http.StatusBadRequest, httperr.CodeBadRequest, "invalid JSON"))
 		return
 	}
 
 	if req.Username == "" || req.Password == "" {
-		http.Error(w, "Username and password required", http.StatusBadRequest)
+		s.writeErr(w, r, httperr.New(http.StatusBadRequest, httperr.CodeBadRequest, "username and password required"))
 		return
 	}
 
 	if err := s.metaStore.InitializeSystem(req.Username, req.Password); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		s.writeErr(w, r, httperr.New(http.StatusInternalServerError, httperr.CodeInternal, err.Error()))
 		return
 	}
 
@@ -247,7 +574,7 @@ func (s *IngestServer) handleSystemInit(w http.ResponseWriter, r *http.Request)
 
 func (s *IngestServer) handleLogin(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		s.writeErr(w, r, httperr.MethodNotAllowed())
 		return
 	}
 
@@ -256,18 +583,18 @@ func (s *IngestServer) handleLogin(w http.ResponseWriter, r *http.Request) {
 		Password string `json:"password"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		s.writeErr(w, r, httperr.New(http.StatusBadRequest, httperr.CodeBadRequest, "invalid JSON"))
 		return
 	}
 
 	user, exists := s.metaStore.GetUser(req.Username)
 	if !exists {
-		http.Error(w, "Invalid username or password", http.StatusUnauthorized)
+		s.writeErr(w, r, httperr.New(http.StatusUnauthorized, httperr.CodeAuthInvalidUser, "invalid username or password"))
 		return
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
-		http.Error(w, "Invalid username or password", http.StatusUnauthorized)
+		s.writeErr(w, r, httperr.New(http.StatusUnauthorized, httperr.CodeAuthInvalidUser, "invalid username or password"))
 		return
 	}
 
@@ -295,9 +622,17 @@ func (s *IngestServer) createSession(w http.ResponseWriter, username, role strin
 	})
 }
 
+// handleSystemConfig serves GET/POST /api/system/config. GET returns the
+// config's current Fingerprint as an ETag; POST requires that value back
+// as If-Match, so two admins editing config at once get a 409 instead of
+// one silently overwriting the other's change. A successful POST is
+// applied to the running QueryEngine immediately via DoLockedAction's
+// publish, with no restart required.
 func (s *IngestServer) handleSystemConfig(w http.ResponseWriter, r *http.Request) {
 	if r.Method == http.MethodGet {
 		data := s.metaStore.GetData()
+		w.Header().Set("ETag", s.metaStore.Fingerprint())
+		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(data.Config)
 		return
 	}
@@ -305,28 +640,173 @@ func (s *IngestServer) handleSystemConfig(w http.ResponseWriter, r *http.Request
 	if r.Method == http.MethodPost {
 		var cfg controller.Config
 		if err := json.NewDecoder(r.Body).Decode(&cfg); err != nil {
-			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			s.writeErr(w, r, httperr.New(http.StatusBadRequest, httperr.CodeBadRequest, "invalid JSON"))
 			return
 		}
 
 		// Validate retention duration
 		if _, err := time.ParseDuration(cfg.Retention); err != nil {
-			http.Error(w, "Invalid retention duration format", http.StatusBadRequest)
+			s.writeErr(w, r, httperr.New(http.StatusBadRequest, httperr.CodeBadRequest, "invalid retention duration format"))
+			return
+		}
+
+		ifMatch := r.Header.Get("If-Match")
+		if ifMatch == "" {
+			s.writeErr(w, r, httperr.New(http.StatusBadRequest, httperr.CodeBadRequest, "If-Match header is required"))
 			return
 		}
 
-		if err := s.metaStore.UpdateConfig(cfg); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+		err := s.metaStore.DoLockedAction(ifMatch, func(c *controller.Config) error {
+			*c = cfg
+			return nil
+		})
+		switch {
+		case errors.Is(err, controller.ErrConfigConflict):
+			s.writeErr(w, r, httperr.New(http.StatusConflict, httperr.CodeConfigConflict, "config was modified concurrently; refetch and retry"))
+			return
+		case err != nil:
+			s.writeErr(w, r, httperr.New(http.StatusInternalServerError, httperr.CodeInternal, err.Error()))
 			return
 		}
 
-		// Note: Ideally we would trigger an update in QueryEngine too.
-		// For now, it will take effect on next restart or we can pass it via reference.
+		s.audit(s.sessionUsername(r), clientIP(r), "config_update", "success", "")
+		w.Header().Set("ETag", s.metaStore.Fingerprint())
 		w.WriteHeader(http.StatusOK)
 		return
 	}
 }
 
+// handleSystemConfigField serves GET/PUT /api/system/config/{path},
+// addressing a single Config field by its JSON tag path (slash-separated
+// for nested fields, e.g. oidc/client_id) instead of requiring the whole
+// document — so the UI can save one setting, like rotating retention,
+// without racing a concurrent admin's edit to an unrelated field. Same
+// ETag/If-Match concurrency contract as handleSystemConfig.
+func (s *IngestServer) handleSystemConfigField(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/api/system/config/")
+	if path == "" {
+		s.writeErr(w, r, httperr.New(http.StatusBadRequest, httperr.CodeBadRequest, "config field path is required"))
+		return
+	}
+	segments := strings.Split(path, "/")
+
+	switch r.Method {
+	case http.MethodGet:
+		cfg := s.metaStore.GetData().Config
+		val, ok := configFieldValue(cfg, segments)
+		if !ok {
+			s.writeErr(w, r, httperr.New(http.StatusNotFound, httperr.CodeNotFound, fmt.Sprintf("unknown config field %q", path)))
+			return
+		}
+		w.Header().Set("ETag", s.metaStore.Fingerprint())
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(val)
+
+	case http.MethodPut:
+		var value interface{}
+		if err := json.NewDecoder(r.Body).Decode(&value); err != nil {
+			s.writeErr(w, r, httperr.New(http.StatusBadRequest, httperr.CodeBadRequest, "invalid JSON"))
+			return
+		}
+
+		ifMatch := r.Header.Get("If-Match")
+		if ifMatch == "" {
+			s.writeErr(w, r, httperr.New(http.StatusBadRequest, httperr.CodeBadRequest, "If-Match header is required"))
+			return
+		}
+
+		err := s.metaStore.DoLockedAction(ifMatch, func(c *controller.Config) error {
+			return setConfigField(c, segments, value)
+		})
+		switch {
+		case errors.Is(err, controller.ErrConfigConflict):
+			s.writeErr(w, r, httperr.New(http.StatusConflict, httperr.CodeConfigConflict, "config was modified concurrently; refetch and retry"))
+			return
+		case err != nil:
+			s.writeErr(w, r, httperr.New(http.StatusBadRequest, httperr.CodeBadRequest, err.Error()))
+			return
+		}
+
+		s.audit(s.sessionUsername(r), clientIP(r), "config_update", "success", path)
+		w.Header().Set("ETag", s.metaStore.Fingerprint())
+		w.WriteHeader(http.StatusOK)
+
+	default:
+		s.writeErr(w, r, httperr.MethodNotAllowed())
+	}
+}
+
+// configFieldValue resolves segments (a slash-split field path, e.g.
+// ["oidc", "client_id"]) against cfg's JSON representation. There's no
+// full JSONPath query support here — just addressing one field by its
+// tag path, which is all the UI's per-field save needs.
+func configFieldValue(cfg controller.Config, segments []string) (interface{}, bool) {
+	raw, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, false
+	}
+	var generic map[string]interface{}
+	if err := json.Unmarshal(raw, &generic); err != nil {
+		return nil, false
+	}
+
+	var cur interface{} = generic
+	for _, seg := range segments {
+		m, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		cur, ok = m[seg]
+		if !ok {
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// setConfigField sets the field addressed by segments on cfg to value by
+// round-tripping through cfg's JSON representation, the same addressing
+// scheme configFieldValue reads with.
+func setConfigField(cfg *controller.Config, segments []string, value interface{}) error {
+	raw, err := json.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	var generic map[string]interface{}
+	if err := json.Unmarshal(raw, &generic); err != nil {
+		return err
+	}
+
+	if err := setNestedField(generic, segments, value); err != nil {
+		return err
+	}
+
+	merged, err := json.Marshal(generic)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(merged, cfg)
+}
+
+func setNestedField(m map[string]interface{}, segments []string, value interface{}) error {
+	if len(segments) == 0 {
+		return fmt.Errorf("empty config field path")
+	}
+	if len(segments) == 1 {
+		if _, ok := m[segments[0]]; !ok {
+			return fmt.Errorf("unknown config field %q", segments[0])
+		}
+		m[segments[0]] = value
+		return nil
+	}
+
+	next, ok := m[segments[0]].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("unknown config field %q", segments[0])
+	}
+	return setNestedField(next, segments[1:], value)
+}
+
 func (s *IngestServer) handleUsers(w http.ResponseWriter, r *http.Request) {
 	if r.Method == http.MethodGet {
 		data := s.metaStore.GetData()
@@ -347,7 +827,7 @@ func (s *IngestServer) handleUsers(w http.ResponseWriter, r *http.Request) {
 			Role     string `json:"role"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			s.writeErr(w, r, httperr.New(http.StatusBadRequest, httperr.CodeBadRequest, "invalid JSON"))
 			return
 		}
 
@@ -359,9 +839,10 @@ func (s *IngestServer) handleUsers(w http.ResponseWriter, r *http.Request) {
 			CreatedAt:    time.Now().Unix(),
 		})
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			s.writeErr(w, r, httperr.New(http.StatusBadRequest, httperr.CodeBadRequest, err.Error()))
 			return
 		}
+		s.audit(s.sessionUsername(r), clientIP(r), "user_create", "success", req.Username)
 		w.WriteHeader(http.StatusCreated)
 		return
 	}
@@ -373,9 +854,10 @@ func (s *IngestServer) handleUserItem(w http.ResponseWriter, r *http.Request) {
 
 	if r.Method == http.MethodDelete {
 		if err := s.metaStore.DeleteUser(username); err != nil {
-			http.Error(w, err.Error(), http.StatusNotFound)
+			s.writeErr(w, r, httperr.New(http.StatusNotFound, httperr.CodeNotFound, err.Error()))
 			return
 		}
+		s.audit(s.sessionUsername(r), clientIP(r), "user_delete", "success", username)
 		w.WriteHeader(http.StatusNoContent)
 		return
 	}
@@ -390,11 +872,16 @@ func (s *IngestServer) handleTokens(w http.ResponseWriter, r *http.Request) {
 
 	if r.Method == http.MethodPost {
 		var req struct {
-			Name string `json:"name"`
-			Type string `json:"type"`
+			Name            string   `json:"name"`
+			Type            string   `json:"type"`
+			Scopes          []string `json:"scopes"`
+			AllowedServices []string `json:"allowed_services"`
+			AllowedHosts    []string `json:"allowed_hosts"`
+			RateLimitRPS    int      `json:"rate_limit_rps"`
+			ExpiresAt       int64    `json:"expires_at"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			s.writeErr(w, r, httperr.New(http.StatusBadRequest, httperr.CodeBadRequest, "invalid JSON"))
 			return
 		}
 
@@ -414,16 +901,22 @@ func (s *IngestServer) handleTokens(w http.ResponseWriter, r *http.Request) {
 		s.sessionsMu.RUnlock()
 
 		err := s.metaStore.AddToken(controller.APIToken{
-			ID:        id,
-			Name:      req.Name,
-			Token:     tokenVal,
-			Type:      req.Type,
-			CreatedBy: session.Username,
+			ID:              id,
+			Name:            req.Name,
+			Token:           tokenVal,
+			Type:            req.Type,
+			CreatedBy:       session.Username,
+			Scopes:          req.Scopes,
+			AllowedServices: req.AllowedServices,
+			AllowedHosts:    req.AllowedHosts,
+			RateLimitRPS:    req.RateLimitRPS,
+			ExpiresAt:       req.ExpiresAt,
 		})
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			s.writeErr(w, r, httperr.New(http.StatusInternalServerError, httperr.CodeInternal, err.Error()))
 			return
 		}
+		s.audit(session.Username, clientIP(r), "token_create", "success", fmt.Sprintf("id=%s name=%s", id, req.Name))
 		json.NewEncoder(w).Encode(map[string]string{"token": tokenVal, "id": id})
 		return
 	}
@@ -435,18 +928,86 @@ func (s *IngestServer) handleTokenItem(w http.ResponseWriter, r *http.Request) {
 
 	if r.Method == http.MethodDelete {
 		if err := s.metaStore.DeleteToken(id); err != nil {
-			http.Error(w, err.Error(), http.StatusNotFound)
+			s.writeErr(w, r, httperr.New(http.StatusNotFound, httperr.CodeNotFound, err.Error()))
+			return
+		}
+		s.audit(s.sessionUsername(r), clientIP(r), "token_delete", "success", id)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+}
+
+// handleRetentionPolicies lists and creates named retention policies.
+// Policies live in controller.Config (persisted by metaStore the same
+// way Retention itself is), so a create/delete here takes effect on the
+// running QueryEngine via WatchConfig/ApplyConfig without a restart.
+func (s *IngestServer) handleRetentionPolicies(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		json.NewEncoder(w).Encode(s.metaStore.GetData().Config.RetentionPolicies)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		var req controller.RetentionPolicy
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			s.writeErr(w, r, httperr.New(http.StatusBadRequest, httperr.CodeBadRequest, "invalid JSON"))
+			return
+		}
+		if req.Name == "" {
+			s.writeErr(w, r, httperr.New(http.StatusBadRequest, httperr.CodeBadRequest, "name is required"))
+			return
+		}
+		if _, err := time.ParseDuration(req.MaxAge); err != nil {
+			s.writeErr(w, r, httperr.New(http.StatusBadRequest, httperr.CodeBadRequest, "invalid max_age duration format"))
+			return
+		}
+		if _, err := nanoql.Parse(req.Predicate); err != nil {
+			s.writeErr(w, r, httperr.New(http.StatusBadRequest, httperr.CodeBadRequest, "invalid predicate: "+err.Error()))
+			return
+		}
+
+		if err := s.metaStore.AddRetentionPolicy(req); err != nil {
+			s.writeErr(w, r, httperr.New(http.StatusBadRequest, httperr.CodeBadRequest, err.Error()))
+			return
+		}
+		s.audit(s.sessionUsername(r), clientIP(r), "retention_policy_create", "success", req.Name)
+		w.WriteHeader(http.StatusCreated)
+		return
+	}
+
+	s.writeErr(w, r, httperr.MethodNotAllowed())
+}
+
+// handleRetentionPolicyItem deletes a single named retention policy.
+func (s *IngestServer) handleRetentionPolicyItem(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(r.URL.Path, "/")
+	name := parts[len(parts)-1]
+
+	if r.Method == http.MethodDelete {
+		if err := s.metaStore.DeleteRetentionPolicy(name); err != nil {
+			s.writeErr(w, r, httperr.New(http.StatusNotFound, httperr.CodeNotFound, fmt.Sprintf("policy %q not found", name)))
 			return
 		}
+		s.audit(s.sessionUsername(r), clientIP(r), "retention_policy_delete", "success", name)
 		w.WriteHeader(http.StatusNoContent)
 		return
 	}
+
+	s.writeErr(w, r, httperr.MethodNotAllowed())
 }
 
 // handleIngest processes POST requests with JSON logs.
 func (s *IngestServer) handleIngest(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		s.writeErr(w, r, httperr.MethodNotAllowed())
+		return
+	}
+
+	// Newline-delimited JSON is routed by Content-Type rather than a
+	// separate path, so agents that already POST NDJSON to /api/ingest
+	// (e.g. Filebeat's http output) need no reconfiguration.
+	if ct := r.Header.Get("Content-Type"); strings.Contains(ct, "ndjson") {
+		s.protocolIngestHandler(ingest.NDJSONDecoder{}, writeBulkSuccess)(w, r)
 		return
 	}
 
@@ -458,7 +1019,7 @@ func (s *IngestServer) handleIngest(w http.ResponseWriter, r *http.Request) {
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
 		log.Printf("Failed to read body: %v", err)
-		http.Error(w, "Failed to read body", http.StatusInternalServerError)
+		s.writeErr(w, r, httperr.New(http.StatusInternalServerError, httperr.CodeInternal, "failed to read body"))
 		return
 	}
 	defer r.Body.Close()
@@ -473,10 +1034,20 @@ func (s *IngestServer) handleIngest(w http.ResponseWriter, r *http.Request) {
 	v, err := p.ParseBytes(body)
 	if err != nil {
 		log.Printf("JSON Parse Error: %v. Body: %s", err, string(body))
-		http.Error(w, fmt.Sprintf("Invalid JSON: %v", err), http.StatusBadRequest)
+		s.writeErr(w, r, httperr.New(http.StatusBadRequest, httperr.CodeIngestInvalidJSON, fmt.Sprintf("invalid JSON: %v", err)))
 		return
 	}
 
+	// A token scoped to specific services (APIToken.AllowedServices) may
+	// only ingest logs tagged with one of them; an entry for any other
+	// service is dropped rather than failing the whole batch.
+	var allowedServices []string
+	if s.metaStore != nil {
+		if tok, exists := s.metaStore.GetTokenByValue(bearerToken(r)); exists {
+			allowedServices = tok.AllowedServices
+		}
+	}
+
 	// Helper function to process a single log object
 	processLog := func(val *fastjson.Value) {
 		tsVal := val.GetInt64("timestamp")
@@ -491,6 +1062,10 @@ func (s *IngestServer) handleIngest(w http.ResponseWriter, r *http.Request) {
 			serviceStr = "default"
 		}
 
+		if len(allowedServices) > 0 && !containsString(allowedServices, serviceStr) {
+			return
+		}
+
 		hostStr := string(val.GetStringBytes("host"))
 		if hostStr == "" {
 			// Fallback: Use IP from connection (strip port)
@@ -527,7 +1102,7 @@ func (s *IngestServer) handleIngest(w http.ResponseWriter, r *http.Request) {
 // handleQuery processes GET /api/search requests.
 func (s *IngestServer) handleQuery(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		s.writeErr(w, r, httperr.MethodNotAllowed())
 		return
 	}
 
@@ -538,24 +1113,27 @@ func (s *IngestServer) handleQuery(w http.ResponseWriter, r *http.Request) {
 			Limit:    s.parseLimit(r),
 			Auth:     r.Header.Get("Authorization"),
 		}
-		rows, err := s.aggregator.Search(params)
+		rows, fanout, err := s.aggregator.Search(r.Context(), params)
 		if err != nil {
-			http.Error(w, "Aggregation failed", http.StatusInternalServerError)
+			s.writeErr(w, r, httperr.New(http.StatusInternalServerError, httperr.CodeClusterAggregationFailed, err.Error()))
 			return
 		}
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(rows)
+		writeFanoutResponse(w, searchResponse{Rows: rows, FanoutResult: fanout})
 		return
 	}
 
 	// 2. Standalone/Ingester behavior: Execute local scan
-	filter := s.parseFilter(r)
+	filter, err := s.parseFilter(r)
+	if err != nil {
+		s.writeErr(w, r, httperr.New(http.StatusBadRequest, httperr.CodeQueryBadFilter, err.Error()))
+		return
+	}
 	limit := s.parseLimit(r)
 
 	rows, err := s.queryEngine.ExecuteScan(filter, limit)
 	if err != nil {
 		log.Printf("Query error: %v", err)
-		http.Error(w, "Query failed", http.StatusInternalServerError)
+		s.writeErr(w, r, httperr.New(http.StatusInternalServerError, httperr.CodeInternal, "query failed"))
 		return
 	}
 
@@ -563,35 +1141,131 @@ func (s *IngestServer) handleQuery(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(rows)
 }
 
-func (s *IngestServer) parseFilter(r *http.Request) engine.Filter {
+// handleSearchStream is handleQuery's streaming counterpart: same
+// filters and the same row order, but written as one NDJSON
+// object per row (flushed as it goes) instead of a single buffered JSON
+// array. In console role this lets the caller start consuming before
+// the whole cluster has answered, and keeps the console's own memory use
+// down to one in-flight row per node rather than every node's full
+// result - see Aggregator.SearchStream. In console role the final line
+// is a searchStreamTrailer, distinguishable from a LogRow by its "cursor"
+// field (which a row never has), carrying the same partial-result
+// bookkeeping handleQuery returns plus a cursor the caller can replay
+// via ?cursor= to page forward. In standalone/ingester role only rows
+// are written, with no trailer.
+func (s *IngestServer) handleSearchStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.writeErr(w, r, httperr.MethodNotAllowed())
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		s.writeErr(w, r, httperr.New(http.StatusInternalServerError, httperr.CodeInternal, "streaming not supported"))
+		return
+	}
+
+	if s.role == "console" {
+		params := cluster.QueryParams{
+			RawQuery: r.URL.RawQuery,
+			Limit:    s.parseLimit(r),
+			Auth:     r.Header.Get("Authorization"),
+			Cursor:   r.URL.Query().Get("cursor"),
+		}
+		rows, done, err := s.aggregator.SearchStream(r.Context(), params)
+		if err != nil {
+			s.writeErr(w, r, httperr.New(http.StatusInternalServerError, httperr.CodeClusterAggregationFailed, err.Error()))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.WriteHeader(http.StatusOK)
+
+		enc := json.NewEncoder(w)
+		for row := range rows {
+			if err := enc.Encode(row); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+
+		result := <-done
+		enc.Encode(searchStreamTrailer{Cursor: result.Cursor.Encode(), FanoutResult: result.Fanout})
+		flusher.Flush()
+		return
+	}
+
+	filter, err := s.parseFilter(r)
+	if err != nil {
+		s.writeErr(w, r, httperr.New(http.StatusBadRequest, httperr.CodeQueryBadFilter, err.Error()))
+		return
+	}
+	limit := s.parseLimit(r)
+
+	rows, err := s.queryEngine.ExecuteScan(filter, limit)
+	if err != nil {
+		log.Printf("Query error: %v", err)
+		s.writeErr(w, r, httperr.New(http.StatusInternalServerError, httperr.CodeInternal, "query failed"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+
+	enc := json.NewEncoder(w)
+	for _, row := range rows {
+		if err := enc.Encode(row); err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// parseFilter builds a Filter from r's query parameters, reporting an
+// error for any parameter present but malformed rather than silently
+// ignoring it the way parseLimit does for "limit" — an operator pointing
+// a dashboard at a bad min_ts deserves a 400, not results silently
+// spanning all time.
+func (s *IngestServer) parseFilter(r *http.Request) (engine.Filter, error) {
 	filter := engine.Filter{}
-	minTsStr := r.URL.Query().Get("min_ts")
+	q := r.URL.Query()
+
+	minTsStr := q.Get("min_ts")
 	if minTsStr == "" {
-		minTsStr = r.URL.Query().Get("start")
+		minTsStr = q.Get("start")
 	}
 	if minTsStr != "" {
-		if val, err := strconv.ParseInt(minTsStr, 10, 64); err == nil {
-			filter.MinTime = val
+		val, err := strconv.ParseInt(minTsStr, 10, 64)
+		if err != nil {
+			return filter, fmt.Errorf("invalid min_ts/start %q: %w", minTsStr, err)
 		}
+		filter.MinTime = val
 	}
-	maxTsStr := r.URL.Query().Get("max_ts")
+
+	maxTsStr := q.Get("max_ts")
 	if maxTsStr == "" {
-		maxTsStr = r.URL.Query().Get("end")
+		maxTsStr = q.Get("end")
 	}
 	if maxTsStr != "" {
-		if val, err := strconv.ParseInt(maxTsStr, 10, 64); err == nil {
-			filter.MaxTime = val
+		val, err := strconv.ParseInt(maxTsStr, 10, 64)
+		if err != nil {
+			return filter, fmt.Errorf("invalid max_ts/end %q: %w", maxTsStr, err)
 		}
+		filter.MaxTime = val
 	}
-	if levelStr := r.URL.Query().Get("level"); levelStr != "" {
-		if val, err := strconv.Atoi(levelStr); err == nil {
-			filter.Level = uint8(val)
+
+	if levelStr := q.Get("level"); levelStr != "" {
+		val, err := strconv.Atoi(levelStr)
+		if err != nil {
+			return filter, fmt.Errorf("invalid level %q: %w", levelStr, err)
 		}
+		filter.Level = uint8(val)
 	}
-	filter.Service = r.URL.Query().Get("service")
-	filter.Host = r.URL.Query().Get("host")
-	filter.Query = r.URL.Query().Get("q")
-	return filter
+
+	filter.Service = q.Get("service")
+	filter.Host = q.Get("host")
+	filter.Query = q.Get("q")
+	return filter, nil
 }
 
 func (s *IngestServer) parseLimit(r *http.Request) int {
@@ -607,7 +1281,7 @@ func (s *IngestServer) parseLimit(r *http.Request) int {
 
 func (s *IngestServer) handleHistogram(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		s.writeErr(w, r, httperr.MethodNotAllowed())
 		return
 	}
 
@@ -616,13 +1290,12 @@ func (s *IngestServer) handleHistogram(w http.ResponseWriter, r *http.Request) {
 			RawQuery: r.URL.RawQuery,
 			Auth:     r.Header.Get("Authorization"),
 		}
-		points, err := s.aggregator.Histogram(params)
+		points, fanout, err := s.aggregator.Histogram(r.Context(), params)
 		if err != nil {
-			http.Error(w, "Aggregation failed", http.StatusInternalServerError)
+			s.writeErr(w, r, httperr.New(http.StatusInternalServerError, httperr.CodeClusterAggregationFailed, err.Error()))
 			return
 		}
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(points)
+		writeFanoutResponse(w, histogramResponse{Points: points, FanoutResult: fanout})
 		return
 	}
 
@@ -652,10 +1325,14 @@ func (s *IngestServer) handleHistogram(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	filter := s.parseFilter(r)
+	filter, err := s.parseFilter(r)
+	if err != nil {
+		s.writeErr(w, r, httperr.New(http.StatusBadRequest, httperr.CodeQueryBadFilter, err.Error()))
+		return
+	}
 	points, err := s.queryEngine.ComputeHistogram(start, end, interval, filter)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		s.writeErr(w, r, httperr.New(http.StatusInternalServerError, httperr.CodeInternal, err.Error()))
 		return
 	}
 
@@ -666,18 +1343,17 @@ func (s *IngestServer) handleHistogram(w http.ResponseWriter, r *http.Request) {
 // handleStats calculates system statistics.
 func (s *IngestServer) handleStats(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		s.writeErr(w, r, httperr.MethodNotAllowed())
 		return
 	}
 
 	if s.role == "console" {
-		stats, err := s.aggregator.Stats(r.Header.Get("Authorization"))
+		stats, fanout, err := s.aggregator.Stats(r.Context(), r.Header.Get("Authorization"))
 		if err != nil {
-			http.Error(w, "Aggregation failed", http.StatusInternalServerError)
+			s.writeErr(w, r, httperr.New(http.StatusInternalServerError, httperr.CodeClusterAggregationFailed, err.Error()))
 			return
 		}
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(stats)
+		writeFanoutResponse(w, statsResponse{SystemStats: stats, FanoutResult: fanout})
 		return
 	}
 
@@ -0,0 +1,129 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"time"
+
+	oidcauth "github.com/coffersTech/nanolog/server/internal/auth/oidc"
+	"github.com/coffersTech/nanolog/server/internal/controller"
+	"github.com/coffersTech/nanolog/server/internal/httperr"
+)
+
+// oidcPendingAuth is a login attempt awaiting its provider callback,
+// keyed by the OAuth2 "state" value. It expires quickly since a login
+// that never completes the redirect round trip shouldn't linger.
+type oidcPendingAuth struct {
+	CodeVerifier string
+	Expires      time.Time
+}
+
+// oidcVerifierFor lazily builds and caches the oidc.Verifier for the
+// system's current OIDC config, rebuilding only when that config changes
+// so an ordinary request doesn't pay for a discovery-document fetch.
+func (s *IngestServer) oidcVerifierFor(cfg controller.OIDCConfig) (*oidcauth.Verifier, error) {
+	s.oidcMu.Lock()
+	defer s.oidcMu.Unlock()
+
+	if s.oidcVerifier != nil && reflect.DeepEqual(s.oidcVerifierCfg, cfg) {
+		return s.oidcVerifier, nil
+	}
+
+	v, err := oidcauth.NewVerifier(context.Background(), oidcauth.Config{
+		IssuerURL:    cfg.IssuerURL,
+		ClientID:     cfg.ClientID,
+		ClientSecret: cfg.ClientSecret,
+		RedirectURL:  cfg.RedirectURL,
+		RoleClaim:    cfg.RoleClaim,
+		RoleMappings: cfg.RoleMappings,
+		DefaultRole:  cfg.DefaultRole,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.oidcVerifier = v
+	s.oidcVerifierCfg = cfg
+	return v, nil
+}
+
+// handleOIDCLogin starts the authorization-code + PKCE flow by
+// redirecting the browser to the provider, stashing the PKCE verifier
+// under a freshly minted state value until handleOIDCCallback returns.
+func (s *IngestServer) handleOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	cfg := s.metaStore.GetData().Config.OIDC
+	if cfg.IssuerURL == "" {
+		s.writeErr(w, r, httperr.New(http.StatusNotFound, httperr.CodeNotFound, "OIDC SSO is not configured"))
+		return
+	}
+
+	v, err := s.oidcVerifierFor(cfg)
+	if err != nil {
+		s.writeErr(w, r, httperr.New(http.StatusInternalServerError, httperr.CodeInternal, err.Error()))
+		return
+	}
+
+	state := newRequestID()
+	authURL, codeVerifier := v.AuthCodeURL(state)
+
+	s.oidcPendingMu.Lock()
+	if s.oidcPending == nil {
+		s.oidcPending = make(map[string]oidcPendingAuth)
+	}
+	s.oidcPending[state] = oidcPendingAuth{CodeVerifier: codeVerifier, Expires: time.Now().Add(10 * time.Minute)}
+	s.oidcPendingMu.Unlock()
+
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// handleOIDCCallback completes the flow: it exchanges the authorization
+// code for tokens, verifies the ID token, maps the configured claim to a
+// nanolog role, and stores the resulting session the same way
+// createSession does for a password login.
+func (s *IngestServer) handleOIDCCallback(w http.ResponseWriter, r *http.Request) {
+	cfg := s.metaStore.GetData().Config.OIDC
+	if cfg.IssuerURL == "" {
+		s.writeErr(w, r, httperr.New(http.StatusNotFound, httperr.CodeNotFound, "OIDC SSO is not configured"))
+		return
+	}
+
+	state := r.URL.Query().Get("state")
+	code := r.URL.Query().Get("code")
+
+	s.oidcPendingMu.Lock()
+	pending, ok := s.oidcPending[state]
+	if ok {
+		delete(s.oidcPending, state)
+	}
+	s.oidcPendingMu.Unlock()
+
+	if !ok || time.Now().After(pending.Expires) {
+		s.writeErr(w, r, httperr.New(http.StatusBadRequest, httperr.CodeAuthExpired, "invalid or expired OIDC login attempt"))
+		return
+	}
+
+	v, err := s.oidcVerifierFor(cfg)
+	if err != nil {
+		s.writeErr(w, r, httperr.New(http.StatusInternalServerError, httperr.CodeInternal, err.Error()))
+		return
+	}
+
+	claims, err := v.Exchange(r.Context(), code, pending.CodeVerifier)
+	if err != nil {
+		s.writeErr(w, r, httperr.New(http.StatusUnauthorized, httperr.CodeAuthInvalidUser, err.Error()))
+		return
+	}
+
+	role, ok := v.MapRole(claims)
+	if !ok {
+		s.writeErr(w, r, httperr.New(http.StatusForbidden, httperr.CodeAuthForbidden, "no nanolog role mapped for this identity"))
+		return
+	}
+
+	username := claims.Email
+	if username == "" {
+		username = claims.Subject
+	}
+	s.createSession(w, username, role)
+}
@@ -0,0 +1,91 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/coffersTech/nanolog/server/internal/engine"
+	"github.com/coffersTech/nanolog/server/internal/httperr"
+	"github.com/coffersTech/nanolog/server/internal/ingest"
+)
+
+// bulkResponseItem is one failed record in a bulkResponse, indexed the
+// same way Elasticsearch's _bulk response indexes per-action failures.
+type bulkResponseItem struct {
+	Index int    `json:"index"`
+	Error string `json:"error"`
+}
+
+// bulkResponse reports how many records from a protocol-decoded batch
+// were accepted, and which (if any) failed to decode.
+type bulkResponse struct {
+	Errors   bool               `json:"errors"`
+	Accepted int                `json:"accepted"`
+	Failed   []bulkResponseItem `json:"failed,omitempty"`
+}
+
+// protocolIngestHandler decodes POST bodies with decoder and feeds every
+// resulting engine.LogEvent to s.queryEngine.Ingest, syncing the WAL once
+// per request just like handleIngest does. A decoder reporting a
+// *ingest.BatchError still has every accepted record synced; only the
+// failed indexes are rejected, mirroring Elasticsearch's partial-success
+// _bulk response instead of discarding the whole batch over one bad line.
+// onSuccess writes the response body for a batch with no failures at all,
+// since each protocol's clients expect a different shape there (Loki
+// wants 204, OTLP wants an empty ExportLogsServiceResponse, bulk loaders
+// want an errors:false body).
+func (s *IngestServer) protocolIngestHandler(decoder ingest.ProtocolDecoder, onSuccess func(http.ResponseWriter)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			s.writeErr(w, r, httperr.MethodNotAllowed())
+			return
+		}
+		defer r.Body.Close()
+
+		accepted := 0
+		err := decoder.Decode(r.Body, func(ev engine.LogEvent) error {
+			s.queryEngine.Ingest(ev.Timestamp, ev.Level, ev.Service, ev.Host, ev.Message)
+			accepted++
+			return nil
+		})
+		s.queryEngine.SyncWAL()
+
+		var batchErr *ingest.BatchError
+		switch {
+		case err == nil:
+			onSuccess(w)
+		case errors.As(err, &batchErr):
+			w.Header().Set("Content-Type", "application/json")
+			resp := bulkResponse{Errors: true, Accepted: batchErr.Accepted}
+			for _, f := range batchErr.Failed {
+				resp.Failed = append(resp.Failed, bulkResponseItem{Index: f.Index, Error: f.Err.Error()})
+			}
+			json.NewEncoder(w).Encode(resp)
+		default:
+			log.Printf("protocol ingest error: %v", err)
+			s.writeErr(w, r, httperr.New(http.StatusBadRequest, httperr.CodeIngestInvalidJSON, fmt.Sprintf("decode error: %v", err)))
+		}
+	}
+}
+
+// writeBulkSuccess responds the way an Elasticsearch bulk loader expects
+// when every record in the batch decoded cleanly.
+func writeBulkSuccess(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(bulkResponse{Errors: false})
+}
+
+// writeNoContent responds the way Loki's push API expects on success.
+func writeNoContent(w http.ResponseWriter) {
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeOTLPSuccess responds with an empty ExportLogsServiceResponse, the
+// way the OTLP collector logs.v1 Export RPC does on success.
+func writeOTLPSuccess(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{}`))
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/coffersTech/nanolog/server/internal/httperr"
+)
+
+type ctxKeyRequestID struct{}
+
+// withRequestID stamps every request with an X-Request-ID (reusing one
+// already set by an upstream proxy, if present) and logs a structured
+// summary once the handler returns, so an operator can correlate a
+// failing ingest or query with the exact log line that served it.
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if id == "" {
+			id = newRequestID()
+		}
+		w.Header().Set("X-Request-ID", id)
+		r = r.WithContext(context.WithValue(r.Context(), ctxKeyRequestID{}, id))
+
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		log.Printf("request_id=%s method=%s path=%s status=%d duration=%s",
+			id, r.Method, r.URL.Path, rec.status, time.Since(start))
+	})
+}
+
+// newRequestID generates a short random ID, the same way createSession
+// mints session tokens.
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func requestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(ctxKeyRequestID{}).(string)
+	return id
+}
+
+// statusRecorder captures the status code WriteHeader was called with, so
+// withRequestID can log it after the handler returns — http.ResponseWriter
+// itself exposes no way to read the status back afterward.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// writeErr writes e as the response body, stamping it with r's request ID
+// so the error body and the request's log line above share one
+// correlation ID.
+func (s *IngestServer) writeErr(w http.ResponseWriter, r *http.Request, e *httperr.Error) {
+	e.WithRequestID(requestIDFromContext(r.Context())).WriteTo(w)
+}
@@ -0,0 +1,163 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/coffersTech/nanolog/server/internal/cluster"
+	"github.com/coffersTech/nanolog/server/internal/engine"
+	"github.com/coffersTech/nanolog/server/internal/httperr"
+)
+
+// maxTailStreamsPerToken bounds how many concurrent /api/tail connections
+// one token may hold open, so a runaway or malicious client can't exhaust
+// server goroutines/memory by opening unbounded live-tail streams.
+const maxTailStreamsPerToken = 4
+
+// acquireTailSlot reserves one concurrent /api/tail stream for token,
+// reporting false if it's already at maxTailStreamsPerToken. An empty
+// token (shouldn't happen past AuthMiddleware) is never limited.
+func (s *IngestServer) acquireTailSlot(token string) bool {
+	if token == "" {
+		return true
+	}
+
+	s.tailSlotsMu.Lock()
+	defer s.tailSlotsMu.Unlock()
+	if s.tailSlots[token] >= maxTailStreamsPerToken {
+		return false
+	}
+	s.tailSlots[token]++
+	return true
+}
+
+// releaseTailSlot returns the slot acquireTailSlot reserved for token.
+func (s *IngestServer) releaseTailSlot(token string) {
+	if token == "" {
+		return
+	}
+
+	s.tailSlotsMu.Lock()
+	defer s.tailSlotsMu.Unlock()
+	s.tailSlots[token]--
+	if s.tailSlots[token] <= 0 {
+		delete(s.tailSlots, token)
+	}
+}
+
+// handleTail serves GET /api/tail, upgrading to text/event-stream and
+// pushing matching rows as they're ingested instead of requiring the
+// client to poll /api/search. In console role it fans out to every live
+// ingester via Aggregator.Tail; otherwise it subscribes directly to the
+// local QueryEngine. A reconnecting client sends back the last row's
+// Last-Event-ID so it can backfill whatever it missed instead of losing
+// it.
+func (s *IngestServer) handleTail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.writeErr(w, r, httperr.MethodNotAllowed())
+		return
+	}
+
+	var filter engine.Filter
+	if s.role != "console" {
+		var err error
+		filter, err = s.parseFilter(r)
+		if err != nil {
+			s.writeErr(w, r, httperr.New(http.StatusBadRequest, httperr.CodeQueryBadFilter, err.Error()))
+			return
+		}
+	}
+
+	token := bearerToken(r)
+	if !s.acquireTailSlot(token) {
+		s.writeErr(w, r, httperr.New(http.StatusTooManyRequests, httperr.CodeTooManyStreams, "too many concurrent tail streams for this token"))
+		return
+	}
+	defer s.releaseTailSlot(token)
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		s.writeErr(w, r, httperr.New(http.StatusInternalServerError, httperr.CodeInternal, "streaming not supported"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+
+	var rows <-chan engine.LogRow
+	var cancel func()
+
+	if s.role == "console" {
+		params := cluster.QueryParams{
+			RawQuery:    r.URL.RawQuery,
+			Auth:        r.Header.Get("Authorization"),
+			LastEventID: lastEventID,
+		}
+		rows, cancel = s.aggregator.Tail(r.Context(), params)
+	} else {
+		if lastEventID != "" {
+			s.backfillTail(w, flusher, filter, lastEventID)
+		}
+		rows, cancel = s.queryEngine.Subscribe(filter)
+	}
+	defer cancel()
+
+	for {
+		select {
+		case row, ok := <-rows:
+			if !ok {
+				return
+			}
+			writeTailRow(w, row)
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// backfillTail replays everything matching filter since lastEventID (a
+// row Timestamp, the id: value this node hands out) before handleTail
+// hands the connection off to a live Subscribe, so a reconnecting client
+// doesn't lose rows ingested while it was disconnected.
+func (s *IngestServer) backfillTail(w http.ResponseWriter, flusher http.Flusher, filter engine.Filter, lastEventID string) {
+	since, err := strconv.ParseInt(lastEventID, 10, 64)
+	if err != nil {
+		return
+	}
+
+	backfill := filter
+	backfill.MinTime = since + 1
+
+	rows, err := s.queryEngine.ExecuteScan(backfill, 10000)
+	if err != nil {
+		return
+	}
+
+	// ExecuteScan returns MemTable rows newest-first followed by each
+	// file's rows in file order; replay oldest-first like a live stream
+	// would have delivered them.
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].Timestamp < rows[j].Timestamp })
+	for _, row := range rows {
+		writeTailRow(w, row)
+	}
+	flusher.Flush()
+}
+
+// writeTailRow writes row as one SSE event, using its timestamp as the
+// event ID so Last-Event-ID resumption has something to backfill from.
+func writeTailRow(w http.ResponseWriter, row engine.LogRow) {
+	data, err := json.Marshal(row)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "id: %d\ndata: %s\n\n", row.Timestamp, data)
+}
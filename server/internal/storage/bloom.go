@@ -0,0 +1,145 @@
+package storage
+
+import (
+	"hash/fnv"
+	"sort"
+)
+
+// bloomHashCount and bloomBitsPerEntry size every Bloom filter written into
+// a v3 footer: 10 bits/entry at k=7 keeps the false-positive rate under 1%,
+// the standard tradeoff for that hash count.
+const (
+	bloomHashCount    = 7
+	bloomBitsPerEntry = 10
+)
+
+// bloomFilter is a fixed-size Bloom filter over arbitrary byte keys. Two
+// independent 64-bit FNV hashes (h1, h2) are combined via Kirsch-Mitzenmacher
+// double hashing - index_i = (h1 + i*h2) mod m - to derive bloomHashCount
+// indices without running that many distinct hash functions.
+type bloomFilter struct {
+	bits []byte
+	m    uint64 // number of bits
+}
+
+// newBloomFilter sizes a filter for n expected entries. n == 0 (an empty
+// column) yields a filter that reports every key as a possible match,
+// since there's nothing to rule out.
+func newBloomFilter(n int) *bloomFilter {
+	if n <= 0 {
+		return &bloomFilter{}
+	}
+	m := uint64(n) * bloomBitsPerEntry
+	return &bloomFilter{bits: make([]byte, (m+7)/8), m: m}
+}
+
+// bloomFromBytes reconstructs a filter from its on-disk bit array.
+func bloomFromBytes(data []byte) *bloomFilter {
+	return &bloomFilter{bits: data, m: uint64(len(data)) * 8}
+}
+
+func bloomHashes(key []byte) (uint64, uint64) {
+	h1 := fnv.New64()
+	h1.Write(key)
+	h2 := fnv.New64a()
+	h2.Write(key)
+	return h1.Sum64(), h2.Sum64()
+}
+
+func (b *bloomFilter) add(key []byte) {
+	if b.m == 0 {
+		return
+	}
+	h1, h2 := bloomHashes(key)
+	for i := uint64(0); i < bloomHashCount; i++ {
+		idx := (h1 + i*h2) % b.m
+		b.bits[idx/8] |= 1 << (idx % 8)
+	}
+}
+
+// mightContain reports whether key was possibly added. A false return is
+// certain; a true return may be a false positive.
+func (b *bloomFilter) mightContain(key []byte) bool {
+	if b.m == 0 {
+		return true
+	}
+	h1, h2 := bloomHashes(key)
+	for i := uint64(0); i < bloomHashCount; i++ {
+		idx := (h1 + i*h2) % b.m
+		if b.bits[idx/8]&(1<<(idx%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// trigrams returns every overlapping 3-byte window of s, case-sensitive and
+// unsplit on word boundaries, so that any substring match reported by
+// strings.Contains(msg, s) is guaranteed to have every one of its windows
+// already present among the windows added from msg. Strings under 3 bytes
+// have no windows and return nil, signaling "can't rule out" to callers.
+func trigrams(s string) [][]byte {
+	if len(s) < 3 {
+		return nil
+	}
+	grams := make([][]byte, 0, len(s)-2)
+	for i := 0; i+3 <= len(s); i++ {
+		grams = append(grams, []byte(s[i:i+3]))
+	}
+	return grams
+}
+
+// mightContainQuery reports whether bloom (built from a MsgCol's trigrams)
+// could contain q as a substring of some indexed message. Queries under 3
+// bytes can't be checked this way and always report true.
+func mightContainQuery(bloom *bloomFilter, q string) bool {
+	grams := trigrams(q)
+	if grams == nil {
+		return true
+	}
+	for _, g := range grams {
+		if !bloom.mightContain(g) {
+			return false
+		}
+	}
+	return true
+}
+
+// topServicesDictSize caps the service dictionary written into a v3 footer.
+const topServicesDictSize = 16
+
+// topServices returns up to topServicesDictSize of the most frequent values
+// in svc, most frequent first (ties broken lexically for determinism). It's
+// exposed in the footer as auxiliary metadata for low-cardinality files; it
+// isn't itself used for pruning since a file with more distinct services
+// than topServicesDictSize lists only the most frequent of them.
+func topServices(svc []string) []string {
+	counts := make(map[string]int, len(svc))
+	for _, s := range svc {
+		counts[s]++
+	}
+
+	type kv struct {
+		svc   string
+		count int
+	}
+	kvs := make([]kv, 0, len(counts))
+	for s, c := range counts {
+		kvs = append(kvs, kv{s, c})
+	}
+	sort.Slice(kvs, func(i, j int) bool {
+		if kvs[i].count != kvs[j].count {
+			return kvs[i].count > kvs[j].count
+		}
+		return kvs[i].svc < kvs[j].svc
+	})
+	if len(kvs) > topServicesDictSize {
+		kvs = kvs[:topServicesDictSize]
+	}
+
+	out := make([]string, len(kvs))
+	for i, e := range kvs {
+		out[i] = e.svc
+	}
+	return out
+}
@@ -0,0 +1,152 @@
+package storage
+
+import (
+	"io"
+	"strings"
+
+	"github.com/coffersTech/nanolog/server/internal/engine"
+	"github.com/coffersTech/nanolog/server/internal/engine/cache"
+)
+
+// ReadColumns reads the column blocks needed to satisfy cols and filter
+// from rs, in on-disk order (timestamp, level, service, message): the
+// timestamp and level blocks are always read, the service block whenever
+// it or the message block is needed, and the message column is never
+// decompressed unless ColMsg is requested or filter.Query is set. rs is typically obtained from a Storage
+// backend's Open method; ReadColumns closes it before returning.
+//
+// The .nano format does not persist a host column (see
+// ColumnWriter.WriteSnapshot), so ColumnBatch.Hosts is always left nil here
+// regardless of cols.
+func (cr *ColumnReader) ReadColumns(rs io.ReadSeekCloser, cols engine.ColumnSet, filter engine.Filter, fileID uint64, bc *cache.BlockCache) (engine.ColumnBatch, error) {
+	defer rs.Close()
+
+	fh, err := readFileHeader(rs)
+	if err != nil {
+		return engine.ColumnBatch{}, err
+	}
+	if fh.rowCount == 0 {
+		return engine.ColumnBatch{}, nil
+	}
+	if filter.MinTime > 0 && fh.maxTs < filter.MinTime {
+		return engine.ColumnBatch{}, nil
+	}
+	if filter.MaxTime > 0 && fh.minTs > filter.MaxTime {
+		return engine.ColumnBatch{}, nil
+	}
+	if fh.version >= 3 {
+		if filter.Service != "" && fh.bloomSvc != nil && !fh.bloomSvc.mightContain([]byte(filter.Service)) {
+			return engine.ColumnBatch{}, nil
+		}
+		if filter.Query != "" && fh.bloomMsg != nil && !mightContainQuery(fh.bloomMsg, filter.Query) {
+			return engine.ColumnBatch{}, nil
+		}
+	}
+
+	needMsg := cols.Has(engine.ColMsg) || filter.Query != ""
+	// Blocks are read sequentially from rs's position, so the message
+	// block can only be reached by reading the service block first.
+	needSvc := cols.Has(engine.ColSvc) || filter.Service != "" || needMsg
+
+	tsData, err := readCachedColumn(cr, rs, fileID, bc, blockTimestamps)
+	if err != nil {
+		return engine.ColumnBatch{}, err
+	}
+	lvlData, err := readCachedColumn(cr, rs, fileID, bc, blockLevels)
+	if err != nil {
+		return engine.ColumnBatch{}, err
+	}
+
+	var timestamps []int64
+	var levels []uint8
+	if fh.version == 1 {
+		timestamps = bytesToInt64Slice(tsData)
+		levels = lvlData
+	} else {
+		timestamps = decodeTsColV2(tsData, fh.rowCount)
+		levels = decodeLvlColV2(lvlData, fh.rowCount)
+	}
+
+	var services stringCol
+	var svcFilterCode int
+	var svcFilterIsDict bool
+	if needSvc {
+		svcData, err := readCachedColumn(cr, rs, fileID, bc, blockServices)
+		if err != nil {
+			return engine.ColumnBatch{}, err
+		}
+		services = decodeStringColTyped(svcData, fh.version)
+		if filter.Service != "" && services.raw == nil {
+			code, found := services.codeOf(filter.Service)
+			if !found {
+				return engine.ColumnBatch{}, nil
+			}
+			svcFilterCode = code
+			svcFilterIsDict = true
+		}
+	}
+
+	var messages []string
+	if needMsg {
+		// The message column is the last block before the footer, so when
+		// it isn't needed we simply never read it.
+		msgData, err := readCachedColumn(cr, rs, fileID, bc, blockMessages)
+		if err != nil {
+			return engine.ColumnBatch{}, err
+		}
+		messages = decodeStringColTyped(msgData, fh.version).toSlice()
+	}
+
+	var batch engine.ColumnBatch
+	for i := 0; i < fh.rowCount; i++ {
+		ts := timestamps[i]
+		if filter.MinTime > 0 && ts < filter.MinTime {
+			continue
+		}
+		if filter.MaxTime > 0 && ts > filter.MaxTime {
+			continue
+		}
+
+		lvl := levels[i]
+		if filter.Level > 0 && lvl != filter.Level {
+			continue
+		}
+
+		var svc string
+		if needSvc {
+			if svcFilterIsDict {
+				if services.codes[i] != svcFilterCode {
+					continue
+				}
+			}
+			svc = services.at(i)
+			if !svcFilterIsDict && filter.Service != "" && svc != filter.Service {
+				continue
+			}
+		}
+
+		var msg string
+		if messages != nil {
+			msg = messages[i]
+			if filter.Query != "" && !strings.Contains(msg, filter.Query) {
+				continue
+			}
+		}
+
+		if cols.Has(engine.ColTs) {
+			batch.Timestamps = append(batch.Timestamps, ts)
+		}
+		if cols.Has(engine.ColLvl) {
+			batch.Levels = append(batch.Levels, lvl)
+		}
+		if cols.Has(engine.ColSvc) {
+			batch.Services = append(batch.Services, svc)
+		}
+		if cols.Has(engine.ColMsg) {
+			batch.Messages = append(batch.Messages, msg)
+		}
+		batch.RowCount++
+	}
+
+	return batch, nil
+}
@@ -5,13 +5,22 @@ import (
 	"encoding/binary"
 	"errors"
 	"io"
-	"os"
 	"strings"
 
 	"github.com/coffersTech/nanolog/server/internal/engine"
+	"github.com/coffersTech/nanolog/server/internal/engine/cache"
 	"github.com/klauspost/compress/zstd"
 )
 
+// Block IDs for the fixed column layout within a .nano file, used as cache
+// keys alongside the file's ID.
+const (
+	blockTimestamps uint64 = iota
+	blockLevels
+	blockServices
+	blockMessages
+)
+
 var ErrInvalidHeader = errors.New("invalid .nano file header")
 
 // LogIterator provides a row-by-row view of logs.
@@ -24,6 +33,7 @@ type LogIterator interface {
 
 type ColumnReader struct {
 	decoder *zstd.Decoder
+	bufPool *cache.BufferPool
 }
 
 func NewColumnReader() (*ColumnReader, error) {
@@ -31,24 +41,25 @@ func NewColumnReader() (*ColumnReader, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &ColumnReader{decoder: dec}, nil
+	return &ColumnReader{decoder: dec, bufPool: cache.NewBufferPool()}, nil
 }
 
-// NewIterator creates a new iterator for a .nano file with filtering.
-func (cr *ColumnReader) NewIterator(filename string, filter engine.Filter) (LogIterator, error) {
-	f, err := os.Open(filename)
-	if err != nil {
-		return nil, err
-	}
-
+// NewIterator creates a new iterator over rs, a handle typically obtained
+// from a Storage backend's Open method. fileID identifies rs for block-cache
+// lookups (callers typically derive it from the originating FileDesc); bc
+// may be nil to read without caching. The iterator takes ownership of rs and
+// closes it when Close is called.
+func (cr *ColumnReader) NewIterator(rs io.ReadSeekCloser, filter engine.Filter, fileID uint64, bc *cache.BlockCache) (LogIterator, error) {
 	it := &FileIterator{
 		reader: cr,
-		file:   f,
+		rs:     rs,
 		filter: filter,
+		fileID: fileID,
+		bc:     bc,
 	}
 
 	if err := it.init(); err != nil {
-		f.Close()
+		rs.Close()
 		return nil, err
 	}
 
@@ -57,102 +68,453 @@ func (cr *ColumnReader) NewIterator(filename string, filter engine.Filter) (LogI
 
 type FileIterator struct {
 	reader *ColumnReader
-	file   *os.File
+	rs     io.ReadSeekCloser
 	filter engine.Filter
+	fileID uint64
+	bc     *cache.BlockCache
 
 	// Columns data
 	timestamps []int64
 	levels     []uint8
-	services   []string
+	services   stringCol
 	messages   []string
 
+	// svcFilterCode/svcFilterIsDict let Next compare Filter.Service
+	// against services as an integer code instead of a string on every
+	// row, when services is dictionary-coded (see stringCol).
+	svcFilterCode   int
+	svcFilterIsDict bool
+
 	rowCount int
 	cursor   int
 	currRow  engine.LogRow
 	err      error
 }
 
-func (it *FileIterator) init() error {
-	// 1. Validate Header
+// fileHeader holds the parsed .nano header/footer, positioned so the
+// columns can be read sequentially starting right after it's returned.
+// bloomSvc, bloomMsg, and dict are only populated for v3+ files; the
+// .nano format has never persisted a host column (see ReadColumns), so
+// there is no bloomHost.
+type fileHeader struct {
+	version      int
+	rowCount     int
+	minTs, maxTs int64
+	bloomSvc     *bloomFilter
+	bloomMsg     *bloomFilter
+	dict         []string
+}
+
+// readFileHeader validates the magic header, reads the footer from the end
+// of rs, and leaves rs positioned right after the header, ready for
+// sequential column reads. It recognizes the v1 (raw columns), v2
+// (delta-of-delta/RLE columns), v3 (v2 columns plus a Bloom-filter
+// footer), and v4 (v3 footer, plus a per-column string encoding marker
+// enabling dictionary-coded columns) magic values, recording which was
+// found in fileHeader.version so callers can pick the matching column
+// decode.
+func readFileHeader(rs io.ReadSeekCloser) (fileHeader, error) {
 	header := make([]byte, 8)
-	if _, err := io.ReadFull(it.file, header); err != nil {
-		return err
+	if _, err := io.ReadFull(rs, header); err != nil {
+		return fileHeader{}, err
 	}
-	if !bytes.Equal(header, MagicHeader) {
-		return ErrInvalidHeader
+	var version int
+	switch {
+	case bytes.Equal(header, MagicHeader):
+		version = 1
+	case bytes.Equal(header, MagicHeaderV2):
+		version = 2
+	case bytes.Equal(header, MagicHeaderV3):
+		version = 3
+	case bytes.Equal(header, MagicHeaderV4):
+		version = 4
+	default:
+		return fileHeader{}, ErrInvalidHeader
 	}
 
-	// 2. Read Footer (at end of file)
-	// Footer: RowCount(4) + MinTs(8) + MaxTs(8) = 20 bytes
-	info, err := it.file.Stat()
+	size, err := rs.Seek(0, io.SeekEnd)
 	if err != nil {
-		return err
+		return fileHeader{}, err
+	}
+
+	var fh fileHeader
+	if version >= 3 {
+		fh, err = readFooterV3(rs, size)
+		fh.version = version
+	} else {
+		fh, err = readFooterLegacy(rs, size, version)
+	}
+	if err != nil {
+		return fileHeader{}, err
 	}
-	if info.Size() < 28 { // Header(8) + Footer(20)
-		return errors.New("file too small")
+
+	if _, err := rs.Seek(int64(len(header)), io.SeekStart); err != nil {
+		return fileHeader{}, err
 	}
+	return fh, nil
+}
 
+// readFooterLegacy reads the fixed 20-byte v1/v2 footer (RowCount, MinTs,
+// MaxTs) from the end of rs.
+func readFooterLegacy(rs io.ReadSeeker, size int64, version int) (fileHeader, error) {
+	if size < 28 { // Header(8) + Footer(20)
+		return fileHeader{}, errors.New("file too small")
+	}
+	if _, err := rs.Seek(-20, io.SeekEnd); err != nil {
+		return fileHeader{}, err
+	}
 	footer := make([]byte, 20)
-	if _, err := it.file.ReadAt(footer, info.Size()-20); err != nil {
-		return err
+	if _, err := io.ReadFull(rs, footer); err != nil {
+		return fileHeader{}, err
+	}
+	return fileHeader{
+		version:  version,
+		rowCount: int(binary.LittleEndian.Uint32(footer[0:4])),
+		minTs:    int64(binary.LittleEndian.Uint64(footer[4:12])),
+		maxTs:    int64(binary.LittleEndian.Uint64(footer[12:20])),
+	}, nil
+}
+
+// readFooterV3 reads the v3 footer. A trailing uint32 at EOF gives the
+// footer's own byte length, which locates its start without scanning
+// forward through the (possibly large) column blocks first.
+func readFooterV3(rs io.ReadSeeker, size int64) (fileHeader, error) {
+	if size < 12 { // Header(8) + trailing length(4), at minimum
+		return fileHeader{}, errors.New("file too small")
+	}
+	if _, err := rs.Seek(-4, io.SeekEnd); err != nil {
+		return fileHeader{}, err
+	}
+	var footerLen uint32
+	if err := binary.Read(rs, binary.LittleEndian, &footerLen); err != nil {
+		return fileHeader{}, err
+	}
+	if int64(footerLen)+12 > size {
+		return fileHeader{}, errors.New("invalid v3 footer length")
+	}
+
+	if _, err := rs.Seek(-4-int64(footerLen), io.SeekEnd); err != nil {
+		return fileHeader{}, err
+	}
+	footer := make([]byte, footerLen)
+	if _, err := io.ReadFull(rs, footer); err != nil {
+		return fileHeader{}, err
+	}
+
+	pos := 0
+	readBlob := func() ([]byte, error) {
+		if pos+4 > len(footer) {
+			return nil, errors.New("truncated v3 footer")
+		}
+		n := int(binary.LittleEndian.Uint32(footer[pos : pos+4]))
+		pos += 4
+		if n < 0 || pos+n > len(footer) {
+			return nil, errors.New("truncated v3 footer")
+		}
+		b := footer[pos : pos+n]
+		pos += n
+		return b, nil
 	}
 
-	rowCount := binary.LittleEndian.Uint32(footer[0:4])
-	minTs := int64(binary.LittleEndian.Uint64(footer[4:12]))
-	maxTs := int64(binary.LittleEndian.Uint64(footer[12:20]))
+	bloomSvcBytes, err := readBlob()
+	if err != nil {
+		return fileHeader{}, err
+	}
+	bloomMsgBytes, err := readBlob()
+	if err != nil {
+		return fileHeader{}, err
+	}
+	dictBytes, err := readBlob()
+	if err != nil {
+		return fileHeader{}, err
+	}
+
+	if pos+20 > len(footer) {
+		return fileHeader{}, errors.New("truncated v3 footer")
+	}
+	rowCount := int(binary.LittleEndian.Uint32(footer[pos : pos+4]))
+	minTs := int64(binary.LittleEndian.Uint64(footer[pos+4 : pos+12]))
+	maxTs := int64(binary.LittleEndian.Uint64(footer[pos+12 : pos+20]))
+
+	return fileHeader{
+		version:  3,
+		rowCount: rowCount,
+		minTs:    minTs,
+		maxTs:    maxTs,
+		bloomSvc: bloomFromBytes(bloomSvcBytes),
+		bloomMsg: bloomFromBytes(bloomMsgBytes),
+		dict:     decodeServiceDict(dictBytes),
+	}, nil
+}
+
+// decodeServiceDict reverses writeFooterV3's dict encoding: [count
+// uint32][len uint32][bytes]... repeated count times.
+func decodeServiceDict(data []byte) []string {
+	if len(data) < 4 {
+		return nil
+	}
+	count := int(binary.LittleEndian.Uint32(data[0:4]))
+	pos := 4
+	dict := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		if pos+4 > len(data) {
+			break
+		}
+		n := int(binary.LittleEndian.Uint32(data[pos : pos+4]))
+		pos += 4
+		if n < 0 || pos+n > len(data) {
+			break
+		}
+		dict = append(dict, string(data[pos:pos+n]))
+		pos += n
+	}
+	return dict
+}
+
+// stringCol is a decoded string column, either a plain slice (v1-v3
+// columns, and any v4+ column written raw) or a dictionary plus one
+// integer code per row (v4+ dictionary-coded columns like Service). at
+// and toSlice hide the difference for callers that just want values;
+// codeOf lets a caller that wants to filter by an exact value compare
+// codes as integers instead of decoding every row to a string, checking
+// raw == nil first to know whether that fast path is available at all.
+type stringCol struct {
+	raw   []string
+	dict  []string
+	codes []int
+}
+
+func (sc stringCol) len() int {
+	if sc.raw != nil {
+		return len(sc.raw)
+	}
+	return len(sc.codes)
+}
+
+func (sc stringCol) at(i int) string {
+	if sc.raw != nil {
+		return sc.raw[i]
+	}
+	return sc.dict[sc.codes[i]]
+}
 
-	it.rowCount = int(rowCount)
+// toSlice materializes every value, for callers (e.g. the message
+// column, or a cols.Has(engine.ColSvc) projection) that need the full
+// []string regardless of how the column was encoded on disk.
+func (sc stringCol) toSlice() []string {
+	if sc.raw != nil {
+		return sc.raw
+	}
+	out := make([]string, len(sc.codes))
+	for i, c := range sc.codes {
+		out[i] = sc.dict[c]
+	}
+	return out
+}
+
+// codeOf reports value's index in sc's dictionary. ok is false both when
+// sc is raw-encoded (sc.raw != nil, no dictionary to look up) and when
+// value isn't present in the dictionary at all - in the latter case, no
+// row in this column can possibly equal value, a distinction the caller
+// can use for a stronger skip than "fall back to a per-row compare".
+func (sc stringCol) codeOf(value string) (code int, ok bool) {
+	if sc.raw != nil {
+		return 0, false
+	}
+	for i, d := range sc.dict {
+		if d == value {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// decodeStringColTyped decodes a string column's decompressed bytes,
+// dispatching on the per-column encoding marker byte v4+ files prepend
+// to every string column (see writeRawStringCol/writeDictStringCol).
+// v1-v3 columns carry no marker and are always raw.
+func decodeStringColTyped(data []byte, version int) stringCol {
+	if version < 4 {
+		return stringCol{raw: bytesToStringSlice(data)}
+	}
+	if len(data) == 0 {
+		return stringCol{}
+	}
+	marker, body := data[0], data[1:]
+	if marker == stringColDict {
+		return decodeDictStringCol(body)
+	}
+	return stringCol{raw: bytesToStringSlice(body)}
+}
+
+// decodeDictStringCol reverses writeDictStringCol's body (marker byte
+// already stripped by the caller): [dictCount uint32][len uint32]
+// [bytes]... repeated dictCount times, then [codeWidth byte][code]...
+// one code (2 or 4 bytes, LittleEndian) per row.
+func decodeDictStringCol(data []byte) stringCol {
+	if len(data) < 4 {
+		return stringCol{}
+	}
+	dictCount := int(binary.LittleEndian.Uint32(data[0:4]))
+	pos := 4
+	dict := make([]string, 0, dictCount)
+	for i := 0; i < dictCount; i++ {
+		if pos+4 > len(data) {
+			return stringCol{dict: dict}
+		}
+		n := int(binary.LittleEndian.Uint32(data[pos : pos+4]))
+		pos += 4
+		if n < 0 || pos+n > len(data) {
+			return stringCol{dict: dict}
+		}
+		dict = append(dict, string(data[pos:pos+n]))
+		pos += n
+	}
+	if pos >= len(data) {
+		return stringCol{dict: dict}
+	}
+	codeWidth := data[pos]
+	pos++
+
+	var codes []int
+	for pos < len(data) {
+		switch codeWidth {
+		case 2:
+			if pos+2 > len(data) {
+				return stringCol{dict: dict, codes: codes}
+			}
+			codes = append(codes, int(binary.LittleEndian.Uint16(data[pos:pos+2])))
+			pos += 2
+		default:
+			if pos+4 > len(data) {
+				return stringCol{dict: dict, codes: codes}
+			}
+			codes = append(codes, int(binary.LittleEndian.Uint32(data[pos:pos+4])))
+			pos += 4
+		}
+	}
+	return stringCol{dict: dict, codes: codes}
+}
+
+func (it *FileIterator) init() error {
+	fh, err := readFileHeader(it.rs)
+	if err != nil {
+		return err
+	}
+
+	it.rowCount = fh.rowCount
 	it.cursor = -1
 
 	// File-level filtering based on MinTs/MaxTs
-	if rowCount > 0 {
-		if it.filter.MinTime > 0 && maxTs < it.filter.MinTime {
+	if fh.rowCount > 0 {
+		if it.filter.MinTime > 0 && fh.maxTs < it.filter.MinTime {
 			it.rowCount = 0 // Skip entire file
 			return nil
 		}
-		if it.filter.MaxTime > 0 && minTs > it.filter.MaxTime {
+		if it.filter.MaxTime > 0 && fh.minTs > it.filter.MaxTime {
 			it.rowCount = 0 // Skip entire file
 			return nil
 		}
+
+		// v3+ files carry Bloom filters over the service and message
+		// columns, letting a filter that can't possibly match rule out
+		// the whole file before any column block is decompressed.
+		if fh.version >= 3 {
+			if it.filter.Service != "" && fh.bloomSvc != nil && !fh.bloomSvc.mightContain([]byte(it.filter.Service)) {
+				it.rowCount = 0
+				return nil
+			}
+			if it.filter.Query != "" && fh.bloomMsg != nil && !mightContainQuery(fh.bloomMsg, it.filter.Query) {
+				it.rowCount = 0
+				return nil
+			}
+		}
 	}
 
-	// 3. Read and decompress all columns (in-memory for now per block)
-	// Note: True streaming would decompress on demand, but .nano v1 stores
+	// Read/decompress all columns (in-memory for now per block).
+	// Note: True streaming would decompress on demand, but .nano stores
 	// whole columns as single compressed blocks.
 	// We still benefit from row-by-row processing at the engine level.
-
-	tsData, err := it.reader.readAndDecompress(it.file)
+	tsData, err := it.readColumn(blockTimestamps)
 	if err != nil {
 		return err
 	}
-	it.timestamps = bytesToInt64Slice(tsData)
-
-	lvlData, err := it.reader.readAndDecompress(it.file)
+	lvlData, err := it.readColumn(blockLevels)
 	if err != nil {
 		return err
 	}
-	it.levels = lvlData
+	if fh.version == 1 {
+		it.timestamps = bytesToInt64Slice(tsData)
+		it.levels = lvlData
+	} else {
+		it.timestamps = decodeTsColV2(tsData, fh.rowCount)
+		it.levels = decodeLvlColV2(lvlData, fh.rowCount)
+	}
 
-	svcData, err := it.reader.readAndDecompress(it.file)
+	svcData, err := it.readColumn(blockServices)
 	if err != nil {
 		return err
 	}
-	it.services = bytesToStringSlice(svcData)
+	it.services = decodeStringColTyped(svcData, fh.version)
+	if it.filter.Service != "" && it.services.raw == nil {
+		code, found := it.services.codeOf(it.filter.Service)
+		if !found {
+			// Dictionary-coded and the filter value isn't in it: no row
+			// in this file can match, so skip the message column too.
+			it.rowCount = 0
+			return nil
+		}
+		it.svcFilterCode = code
+		it.svcFilterIsDict = true
+	}
 
-	msgData, err := it.reader.readAndDecompress(it.file)
+	msgData, err := it.readColumn(blockMessages)
 	if err != nil {
 		return err
 	}
-	it.messages = bytesToStringSlice(msgData)
+	it.messages = decodeStringColTyped(msgData, fh.version).toSlice()
 
 	// Basic column length validation
-	if it.rowCount != len(it.levels) || it.rowCount != len(it.services) || it.rowCount != len(it.messages) {
+	if it.rowCount != len(it.levels) || it.rowCount != it.services.len() || it.rowCount != len(it.messages) {
 		return errors.New("column length mismatch")
 	}
 
 	return nil
 }
 
+// readColumn reads the block at the iterator's current stream position,
+// identified by blockID for cache purposes.
+func (it *FileIterator) readColumn(blockID uint64) ([]byte, error) {
+	return readCachedColumn(it.reader, it.rs, it.fileID, it.bc, blockID)
+}
+
+// readCachedColumn reads the block at blockID starting at rs's current
+// position, consulting bc when non-nil so a later read of the same
+// (fileID, blockID) skips decompression and the underlying I/O entirely. On
+// a cache hit it still advances rs past the block via Seek, since the
+// caller expects to land on the next block afterwards.
+func readCachedColumn(cr *ColumnReader, rs io.ReadSeekCloser, fileID uint64, bc *cache.BlockCache, blockID uint64) ([]byte, error) {
+	load := func() ([]byte, int64, error) {
+		return cr.readAndDecompress(rs)
+	}
+
+	if bc == nil {
+		data, _, err := load()
+		return data, err
+	}
+
+	data, hit, skip, err := bc.Get(fileID, blockID, load)
+	if err != nil {
+		return nil, err
+	}
+	if hit {
+		if _, err := rs.Seek(skip, io.SeekCurrent); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
 func (it *FileIterator) Next() bool {
 	for {
 		it.cursor++
@@ -174,9 +536,14 @@ func (it *FileIterator) Next() bool {
 			continue
 		}
 
-		svc := it.services[it.cursor]
-		if it.filter.Service != "" && svc != it.filter.Service {
-			continue
+		if it.filter.Service != "" {
+			if it.svcFilterIsDict {
+				if it.services.codes[it.cursor] != it.svcFilterCode {
+					continue
+				}
+			} else if it.services.at(it.cursor) != it.filter.Service {
+				continue
+			}
 		}
 
 		msg := it.messages[it.cursor]
@@ -188,7 +555,7 @@ func (it *FileIterator) Next() bool {
 		it.currRow = engine.LogRow{
 			Timestamp: ts,
 			Level:     lvl,
-			Service:   svc,
+			Service:   it.services.at(it.cursor),
 			Message:   msg,
 		}
 		return true
@@ -204,12 +571,15 @@ func (it *FileIterator) Error() error {
 }
 
 func (it *FileIterator) Close() error {
-	return it.file.Close()
+	return it.rs.Close()
 }
 
-// ReadSnapshot reads a .nano file and returns log rows matching the filter.
-func (cr *ColumnReader) ReadSnapshot(filename string, filter engine.Filter) ([]engine.LogRow, error) {
-	it, err := cr.NewIterator(filename, filter)
+// ReadSnapshot reads a .nano snapshot from rs and returns log rows matching
+// the filter. rs is typically obtained from a Storage backend's Open
+// method; ReadSnapshot closes it before returning. fileID identifies rs for
+// block-cache lookups; bc may be nil to read without caching.
+func (cr *ColumnReader) ReadSnapshot(rs io.ReadSeekCloser, filter engine.Filter, fileID uint64, bc *cache.BlockCache) ([]engine.LogRow, error) {
+	it, err := cr.NewIterator(rs, filter, fileID, bc)
 	if err != nil {
 		return nil, err
 	}
@@ -222,30 +592,40 @@ func (cr *ColumnReader) ReadSnapshot(filename string, filter engine.Filter) ([]e
 	return rows, it.Error()
 }
 
-// readAndDecompress reads a compressed block (size + data) and decompresses it.
-func (cr *ColumnReader) readAndDecompress(r io.Reader) ([]byte, error) {
+// readAndDecompress reads a compressed block (size + data), decompresses
+// it, and returns the decompressed bytes along with the number of bytes
+// consumed from r (4-byte size prefix + compressed payload), so a caller
+// satisfying a later request from cache can skip the same span in r.
+func (cr *ColumnReader) readAndDecompress(r io.Reader) ([]byte, int64, error) {
 	// Read compressed size (uint32)
 	var size uint32
 	if err := binary.Read(r, binary.LittleEndian, &size); err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
-	// Read compressed data
-	compressed := make([]byte, size)
+	// Read compressed data into a pooled buffer.
+	compressed := cr.bufPool.Get()
+	if cap(compressed) < int(size) {
+		compressed = make([]byte, size)
+	} else {
+		compressed = compressed[:size]
+	}
 	if _, err := io.ReadFull(r, compressed); err != nil {
-		return nil, err
+		return nil, 0, err
 	}
+	defer cr.bufPool.Put(compressed)
 
 	// Decompress
 	decompressed, err := cr.decoder.DecodeAll(compressed, nil)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
-	return decompressed, nil
+	return decompressed, int64(4 + size), nil
 }
 
 // bytesToInt64Slice converts a byte slice to []int64 (LittleEndian).
+// This is the v1 (raw) timestamp decode; v2 files use decodeTsColV2.
 func bytesToInt64Slice(data []byte) []int64 {
 	count := len(data) / 8
 	result := make([]int64, count)
@@ -256,6 +636,56 @@ func bytesToInt64Slice(data []byte) []int64 {
 	return result
 }
 
+// decodeTsColV2 reverses writeTsColV2: the first int64 is read raw, then
+// each subsequent value is reconstructed by accumulating ZigZag-decoded
+// delta-of-delta varints back into a delta and then a timestamp.
+func decodeTsColV2(data []byte, rowCount int) []int64 {
+	if rowCount == 0 {
+		return nil
+	}
+	result := make([]int64, rowCount)
+	buf := bytes.NewReader(data)
+	binary.Read(buf, binary.LittleEndian, &result[0])
+
+	var prevDelta int64
+	for i := 1; i < rowCount; i++ {
+		uv, err := binary.ReadUvarint(buf)
+		if err != nil {
+			break
+		}
+		dod := zigzagDecode(uv)
+		delta := prevDelta + dod
+		result[i] = result[i-1] + delta
+		prevDelta = delta
+	}
+	return result
+}
+
+// decodeLvlColV2 reverses writeLvlColV2's run-length (value, runLen) pairs.
+func decodeLvlColV2(data []byte, rowCount int) []uint8 {
+	result := make([]uint8, 0, rowCount)
+	buf := bytes.NewReader(data)
+	for buf.Len() > 0 && len(result) < rowCount {
+		v, err := buf.ReadByte()
+		if err != nil {
+			break
+		}
+		runLen, err := binary.ReadUvarint(buf)
+		if err != nil {
+			break
+		}
+		for i := uint64(0); i < runLen; i++ {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// zigzagDecode reverses zigzagEncode.
+func zigzagDecode(v uint64) int64 {
+	return int64(v>>1) ^ -int64(v&1)
+}
+
 // bytesToStringSlice converts a byte slice to []string.
 // Format: [Len uint32][Bytes]...
 func bytesToStringSlice(data []byte) []string {
@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/coffersTech/nanolog/server/internal/engine"
+)
+
+// benchMemTable builds a MemTable shaped like a realistic workload: mostly
+// INFO rows at sub-millisecond spacing, with occasional WARN/ERROR bursts.
+func benchMemTable(rows int) *engine.MemTable {
+	mt := engine.NewMemTable()
+	levels := []string{"INFO", "INFO", "INFO", "INFO", "INFO", "INFO", "WARN", "ERROR"}
+	ts := int64(1753833600000000000) // 2025-07-30T00:00:00Z in nanoseconds
+	for i := 0; i < rows; i++ {
+		ts += 300_000 // 300us spacing, typical of a busy service
+		mt.Append(ts, levels[i%len(levels)], "checkout", "host-1", "processed order")
+	}
+	return mt
+}
+
+// writeV1 writes mt in the old raw-column format, for comparison against
+// the delta-of-delta/RLE format v2 produces.
+func writeV1(w *ColumnWriter, f *closableBuffer, mt *engine.MemTable) error {
+	f.Write(MagicHeader)
+
+	var raw bytes.Buffer
+	for _, v := range mt.TsCol {
+		writeRawInt64(&raw, v)
+	}
+	if err := w.compressAndWrite(f, raw.Bytes()); err != nil {
+		return err
+	}
+	if err := w.compressAndWrite(f, mt.LvlCol); err != nil {
+		return err
+	}
+	if err := w.writeStringCol(f, mt.SvcCol); err != nil {
+		return err
+	}
+	if err := w.writeStringCol(f, mt.MsgCol); err != nil {
+		return err
+	}
+	return w.writeFooter(f, uint32(len(mt.TsCol)), mt.TsCol[0], mt.TsCol[len(mt.TsCol)-1])
+}
+
+func BenchmarkFileSizeV1(b *testing.B) {
+	mt := benchMemTable(10_000)
+	w, _ := NewColumnWriter()
+	var buf closableBuffer
+	if err := writeV1(w, &buf, mt); err != nil {
+		b.Fatalf("writeV1: %v", err)
+	}
+	b.ReportMetric(float64(buf.Len()), "bytes")
+}
+
+func BenchmarkFileSizeV4(b *testing.B) {
+	mt := benchMemTable(10_000)
+	w, _ := NewColumnWriter()
+	var buf closableBuffer
+	if err := w.WriteSnapshot(&buf, mt); err != nil {
+		b.Fatalf("WriteSnapshot: %v", err)
+	}
+	b.ReportMetric(float64(buf.Len()), "bytes")
+}
+
+func BenchmarkDecodeV1(b *testing.B) {
+	mt := benchMemTable(10_000)
+	w, _ := NewColumnWriter()
+	r, _ := NewColumnReader()
+	var src closableBuffer
+	if err := writeV1(w, &src, mt); err != nil {
+		b.Fatalf("writeV1: %v", err)
+	}
+	data := append([]byte(nil), src.Bytes()...)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf := closableBuffer{Buffer: *bytes.NewBuffer(append([]byte(nil), data...))}
+		if _, err := r.ReadSnapshot(&buf, engine.Filter{}, 0, nil); err != nil {
+			b.Fatalf("ReadSnapshot: %v", err)
+		}
+	}
+}
+
+func BenchmarkDecodeV4(b *testing.B) {
+	mt := benchMemTable(10_000)
+	w, _ := NewColumnWriter()
+	r, _ := NewColumnReader()
+	var src closableBuffer
+	if err := w.WriteSnapshot(&src, mt); err != nil {
+		b.Fatalf("WriteSnapshot: %v", err)
+	}
+	data := append([]byte(nil), src.Bytes()...)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf := closableBuffer{Buffer: *bytes.NewBuffer(append([]byte(nil), data...))}
+		if _, err := r.ReadSnapshot(&buf, engine.Filter{}, 0, nil); err != nil {
+			b.Fatalf("ReadSnapshot: %v", err)
+		}
+	}
+}
@@ -0,0 +1,301 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/coffersTech/nanolog/server/internal/engine"
+)
+
+func newTestMemTable(rows int) *engine.MemTable {
+	mt := engine.NewMemTable()
+	base := time.Date(2026, 7, 30, 0, 0, 0, 0, time.UTC).UnixNano()
+	levels := []string{"INFO", "INFO", "INFO", "INFO", "WARN", "ERROR", "INFO"}
+	for i := 0; i < rows; i++ {
+		ts := base + int64(i)*500_000 // sub-millisecond spacing
+		lvl := levels[i%len(levels)]
+		mt.Append(ts, lvl, "svc", "host", fmt.Sprintf("message %d", i))
+	}
+	return mt
+}
+
+func writeAndRead(t *testing.T, mt *engine.MemTable) []engine.LogRow {
+	t.Helper()
+
+	w, err := NewColumnWriter()
+	if err != nil {
+		t.Fatalf("NewColumnWriter: %v", err)
+	}
+	r, err := NewColumnReader()
+	if err != nil {
+		t.Fatalf("NewColumnReader: %v", err)
+	}
+
+	var buf closableBuffer
+	if err := w.WriteSnapshot(&buf, mt); err != nil {
+		t.Fatalf("WriteSnapshot: %v", err)
+	}
+
+	rows, err := r.ReadSnapshot(&buf, engine.Filter{}, 0, nil)
+	if err != nil {
+		t.Fatalf("ReadSnapshot: %v", err)
+	}
+	return rows
+}
+
+// closableBuffer adapts bytes.Buffer to io.ReadSeekCloser for round-trip
+// tests, since .nano files are normally read from a Storage-backed file.
+type closableBuffer struct {
+	bytes.Buffer
+	pos int64
+}
+
+func (b *closableBuffer) Seek(offset int64, whence int) (int64, error) {
+	var base int64
+	switch whence {
+	case 0:
+		base = 0
+	case 1:
+		base = b.pos
+	case 2:
+		base = int64(b.Len())
+	}
+	b.pos = base + offset
+	return b.pos, nil
+}
+
+func (b *closableBuffer) Read(p []byte) (int, error) {
+	full := b.Bytes()
+	if b.pos >= int64(len(full)) {
+		return 0, io.EOF
+	}
+	n := copy(p, full[b.pos:])
+	b.pos += int64(n)
+	return n, nil
+}
+
+func (b *closableBuffer) Close() error { return nil }
+
+func TestWriteSnapshotRoundTripV4(t *testing.T) {
+	mt := newTestMemTable(500)
+	rows := writeAndRead(t, mt)
+
+	if len(rows) != 500 {
+		t.Fatalf("expected 500 rows, got %d", len(rows))
+	}
+	for i, row := range rows {
+		if row.Timestamp != mt.TsCol[i] {
+			t.Fatalf("row %d: timestamp mismatch, got %d want %d", i, row.Timestamp, mt.TsCol[i])
+		}
+		if row.Level != mt.LvlCol[i] {
+			t.Fatalf("row %d: level mismatch, got %d want %d", i, row.Level, mt.LvlCol[i])
+		}
+		if row.Service != mt.SvcCol[i] {
+			t.Fatalf("row %d: service mismatch, got %q want %q", i, row.Service, mt.SvcCol[i])
+		}
+	}
+}
+
+// TestV4ServiceDictEncoding confirms the dictionary-coded Service column
+// round-trips every distinct value correctly and that filtering by
+// Service - whether the value is present or entirely absent from the
+// file's dictionary - still returns the right rows.
+func TestV4ServiceDictEncoding(t *testing.T) {
+	mt := engine.NewMemTable()
+	base := time.Date(2026, 7, 30, 0, 0, 0, 0, time.UTC).UnixNano()
+	services := []string{"checkout", "billing", "checkout", "auth", "billing", "checkout"}
+	for i, svc := range services {
+		mt.Append(base+int64(i), "INFO", svc, "host", fmt.Sprintf("msg %d", i))
+	}
+
+	rows := writeAndRead(t, mt)
+	if len(rows) != len(services) {
+		t.Fatalf("expected %d rows, got %d", len(services), len(rows))
+	}
+	for i, row := range rows {
+		if row.Service != services[i] {
+			t.Fatalf("row %d: service mismatch, got %q want %q", i, row.Service, services[i])
+		}
+	}
+
+	w, err := NewColumnWriter()
+	if err != nil {
+		t.Fatalf("NewColumnWriter: %v", err)
+	}
+	r, err := NewColumnReader()
+	if err != nil {
+		t.Fatalf("NewColumnReader: %v", err)
+	}
+	newBuf := func() *closableBuffer {
+		var buf closableBuffer
+		if err := w.WriteSnapshot(&buf, mt); err != nil {
+			t.Fatalf("WriteSnapshot: %v", err)
+		}
+		return &buf
+	}
+
+	filtered, err := r.ReadSnapshot(newBuf(), engine.Filter{Service: "billing"}, 0, nil)
+	if err != nil {
+		t.Fatalf("ReadSnapshot: %v", err)
+	}
+	if len(filtered) != 2 {
+		t.Fatalf("expected 2 billing rows, got %d", len(filtered))
+	}
+	for _, row := range filtered {
+		if row.Service != "billing" {
+			t.Fatalf("unexpected row service %q", row.Service)
+		}
+	}
+
+	filtered, err = r.ReadSnapshot(newBuf(), engine.Filter{Service: "nonexistent-svc"}, 0, nil)
+	if err != nil {
+		t.Fatalf("ReadSnapshot: %v", err)
+	}
+	if len(filtered) != 0 {
+		t.Fatalf("expected 0 rows for a service absent from the dictionary, got %d", len(filtered))
+	}
+}
+
+// TestV3BloomPruning confirms a v3 file's service and message Bloom
+// filters neither produce false negatives for values that are actually
+// present, nor let an absent value slip through as a false "maybe match"
+// in the per-row filter result (the Bloom only rules out whole files
+// before decompression; it never substitutes for the exact row check).
+func TestV3BloomPruning(t *testing.T) {
+	mt := engine.NewMemTable()
+	base := time.Date(2026, 7, 30, 0, 0, 0, 0, time.UTC).UnixNano()
+	mt.Append(base, "INFO", "checkout", "host-1", "order placed successfully")
+	mt.Append(base+1, "INFO", "billing", "host-2", "invoice generated")
+
+	w, err := NewColumnWriter()
+	if err != nil {
+		t.Fatalf("NewColumnWriter: %v", err)
+	}
+	r, err := NewColumnReader()
+	if err != nil {
+		t.Fatalf("NewColumnReader: %v", err)
+	}
+
+	newBuf := func() *closableBuffer {
+		var buf closableBuffer
+		if err := w.WriteSnapshot(&buf, mt); err != nil {
+			t.Fatalf("WriteSnapshot: %v", err)
+		}
+		return &buf
+	}
+
+	rows, err := r.ReadSnapshot(newBuf(), engine.Filter{Service: "checkout"}, 0, nil)
+	if err != nil {
+		t.Fatalf("ReadSnapshot: %v", err)
+	}
+	if len(rows) != 1 || rows[0].Service != "checkout" {
+		t.Fatalf("expected 1 checkout row, got %v", rows)
+	}
+
+	rows, err = r.ReadSnapshot(newBuf(), engine.Filter{Service: "nonexistent-svc"}, 0, nil)
+	if err != nil {
+		t.Fatalf("ReadSnapshot: %v", err)
+	}
+	if len(rows) != 0 {
+		t.Fatalf("expected 0 rows for absent service, got %d", len(rows))
+	}
+
+	rows, err = r.ReadSnapshot(newBuf(), engine.Filter{Query: "invoice"}, 0, nil)
+	if err != nil {
+		t.Fatalf("ReadSnapshot: %v", err)
+	}
+	if len(rows) != 1 || rows[0].Message != "invoice generated" {
+		t.Fatalf("expected 1 invoice row, got %v", rows)
+	}
+
+	rows, err = r.ReadSnapshot(newBuf(), engine.Filter{Query: "xyzzy-no-match"}, 0, nil)
+	if err != nil {
+		t.Fatalf("ReadSnapshot: %v", err)
+	}
+	if len(rows) != 0 {
+		t.Fatalf("expected 0 rows for absent query substring, got %d", len(rows))
+	}
+}
+
+func TestReadFileHeaderDetectsVersion(t *testing.T) {
+	mt := newTestMemTable(10)
+	w, err := NewColumnWriter()
+	if err != nil {
+		t.Fatalf("NewColumnWriter: %v", err)
+	}
+	var buf closableBuffer
+	if err := w.WriteSnapshot(&buf, mt); err != nil {
+		t.Fatalf("WriteSnapshot: %v", err)
+	}
+
+	fh, err := readFileHeader(&buf)
+	if err != nil {
+		t.Fatalf("readFileHeader: %v", err)
+	}
+	if fh.version != 4 {
+		t.Fatalf("expected version 4, got %d", fh.version)
+	}
+}
+
+// TestReadSnapshotV1Compat builds a v1-format file by hand (raw int64
+// timestamps, raw uint8 levels) to confirm the reader still decodes files
+// written before the delta-of-delta/RLE format bump.
+func TestReadSnapshotV1Compat(t *testing.T) {
+	mt := newTestMemTable(20)
+
+	w, err := NewColumnWriter()
+	if err != nil {
+		t.Fatalf("NewColumnWriter: %v", err)
+	}
+
+	var buf closableBuffer
+	buf.Write(MagicHeader)
+
+	var raw bytes.Buffer
+	for _, v := range mt.TsCol {
+		writeRawInt64(&raw, v)
+	}
+	if err := w.compressAndWrite(&buf, raw.Bytes()); err != nil {
+		t.Fatalf("writing ts column: %v", err)
+	}
+	if err := w.compressAndWrite(&buf, mt.LvlCol); err != nil {
+		t.Fatalf("writing lvl column: %v", err)
+	}
+	if err := w.writeStringCol(&buf, mt.SvcCol); err != nil {
+		t.Fatalf("writing svc column: %v", err)
+	}
+	if err := w.writeStringCol(&buf, mt.MsgCol); err != nil {
+		t.Fatalf("writing msg column: %v", err)
+	}
+	if err := w.writeFooter(&buf, uint32(len(mt.TsCol)), mt.TsCol[0], mt.TsCol[len(mt.TsCol)-1]); err != nil {
+		t.Fatalf("writing footer: %v", err)
+	}
+
+	r, err := NewColumnReader()
+	if err != nil {
+		t.Fatalf("NewColumnReader: %v", err)
+	}
+	rows, err := r.ReadSnapshot(&buf, engine.Filter{}, 0, nil)
+	if err != nil {
+		t.Fatalf("ReadSnapshot: %v", err)
+	}
+	if len(rows) != 20 {
+		t.Fatalf("expected 20 rows, got %d", len(rows))
+	}
+	for i, row := range rows {
+		if row.Timestamp != mt.TsCol[i] || row.Level != mt.LvlCol[i] {
+			t.Fatalf("row %d: got ts=%d lvl=%d, want ts=%d lvl=%d", i, row.Timestamp, row.Level, mt.TsCol[i], mt.LvlCol[i])
+		}
+	}
+}
+
+func writeRawInt64(buf *bytes.Buffer, v int64) {
+	var tmp [8]byte
+	for i := 0; i < 8; i++ {
+		tmp[i] = byte(v >> (8 * i))
+	}
+	buf.Write(tmp[:])
+}
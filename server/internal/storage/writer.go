@@ -3,14 +3,41 @@ package storage
 import (
 	"bytes"
 	"encoding/binary"
-	"os"
+	"io"
 
 	"github.com/klauspost/compress/zstd"
 	"github.com/coffersTech/nanolog/server/internal/engine"
 )
 
-// NanoLog Header
+// NanoLog Header.
+//
+// MagicHeader (v1) stores TsCol/LvlCol as raw, fixed-width values.
+// MagicHeaderV2 stores TsCol as delta-of-delta ZigZag varints and LvlCol as
+// run-length pairs, both still wrapped in the same zstd block afterwards.
+// MagicHeaderV3 keeps the v2 column encoding but replaces the fixed
+// 20-byte footer with a variable-length one carrying Bloom filters over
+// the service and message columns plus a top-services dictionary, so a
+// reader can rule out a whole file before decompressing any of its column
+// blocks. MagicHeaderV4 keeps the v3 footer but prepends a one-byte
+// encoding marker to every string column: SvcCol is dictionary-coded
+// (a per-file string table plus a uint16/uint32 code per row, letting
+// engine.Filter.Service matching compare integers instead of decoding
+// every row to a string), while MsgCol stays raw - free-text messages
+// are too high-cardinality for a dictionary to help. WriteSnapshot
+// always writes v4; v1-v3 are read-only, kept so existing .nano files on
+// disk keep working.
 var MagicHeader = []byte("NANOLOG1")
+var MagicHeaderV2 = []byte("NANOLOG2")
+var MagicHeaderV3 = []byte("NANOLOG3")
+var MagicHeaderV4 = []byte("NANOLOG4")
+
+// Per-column string encoding markers, written as the first decompressed
+// byte of every v4+ string column so mixed encodings can coexist (and a
+// future column can switch encodings without a file format bump).
+const (
+	stringColRaw byte = iota
+	stringColDict
+)
 
 type ColumnWriter struct {
 	encoder *zstd.Encoder
@@ -24,16 +51,14 @@ func NewColumnWriter() (*ColumnWriter, error) {
 	return &ColumnWriter{encoder: enc}, nil
 }
 
-// WriteSnapshot writes the MemTable to a .nano file.
-func (cw *ColumnWriter) WriteSnapshot(filename string, mt *engine.MemTable) error {
-	f, err := os.Create(filename)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-
+// WriteSnapshot writes the MemTable to w in .nano format. The caller owns
+// w and is responsible for closing it (and, if backed by a Storage, for
+// opening it via Storage.Create). The parameter is io.WriteCloser, matching
+// engine.SnapshotWriterFunc, so it plugs directly into Storage.Create's
+// return type without an adapter at the call site.
+func (cw *ColumnWriter) WriteSnapshot(f io.WriteCloser, mt *engine.MemTable) error {
 	// 1. Write Header
-	if _, err := f.Write(MagicHeader); err != nil {
+	if _, err := f.Write(MagicHeaderV4); err != nil {
 		return err
 	}
 
@@ -45,9 +70,7 @@ func (cw *ColumnWriter) WriteSnapshot(filename string, mt *engine.MemTable) erro
 
 	rowCount := uint32(len(tsData))
 	if rowCount == 0 {
-		// Even empty, write footer? Or just return.
-		// Header + Footer.
-		return cw.writeFooter(f, 0, 0, 0)
+		return cw.writeFooterV3(f, newBloomFilter(0), newBloomFilter(0), nil, 0, 0, 0)
 	}
 
 	minTs := tsData[0]
@@ -55,47 +78,95 @@ func (cw *ColumnWriter) WriteSnapshot(filename string, mt *engine.MemTable) erro
 
 	// 3. Compress and Write Columns
 
-	// Timestamp (Int64)
-	if err := cw.writeInt64Col(f, tsData); err != nil {
+	// Timestamp (delta-of-delta, ZigZag varint)
+	if err := cw.writeTsColV2(f, tsData); err != nil {
 		return err
 	}
 
-	// Level (Uint8)
-	if err := cw.writeUint8Col(f, lvlData); err != nil {
+	// Level (run-length encoded)
+	if err := cw.writeLvlColV2(f, lvlData); err != nil {
 		return err
 	}
 
-	// Service (String)
-	if err := cw.writeStringCol(f, svcData); err != nil {
+	// Service (dictionary-coded: low cardinality, so SDK/service names
+	// collapse to a handful of tiny integer codes per row)
+	if err := cw.writeDictStringCol(f, svcData); err != nil {
 		return err
 	}
 
-	// Message (String)
-	if err := cw.writeStringCol(f, msgData); err != nil {
+	// Message (raw: free-text, not a good dictionary fit)
+	if err := cw.writeRawStringCol(f, msgData); err != nil {
 		return err
 	}
 
-	// 4. Footer
-	return cw.writeFooter(f, rowCount, minTs, maxTs)
+	// 4. Build the pruning sketches and write the footer.
+	bloomSvc := newBloomFilter(len(svcData))
+	for _, s := range svcData {
+		bloomSvc.add([]byte(s))
+	}
+
+	bloomMsg := newBloomFilter(len(msgData) * 8) // several trigrams per message
+	for _, m := range msgData {
+		for _, g := range trigrams(m) {
+			bloomMsg.add(g)
+		}
+	}
+
+	return cw.writeFooterV3(f, bloomSvc, bloomMsg, topServices(svcData), rowCount, minTs, maxTs)
 }
 
-func (cw *ColumnWriter) writeInt64Col(f *os.File, data []int64) error {
+// writeTsColV2 encodes data as delta-of-delta ZigZag varints: the first
+// value is written as a raw int64, and each subsequent value is the
+// ZigZag-encoded varint of (delta - prevDelta), which collapses to a
+// single byte whenever consecutive log timestamps arrive at an even pace
+// (the common case for a busy service).
+func (cw *ColumnWriter) writeTsColV2(f io.Writer, data []int64) error {
 	buf := new(bytes.Buffer)
-	// Serialize: Just raw bytes
-	for _, v := range data {
-		binary.Write(buf, binary.LittleEndian, v)
+	if len(data) > 0 {
+		binary.Write(buf, binary.LittleEndian, data[0])
+	}
+
+	var prevDelta int64
+	for i := 1; i < len(data); i++ {
+		delta := data[i] - data[i-1]
+		dod := delta - prevDelta
+		writeVarint(buf, zigzagEncode(dod))
+		prevDelta = delta
 	}
 	return cw.compressAndWrite(f, buf.Bytes())
 }
 
-func (cw *ColumnWriter) writeUint8Col(f *os.File, data []uint8) error {
+// writeLvlColV2 encodes data as run-length (value, runLen) pairs, since
+// consecutive rows overwhelmingly share the same level.
+func (cw *ColumnWriter) writeLvlColV2(f io.Writer, data []uint8) error {
 	buf := new(bytes.Buffer)
-	// Serialize: Just raw bytes
-	binary.Write(buf, binary.LittleEndian, data)
+	for i := 0; i < len(data); {
+		v := data[i]
+		runLen := 1
+		for i+runLen < len(data) && data[i+runLen] == v {
+			runLen++
+		}
+		buf.WriteByte(v)
+		writeVarint(buf, uint64(runLen))
+		i += runLen
+	}
 	return cw.compressAndWrite(f, buf.Bytes())
 }
 
-func (cw *ColumnWriter) writeStringCol(f *os.File, data []string) error {
+// zigzagEncode maps a signed int64 to an unsigned one so small-magnitude
+// negative values stay small-magnitude varints.
+func zigzagEncode(v int64) uint64 {
+	return uint64((v << 1) ^ (v >> 63))
+}
+
+// writeVarint appends v to buf as a base-128 varint.
+func writeVarint(buf *bytes.Buffer, v uint64) {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], v)
+	buf.Write(tmp[:n])
+}
+
+func (cw *ColumnWriter) writeStringCol(f io.Writer, data []string) error {
 	buf := new(bytes.Buffer)
 	// Serialize: [Len uint32][Bytes]...
 	// We don't write count here because Header/Footer has RowCount,
@@ -109,7 +180,66 @@ func (cw *ColumnWriter) writeStringCol(f *os.File, data []string) error {
 	return cw.compressAndWrite(f, buf.Bytes())
 }
 
-func (cw *ColumnWriter) compressAndWrite(f *os.File, raw []byte) error {
+// writeRawStringCol is writeStringCol's v4+ counterpart: the same
+// [Len][Bytes]... body, prefixed with a stringColRaw marker byte so the
+// reader knows which decode to use without having to know the file
+// version's default for this particular column.
+func (cw *ColumnWriter) writeRawStringCol(f io.Writer, data []string) error {
+	buf := new(bytes.Buffer)
+	buf.WriteByte(stringColRaw)
+	for _, s := range data {
+		b := []byte(s)
+		binary.Write(buf, binary.LittleEndian, uint32(len(b)))
+		buf.Write(b)
+	}
+	return cw.compressAndWrite(f, buf.Bytes())
+}
+
+// writeDictStringCol encodes data as a per-file dictionary plus one
+// integer code per row: [marker byte][dictCount uint32][dict entries as
+// len+bytes]...[codeWidth byte][codes]. codeWidth is 2 (uint16) unless
+// the dictionary has more than 65535 entries, in which case it's 4
+// (uint32) - SvcCol and similar low-cardinality columns never hit that,
+// but this keeps the format from silently truncating codes if one does.
+func (cw *ColumnWriter) writeDictStringCol(f io.Writer, data []string) error {
+	dict := make([]string, 0, 16)
+	codes := make([]int, len(data))
+	index := make(map[string]int, 16)
+	for i, s := range data {
+		code, ok := index[s]
+		if !ok {
+			code = len(dict)
+			index[s] = code
+			dict = append(dict, s)
+		}
+		codes[i] = code
+	}
+
+	buf := new(bytes.Buffer)
+	buf.WriteByte(stringColDict)
+	binary.Write(buf, binary.LittleEndian, uint32(len(dict)))
+	for _, s := range dict {
+		b := []byte(s)
+		binary.Write(buf, binary.LittleEndian, uint32(len(b)))
+		buf.Write(b)
+	}
+
+	codeWidth := byte(2)
+	if len(dict) > 0xFFFF {
+		codeWidth = 4
+	}
+	buf.WriteByte(codeWidth)
+	for _, code := range codes {
+		if codeWidth == 2 {
+			binary.Write(buf, binary.LittleEndian, uint16(code))
+		} else {
+			binary.Write(buf, binary.LittleEndian, uint32(code))
+		}
+	}
+	return cw.compressAndWrite(f, buf.Bytes())
+}
+
+func (cw *ColumnWriter) compressAndWrite(f io.Writer, raw []byte) error {
 	compressed := cw.encoder.EncodeAll(raw, make([]byte, 0, len(raw)))
 
 	// Write Compressed Size (uint32)
@@ -123,16 +253,53 @@ func (cw *ColumnWriter) compressAndWrite(f *os.File, raw []byte) error {
 	return err
 }
 
-func (cw *ColumnWriter) writeFooter(f *os.File, rowCount uint32, minTs, maxTs int64) error {
-	// RowCount (4) + MinTs (8) + MaxTs (8)
+// writeFooter writes the fixed 20-byte v1/v2 footer (RowCount, MinTs,
+// MaxTs). WriteSnapshot no longer calls this directly - it always writes
+// the v3 footer via writeFooterV3 - but it's kept for constructing v1/v2 fixtures
+// (see reader_test.go, reader_bench_test.go) since that footer shape is
+// still what readFileHeader expects from those versions.
+func (cw *ColumnWriter) writeFooter(f io.Writer, rowCount uint32, minTs, maxTs int64) error {
 	if err := binary.Write(f, binary.LittleEndian, rowCount); err != nil {
 		return err
 	}
 	if err := binary.Write(f, binary.LittleEndian, minTs); err != nil {
 		return err
 	}
-	if err := binary.Write(f, binary.LittleEndian, maxTs); err != nil {
+	return binary.Write(f, binary.LittleEndian, maxTs)
+}
+
+// writeFooterV3 writes the v3 footer: length-prefixed service and message
+// Bloom filters, a length-prefixed top-services dictionary, then the same
+// RowCount/MinTs/MaxTs fields v1/v2 wrote as their entire footer. A
+// trailing uint32 records the footer's own byte length, so readFileHeader
+// can locate its start by seeking backward from EOF instead of needing to
+// scan forward through the column blocks first.
+func (cw *ColumnWriter) writeFooterV3(f io.Writer, bloomSvc, bloomMsg *bloomFilter, dict []string, rowCount uint32, minTs, maxTs int64) error {
+	buf := new(bytes.Buffer)
+
+	writeBlob := func(b []byte) {
+		binary.Write(buf, binary.LittleEndian, uint32(len(b)))
+		buf.Write(b)
+	}
+
+	writeBlob(bloomSvc.bits)
+	writeBlob(bloomMsg.bits)
+
+	dictBuf := new(bytes.Buffer)
+	binary.Write(dictBuf, binary.LittleEndian, uint32(len(dict)))
+	for _, s := range dict {
+		b := []byte(s)
+		binary.Write(dictBuf, binary.LittleEndian, uint32(len(b)))
+		dictBuf.Write(b)
+	}
+	writeBlob(dictBuf.Bytes())
+
+	binary.Write(buf, binary.LittleEndian, rowCount)
+	binary.Write(buf, binary.LittleEndian, minTs)
+	binary.Write(buf, binary.LittleEndian, maxTs)
+
+	if _, err := f.Write(buf.Bytes()); err != nil {
 		return err
 	}
-	return nil
+	return binary.Write(f, binary.LittleEndian, uint32(buf.Len()))
 }